@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 每个房间的人数上限，创建时可用 ?maxplayers= 和 ?maxspectators= 修改
+const (
+	defaultMaxPlayers    = 10
+	playersLimit         = 100
+	defaultMaxSpectators = 50
+	spectatorsLimit      = 500
+)
+
+// 房间已满时的关闭码，4000-4999留给应用自定义
+const closeRoomFull = 4001
+
+// 房间已满，max为上限
+type roomFullMsg struct {
+	errorMsg
+	Max int `json:"max"`
+}
+
+// 房间已满时拒绝新连接：发送room_full错误（带上限limit）后以closeRoomFull关闭
+func rejectFull(conn *websocket.Conn, lang string, body text, limit int) {
+	rejectConn(conn, lang, roomFullMsg{
+		errorMsg: errorMsg{baseMsg: base(msgError, body), Code: errRoomFull},
+		Max:      limit,
+	}, closeRoomFull, "room full")
+}
+
+// 拒绝还没有writePump的连接：直接写出按lang渲染的错误消息m，再以code关闭
+func rejectConn(conn *websocket.Conn, lang string, m any, code int, reason string) {
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	conn.WriteMessage(websocket.TextMessage, render(m, lang))
+	closeConn(conn, code, reason)
+}
+
+// 发送关闭帧并断开还没有writePump的连接
+func closeConn(conn *websocket.Conn, code int, text string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeWait))
+	conn.Close()
+}
@@ -0,0 +1,53 @@
+package main
+
+// 难度预设，创建房间时用 ?difficulty= 选择，?min/?max/?attempts 仍可单独覆盖
+type Difficulty struct {
+	Name     string
+	Min, Max int
+	Attempts int  // 每轮次数上限，0表示不限
+	Hints    bool // 太大/太小之外再给出冷热提示
+}
+
+var difficulties = map[string]Difficulty{
+	"easy":   {Name: "easy", Min: 1, Max: 50, Hints: true},
+	"normal": {Name: "normal", Min: 1, Max: 100, Attempts: 15},
+	"hard":   {Name: "hard", Min: 1, Max: 1000, Attempts: 12},
+}
+
+// 不选难度时的默认设置，与引入难度之前的行为一致；结果中记为空串
+var defaultDifficulty = Difficulty{Min: defaultMin, Max: defaultMax}
+
+// 按名称查找难度，未知名称返回默认设置
+func lookupDifficulty(name string) Difficulty {
+	if d, ok := difficulties[name]; ok {
+		return d
+	}
+	return defaultDifficulty
+}
+
+// 冷热提示
+const (
+	hintHot  = "hot"
+	hintWarm = "warm"
+	hintCold = "cold"
+)
+
+// 按猜测与答案的距离占整个范围的比例给出冷热提示，文字见消息目录的hint.*
+func hotCold(guess, secret, lo, hi int) string {
+	ratio := float64(abs(guess-secret)) / float64(hi-lo)
+	switch {
+	case ratio <= 0.05:
+		return hintHot
+	case ratio <= 0.15:
+		return hintWarm
+	default:
+		return hintCold
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// 数字牛玩法（?mode=digits）谜底的位数
+const digitsLen = 4
+
+// 生成各位互不相同的谜底，首位可以是0
+func newCode() string {
+	b := []byte("0123456789")
+	rand.Shuffle(len(b), func(i, j int) { b[i], b[j] = b[j], b[i] })
+	return string(b[:digitsLen])
+}
+
+// 校验猜测是否为digitsLen位各不相同的数字，返回去掉空白后的猜测
+func parseCode(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) != digitsLen {
+		return "", fmt.Errorf("need %d digits, got %q", digitsLen, s)
+	}
+	var seen [10]bool
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("not a digit: %q", c)
+		}
+		if seen[c-'0'] {
+			return "", fmt.Errorf("repeated digit: %q", c)
+		}
+		seen[c-'0'] = true
+	}
+	return s, nil
+}
+
+// 计算xAyB：A为数字和位置都对的个数，B为数字对但位置不对的个数。
+// 两者都应是parseCode校验过的字符串
+func bullsCows(secret, guess string) (a, b int) {
+	for i := 0; i < len(guess); i++ {
+		switch j := strings.IndexByte(secret, guess[i]); {
+		case j == i:
+			a++
+		case j >= 0:
+			b++
+		}
+	}
+	return a, b
+}
+
+// 数字牛玩法的判定，调用方需持有房间锁
+func (r *Room) judgeDigits(input string) (verdict, bool) {
+	code, err := parseCode(input)
+	if err != nil {
+		return verdict{}, false
+	}
+	a, b := bullsCows(r.code, code)
+	v := verdict{
+		feedback: feedbackMsg{
+			baseMsg: base(msgFeedback, tr("digits.result", a, b)),
+			Result:  resultMiss,
+			Guess:   code,
+			Digits:  &digitsResult{A: a, B: b},
+		},
+		counted: true,
+	}
+	if a == digitsLen {
+		v.feedback.Result = resultCorrect
+		v.solved = true
+	}
+	return v, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestBullsCows(t *testing.T) {
+	tests := []struct {
+		secret, guess string
+		a, b          int
+	}{
+		{"1234", "1234", 4, 0},
+		{"1234", "5678", 0, 0},
+		{"1234", "4321", 0, 4},
+		{"1234", "1243", 2, 2},
+		{"1234", "1567", 1, 0},
+		{"1234", "5123", 0, 3},
+		{"0912", "9012", 2, 2},
+		{"0912", "0345", 1, 0},
+	}
+	for _, tt := range tests {
+		if a, b := bullsCows(tt.secret, tt.guess); a != tt.a || b != tt.b {
+			t.Errorf("bullsCows(%s, %s) = %dA%dB, want %dA%dB", tt.secret, tt.guess, a, b, tt.a, tt.b)
+		}
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // 空表示应当拒绝
+	}{
+		{"0123", "0123"},
+		{" 9876\n", "9876"},
+		{"1123", ""}, // 重复数字
+		{"1231", ""},
+		{"123", ""},
+		{"12345", ""},
+		{"12a4", ""},
+		{"-123", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := parseCode(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseCode(%q) = %q, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseCode(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// 谜底总是digitsLen位各不相同的数字
+func TestNewCode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if code := newCode(); len(code) != digitsLen {
+			t.Fatalf("newCode() = %q, want %d digits", code, digitsLen)
+		} else if _, err := parseCode(code); err != nil {
+			t.Fatalf("newCode() = %q: %v", code, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 数据库健康检查
+const (
+	startupPingTimeout = 5 * time.Second  // 启动时连不上数据库就退出
+	healthInterval     = 10 * time.Second // 后台检查的间隔
+	healthPingTimeout  = 2 * time.Second
+)
+
+// 最近一次数据库检查的结果，由后台协程更新，/health只读取结果，不会被卡住的数据库阻塞
+type dbHealth struct {
+	mu        sync.RWMutex
+	up        bool
+	checkedAt time.Time
+	err       string // 最近一次失败的原因
+}
+
+// 带超时ping一次数据库并记录结果
+func (h *dbHealth) check(db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := db.PingContext(ctx)
+	h.set(err)
+	return err
+}
+
+func (h *dbHealth) set(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.up = err == nil
+	h.checkedAt = time.Now()
+	h.err = ""
+	if err != nil {
+		h.err = err.Error()
+	}
+}
+
+// 定期检查数据库，随进程一直运行
+func (h *dbHealth) run(db *sql.DB) {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.check(db, healthPingTimeout)
+	}
+}
+
+// 健康检查接口：GET /health，数据库不可用时返回503
+func (s *GameServer) health(c *gin.Context) {
+	s.dbHealth.mu.RLock()
+	up, checkedAt, reason := s.dbHealth.up, s.dbHealth.checkedAt, s.dbHealth.err
+	s.dbHealth.mu.RUnlock()
+
+	if up {
+		c.JSON(http.StatusOK, gin.H{"ok": true, "db": "up", "checked_at": checkedAt.Format(time.RFC3339)})
+		return
+	}
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"ok":         false,
+		"db":         "down",
+		"error":      reason,
+		"checked_at": checkedAt.Format(time.RFC3339),
+	})
+}
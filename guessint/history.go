@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 有人猜对而结束的一轮在guess_rounds中的结束原因，其他原因同round_end消息
+const reasonSolved = "solved"
+
+// 没人猜对时guess_rounds.winner的值
+const noWinner = "unsolved"
+
+// 轮次历史每页的默认和最大条数
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// guess_rounds的一行
+type roundRow struct {
+	room     string
+	roundID  int64
+	round    int
+	game     string
+	answer   string
+	winner   string
+	reason   string
+	guesses  int
+	duration time.Duration
+}
+
+// 由一轮的快照生成guess_rounds的一行
+func (r *Room) roundRow(rr roundResult) roundRow {
+	winner := rr.winner
+	if winner == "" {
+		winner = noWinner
+	}
+	return roundRow{
+		room:     r.name,
+		roundID:  rr.id,
+		round:    rr.round,
+		game:     r.game,
+		answer:   fmt.Sprint(rr.answer),
+		winner:   winner,
+		reason:   rr.reason,
+		guesses:  rr.attempts,
+		duration: time.Since(time.Unix(0, rr.id)),
+	}
+}
+
+func (row roundRow) insert(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO guess_rounds (room_name, round_id, round_no, game_mode, answer, winner, end_reason, guesses, duration_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		row.room, row.roundID, row.round, row.game, row.answer, row.winner, row.reason, row.guesses, row.duration.Milliseconds())
+	return err
+}
+
+// 轮次历史的一项
+type RoundRecord struct {
+	ID         int64  `json:"id"`
+	Round      int    `json:"round"`
+	Game       string `json:"game"`
+	Answer     string `json:"answer"`
+	Winner     string `json:"winner"` // 没人猜对时为unsolved
+	Reason     string `json:"reason"`
+	Guesses    int    `json:"guesses"`
+	DurationMs int64  `json:"duration_ms"`
+	EndedAt    string `json:"ended_at"`
+}
+
+// 查询房间的轮次记录，按结束时间从新到旧；before大于0时只返回id小于它的记录
+func queryRounds(ctx context.Context, db *sql.DB, room string, before int64, limit int) ([]RoundRecord, error) {
+	query := `SELECT id, round_no, game_mode, answer, winner, end_reason, guesses, duration_ms, created_at
+		FROM guess_rounds WHERE room_name = ?`
+	args := []any{room}
+	if before > 0 {
+		query += " AND id < ?"
+		args = append(args, before)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []RoundRecord{}
+	for rows.Next() {
+		var rec RoundRecord
+		if err := rows.Scan(&rec.ID, &rec.Round, &rec.Game, &rec.Answer, &rec.Winner, &rec.Reason,
+			&rec.Guesses, &rec.DurationMs, &rec.EndedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, rec)
+	}
+	return list, rows.Err()
+}
+
+// 轮次历史接口：GET /api/rooms/:room/rounds?limit=50&before=123，按结束时间从新到旧。
+// 翻页时把上一页返回的next_before作为before，没有更多记录时不返回next_before
+func (s *GameServer) roundHistory(c *gin.Context) {
+	room := c.Param("room")
+	limit := defaultHistoryLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxHistoryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxHistoryLimit)})
+			return
+		}
+		limit = n
+	}
+	var before int64
+	if v := c.Query("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before must be a positive id"})
+			return
+		}
+		before = n
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), leaderboardTimeout)
+	defer cancel()
+	list, err := queryRounds(ctx, s.db, room, before, limit)
+	if err != nil {
+		fmt.Println("查询轮次记录失败:", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "db query timeout"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query error"})
+		return
+	}
+	resp := gin.H{"data": list, "room": room, "limit": limit}
+	if len(list) == limit {
+		resp["next_before"] = list[len(list)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
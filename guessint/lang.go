@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// 消息语言，连接时用 ?lang=en 选择，默认中文。
+// 消息中只保存文本的消息ID和参数，写到连接前才按接收者的语言渲染，
+// 同一条广播在中英文玩家混合的房间里各自看到自己的语言
+const (
+	langZh = "zh"
+	langEn = "en"
+)
+
+// 按 ?lang= 选择语言，不认识的语言用中文
+func parseLang(s string) string {
+	if _, ok := catalog[s]; ok {
+		return s
+	}
+	return langZh
+}
+
+// 消息目录：语言 -> 消息ID -> fmt格式。每个ID在各语言中都要有，
+// 万一缺少时用中文，中文也没有时直接显示ID
+var catalog = map[string]map[string]string{
+	langZh: {
+		"list.sep": "、",
+		"clause":   "，%s",
+
+		"prompt.number":     "请猜 %d 到 %d 之间的数字",
+		"prompt.digits":     "请猜 %d 位各不相同的数字",
+		"prompt.word":       "请猜单词 %s",
+		"difficulty":        "（难度：%s）",
+		"difficulty.easy":   "简单",
+		"difficulty.normal": "普通",
+		"difficulty.hard":   "困难",
+
+		"welcome":           "欢迎来到房间 %s，你是 %s，%s%s",
+		"welcome.attempts":  "，每轮共 %d 次机会",
+		"welcome.time":      "，每轮限时 %d 秒",
+		"welcome.turns":     "。回合制房间，当前轮到 %s",
+		"welcome.private":   "。猜测结果只有自己可见",
+		"welcome.countdown": "。新一轮将在 %d 秒后开始",
+		"welcome.spectator": "。你正在观战，不能猜测",
+
+		"guess.by":           "玩家 %s 猜 %v：%s",
+		"guess.low":          "太小了",
+		"guess.high":         "太大了",
+		"guess.out_of_range": "超出范围，请猜 %d 到 %d 之间的数字",
+		"guess.solved":       "玩家 %s 猜对了！答案是 %v，得 %d 分%s",
+		"guess.remaining":    "（本轮剩余 %d 次）",
+		"guess.streak":       "，%s 连胜 %d 局！",
+		"hint.hot":           "很接近了",
+		"hint.warm":          "有点接近",
+		"hint.cold":          "还差得远",
+		"digits.result":      "%dA%dB",
+		"word.state":         "%s（猜错 %d/%d 次）",
+		"word.repeated":      "字母 %s 已经猜过了",
+		"word.letter_hit":    "玩家 %s 猜字母 %s：有",
+		"word.letter_miss":   "玩家 %s 猜字母 %s：没有",
+		"word.wrong":         "玩家 %s 猜单词 %s：不对",
+
+		"round.start":           "新一轮开始！%s%s",
+		"round.warning":         "本轮还剩 %d 秒",
+		"round.countdown":       "新一轮将在 %d 秒后开始",
+		"round.time_up":         "时间到，没有人猜对，答案是 %v",
+		"round.out_of_attempts": "本轮 %d 次机会已用完，答案是 %v",
+		"round.out_of_misses":   "猜错 %d 次，本轮失败，答案是 %v",
+		"scoreboard":            "积分榜：%s",
+
+		"player.joined":     "玩家 %s 加入了房间 %s，当前玩家数: %d（%s）%s",
+		"player.left":       "玩家 %s 离开了房间 %s，当前玩家数: %d%s",
+		"player.resumed":    "玩家 %s 重新连接",
+		"player.spectators": "，观战 %d 人",
+		"turn":              "轮到玩家 %s 猜数字",
+		"turn.timeout":      "玩家 %s 超时未猜",
+		"server.closing":    "服务器正在关闭，请稍后重新连接",
+
+		"error.invalid_number":    "请输入有效的数字",
+		"error.invalid_digits":    "请输入 %d 位各不相同的数字",
+		"error.invalid_word":      "请输入一个英文字母或整个单词",
+		"error.bad_message":       "无法识别的消息",
+		"error.round_not_started": "回合尚未开始",
+		"error.not_your_turn":     "不是你的回合",
+		"error.spectator":         "你正在观战，不能猜测，如需参与请以玩家身份加入房间",
+		"error.room_full":         "房间已满（最多 %d 名玩家），可以换个房间或以观战身份加入",
+		"error.spectators_full":   "观战人数已满（最多 %d 人）",
+		"error.wrong_pass":        "房间口令错误",
+	},
+	langEn: {
+		"list.sep": ", ",
+		"clause":   ", %s",
+
+		"prompt.number":     "Guess a number between %d and %d",
+		"prompt.digits":     "Guess a %d-digit number with no repeated digits",
+		"prompt.word":       "Guess the word %s",
+		"difficulty":        " (difficulty: %s)",
+		"difficulty.easy":   "easy",
+		"difficulty.normal": "normal",
+		"difficulty.hard":   "hard",
+
+		"welcome":           "Welcome to room %s, you are %s. %s%s",
+		"welcome.attempts":  ", %d guesses per round",
+		"welcome.time":      ", %d seconds per round",
+		"welcome.turns":     ". Players take turns, it's %s's turn now",
+		"welcome.private":   ". Only you can see the results of your guesses",
+		"welcome.countdown": ". The next round starts in %d seconds",
+		"welcome.spectator": ". You are spectating and cannot guess",
+
+		"guess.by":           "%s guessed %v: %s",
+		"guess.low":          "too low",
+		"guess.high":         "too high",
+		"guess.out_of_range": "Out of range, guess a number between %d and %d",
+		"guess.solved":       "%s got it! The answer was %v, +%d points%s",
+		"guess.remaining":    " (guesses left this round: %d)",
+		"guess.streak":       ", %s has won %d in a row!",
+		"hint.hot":           "very close",
+		"hint.warm":          "getting close",
+		"hint.cold":          "far off",
+		"digits.result":      "%dA%dB",
+		"word.state":         "%s (%d/%d misses)",
+		"word.repeated":      "The letter %s has already been guessed",
+		"word.letter_hit":    "%s guessed the letter %s: it's in the word",
+		"word.letter_miss":   "%s guessed the letter %s: not in the word",
+		"word.wrong":         "%s guessed the word %s: wrong",
+
+		"round.start":           "New round! %s%s",
+		"round.warning":         "%d seconds left in this round",
+		"round.countdown":       "The next round starts in %d seconds",
+		"round.time_up":         "Time's up and nobody got it, the answer was %v",
+		"round.out_of_attempts": "All %d guesses of this round are used up, the answer was %v",
+		"round.out_of_misses":   "%d misses, the round is lost, the answer was %v",
+		"scoreboard":            "Scoreboard: %s",
+
+		"player.joined":     "%s joined room %s, players: %d (%s)%s",
+		"player.left":       "%s left room %s, players: %d%s",
+		"player.resumed":    "%s reconnected",
+		"player.spectators": ", spectators: %d",
+		"turn":              "It's %s's turn",
+		"turn.timeout":      "%s ran out of time",
+		"server.closing":    "The server is shutting down, please reconnect later",
+
+		"error.invalid_number":    "Please enter a valid number",
+		"error.invalid_digits":    "Please enter %d different digits",
+		"error.invalid_word":      "Please enter a single letter or the whole word",
+		"error.bad_message":       "Unrecognized message",
+		"error.round_not_started": "The round has not started yet",
+		"error.not_your_turn":     "It's not your turn",
+		"error.spectator":         "You are spectating and cannot guess, join the room as a player to take part",
+		"error.room_full":         "The room is full (at most %d players), try another room or join as a spectator",
+		"error.spectators_full":   "No more spectators allowed (at most %d)",
+		"error.wrong_pass":        "Wrong room password",
+	},
+}
+
+// 按语言查找消息ID对应的格式，找不到时依次退回中文和ID本身
+func lookup(lang, id string) string {
+	if f, ok := catalog[lang][id]; ok {
+		return f
+	}
+	if f, ok := catalog[langZh][id]; ok {
+		return f
+	}
+	return id
+}
+
+// 待渲染的文本：按顺序拼接的若干段，每段是消息ID和参数。
+// 参数可以是另一段text或list，渲染时用同一种语言
+type text []textPart
+
+type textPart struct {
+	id   string
+	args []any
+}
+
+// 一段待渲染的文本
+func tr(id string, args ...any) text {
+	return text{{id: id, args: args}}
+}
+
+// 渲染成lang语言的字符串，nil为空串
+func (t text) render(lang string) string {
+	var b strings.Builder
+	for _, p := range t {
+		args := make([]any, len(p.args))
+		for i, a := range p.args {
+			switch v := a.(type) {
+			case text:
+				args[i] = v.render(lang)
+			case list:
+				args[i] = v.render(lang)
+			default:
+				args[i] = a
+			}
+		}
+		fmt.Fprintf(&b, lookup(lang, p.id), args...)
+	}
+	return b.String()
+}
+
+// 名字列表，按语言的分隔符连接
+type list []string
+
+func (l list) render(lang string) string {
+	return strings.Join(l, lookup(lang, "list.sep"))
+}
+
+// 按lang编码一条消息：复制一份并把文本渲染成该语言，原消息不变，可以同时发给多种语言
+func render(m any, lang string) []byte {
+	v := reflect.New(reflect.TypeOf(m))
+	v.Elem().Set(reflect.ValueOf(m))
+	if l, ok := v.Interface().(interface{ localize(string) }); ok {
+		l.localize(lang)
+	}
+	return marshal(v.Interface())
+}
+
+// 同一条广播按语言缓存编码结果，每种语言只编码一次
+type encodings struct {
+	m    any
+	data map[string][]byte
+}
+
+func (e *encodings) get(lang string) []byte {
+	if data, ok := e.data[lang]; ok {
+		return data
+	}
+	if e.data == nil {
+		e.data = make(map[string][]byte, 2)
+	}
+	data := render(e.m, lang)
+	e.data[lang] = data
+	return data
+}
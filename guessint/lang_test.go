@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fmt动词，不含%%
+var verbPattern = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z]`)
+
+// 每个消息ID在中英文中都有，且参数的个数和类型一致
+func TestCatalogComplete(t *testing.T) {
+	for id, zh := range catalog[langZh] {
+		en, ok := catalog[langEn][id]
+		if !ok {
+			t.Errorf("%s: missing in en", id)
+			continue
+		}
+		if vz, ve := verbPattern.FindAllString(zh, -1), verbPattern.FindAllString(en, -1); !slices.Equal(vz, ve) {
+			t.Errorf("%s: zh verbs %v, en verbs %v", id, vz, ve)
+		}
+	}
+	for id := range catalog[langEn] {
+		if _, ok := catalog[langZh][id]; !ok {
+			t.Errorf("%s: missing in zh", id)
+		}
+	}
+}
+
+func TestParseLang(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"en", langEn},
+		{"zh", langZh},
+		{"", langZh},
+		{"fr", langZh},
+		{"EN", langZh},
+	}
+	for _, tt := range tests {
+		if got := parseLang(tt.in); got != tt.want {
+			t.Errorf("parseLang(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 找不到的语言或ID依次退回中文和ID本身
+func TestLookupFallback(t *testing.T) {
+	tests := []struct{ lang, id, want string }{
+		{langEn, "guess.low", "too low"},
+		{langZh, "guess.low", "太小了"},
+		{"fr", "guess.low", "太小了"},
+		{langEn, "no.such.id", "no.such.id"},
+	}
+	for _, tt := range tests {
+		if got := lookup(tt.lang, tt.id); got != tt.want {
+			t.Errorf("lookup(%s, %s) = %q, want %q", tt.lang, tt.id, got, tt.want)
+		}
+	}
+}
+
+// 同一条广播按每个接收者的语言渲染，嵌套的文本和列表用同一种语言
+func TestRenderPerLanguage(t *testing.T) {
+	m := playerMsg{baseMsg: base(msgPlayerJoined, tr("player.joined", "bob", "r1", 2, list{"alice", "bob"}, tr("player.spectators", 1)))}
+	tests := []struct{ lang, want string }{
+		{langZh, `玩家 bob 加入了房间 r1，当前玩家数: 2（alice、bob），观战 1 人`},
+		{langEn, `bob joined room r1, players: 2 (alice, bob), spectators: 1`},
+	}
+	for _, tt := range tests {
+		var got struct{ Text string }
+		if err := json.Unmarshal(render(m, tt.lang), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Text != tt.want {
+			t.Errorf("%s: text = %q, want %q", tt.lang, got.Text, tt.want)
+		}
+	}
+	if m.Text != "" {
+		t.Errorf("render changed the original message: %q", m.Text)
+	}
+}
+
+// ?lang=en 的连接收到英文，不认识的语言和未指定时收到中文
+func TestLangQuery(t *testing.T) {
+	_, ts := newTestServer(t)
+	tests := []struct{ query, want string }{
+		{"lang=en&name=amy", "Welcome to room lang, you are amy. "},
+		{"lang=fr&name=ben", "欢迎来到房间 lang，你是 ben，"},
+		{"name=cat", "欢迎来到房间 lang，你是 cat，"},
+	}
+	for _, tt := range tests {
+		c := dial(t, ts, "lang", tt.query)
+		text, _ := expect(t, c, msgWelcome)["text"].(string)
+		if !strings.HasPrefix(text, tt.want) {
+			t.Errorf("?%s: welcome = %q, want prefix %q", tt.query, text, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 排行榜查询的超时时间
+const leaderboardTimeout = 3 * time.Second
+
+// 排行榜的一行
+type LeaderRow struct {
+	PlayerID string  `json:"player_id"`
+	Wins     int     `json:"wins"`
+	Losses   int     `json:"losses"` // 没有赢的轮次，包括次数用完和时间到
+	WinRate  float64 `json:"win_rate"`
+	// 赢的轮次平均猜了几次，没有记录次数的旧数据不参与计算，没有数据时为null
+	AvgAttempts *float64 `json:"avg_attempts"`
+	BestStreak  int      `json:"best_streak"` // 同一房间内的最长连胜局数
+	Points      int      `json:"points"`      // 累计积分
+}
+
+// 排行榜查询参数
+type leaderboardQuery struct {
+	room       string // 为空表示汇总所有房间
+	difficulty string // 为空表示不限难度
+	sort       string // wins按胜场排序（默认），points按累计积分排序
+	limit      int
+}
+
+// 解析 ?room=、?difficulty=、?sort= 和 ?limit=，limit默认10，范围1到100
+func parseLeaderboardQuery(c *gin.Context) (leaderboardQuery, error) {
+	q := leaderboardQuery{room: c.Query("room"), sort: "wins", limit: 10}
+	switch v := c.Query("sort"); v {
+	case "", "wins":
+	case "points":
+		q.sort = v
+	default:
+		return q, errors.New("sort must be wins or points")
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			return q, errors.New("limit must be between 1 and 100")
+		}
+		q.limit = n
+	}
+	if v := c.Query("difficulty"); v != "" {
+		if _, ok := difficulties[v]; !ok {
+			return q, errors.New("difficulty must be easy, normal or hard")
+		}
+		q.difficulty = v
+	}
+	return q, nil
+}
+
+// 按胜场或累计积分排序查询排行榜
+func queryLeaderboard(ctx context.Context, db *sql.DB, q leaderboardQuery) ([]LeaderRow, error) {
+	query := `SELECT player_id,
+		SUM(result = 'win') AS wins,
+		SUM(result <> 'win') AS losses,
+		AVG(CASE WHEN result = 'win' AND attempts > 0 THEN attempts END) AS avg_attempts,
+		MAX(streak) AS best_streak,
+		SUM(points) AS points
+		FROM game_results`
+	var where []string
+	var args []any
+	if q.room != "" {
+		where = append(where, "room_name = ?")
+		args = append(args, q.room)
+	}
+	if q.difficulty != "" {
+		where = append(where, "difficulty = ?")
+		args = append(args, q.difficulty)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " GROUP BY player_id"
+	if q.sort == "points" {
+		query += " ORDER BY points DESC, wins DESC, player_id LIMIT ?"
+	} else {
+		query += " ORDER BY wins DESC, losses ASC, player_id LIMIT ?"
+	}
+	args = append(args, q.limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []LeaderRow{}
+	for rows.Next() {
+		var row LeaderRow
+		var avg sql.NullFloat64
+		if err := rows.Scan(&row.PlayerID, &row.Wins, &row.Losses, &avg, &row.BestStreak, &row.Points); err != nil {
+			return nil, err
+		}
+		if total := row.Wins + row.Losses; total > 0 {
+			row.WinRate = float64(row.Wins) / float64(total)
+		}
+		if avg.Valid {
+			row.AvgAttempts = &avg.Float64
+		}
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
+
+// 排行榜接口：GET /api/leaderboard?room=room1&difficulty=hard&sort=points&limit=10
+func (s *GameServer) leaderboard(c *gin.Context) {
+	q, err := parseLeaderboardQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), leaderboardTimeout)
+	defer cancel()
+	list, err := queryLeaderboard(ctx, s.db, q)
+	if err != nil {
+		fmt.Println("查询排行榜失败:", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "db query timeout"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "room": q.room, "difficulty": q.difficulty, "sort": q.sort, "limit": q.limit})
+}
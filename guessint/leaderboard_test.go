@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求排行榜接口，返回状态码并把响应解析到out
+func getLeaderboard(t *testing.T, s *GameServer, ctx context.Context, query string, out any) int {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/leaderboard", s.leaderboard)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/leaderboard?"+query, nil).WithContext(ctx)
+	r.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("%s: bad body %s: %v", query, w.Body, err)
+	}
+	return w.Code
+}
+
+// 参数不合法时在查询数据库之前返回400；数据库出错返回500，超时返回504，都带JSON错误
+func TestLeaderboardErrors(t *testing.T) {
+	// 没有监听的端口，连接会失败
+	db, err := sql.Open("mysql", "root@tcp(127.0.0.1:1)/game_db?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := NewGameServer(db)
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		query string
+		ctx   context.Context
+		want  int
+	}{
+		{"limit=0", context.Background(), http.StatusBadRequest},
+		{"limit=101", context.Background(), http.StatusBadRequest},
+		{"limit=ten", context.Background(), http.StatusBadRequest},
+		{"sort=losses", context.Background(), http.StatusBadRequest},
+		{"difficulty=extreme", context.Background(), http.StatusBadRequest},
+		{"room=r1", context.Background(), http.StatusInternalServerError},
+		{"room=r1", expired, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Error string `json:"error"`
+		}
+		if got := getLeaderboard(t, s, tt.ctx, tt.query, &resp); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.query, got, tt.want)
+		}
+		if resp.Error == "" {
+			t.Errorf("%s: no error message in the body", tt.query)
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/gorilla/websocket"
 )
 
@@ -17,34 +23,713 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// 猜数字的范围
+const (
+	defaultMin = 1
+	defaultMax = 100
+	rangeLimit = 1000000 // 范围的上下限都不能超过它
+)
+
+// 每轮猜测次数的上限，0表示不限
+const attemptsLimit = 10000
+
+// 发送队列
+const (
+	sendQueueSize = 256              // 每个连接的发送队列长度
+	sendStall     = 5 * time.Second  // 队列持续满超过这个时间就断开
+	writeWait     = 10 * time.Second // 单条消息的写超时
+)
+
+// 心跳：writePump定期发送ping控制帧，收到pong时延长读超时。客户端掉线后读超时到期，
+// 读循环退出并按断线处理，不会一直挂在房间里。是变量以便测试时调短
+var (
+	pingPeriod = 20 * time.Second
+	pongWait   = 45 * time.Second // 超过这么久没收到pong就断开，需大于pingPeriod
+)
+
 type Player struct {
-	id   string
+	id   string // 连接ID，作为players的key，房间内不会重复
+	name string // 显示名，用于广播和game_results
+	seq  int    // 加入顺序
+	lang string // 消息语言，见lang.go
 	conn *websocket.Conn
+	ping time.Duration // ping间隔，创建时取pingPeriod
+	// 会话令牌，断线后凭它重连；awayTimer是断线保留期的计时器，只在房间锁内访问
+	token     string
+	awayTimer *time.Timer
+	// 广播、计时器和读循环都通过out发消息，只有writePump写连接
+	out       chan []byte
+	final     chan []byte   // 关闭帧，writePump写完队列中的消息后发送
+	done      chan struct{} // 连接关闭时关闭
+	flushed   chan struct{} // writePump退出时关闭
+	closeOnce sync.Once
+	mu        sync.Mutex
+	fullSince time.Time // 队列开始持续满的时间
+}
+
+// 创建连接对应的玩家并设置读超时，pong到来时续期。
+// 心跳间隔在这里取一次，之后测试修改pingPeriod和pongWait不影响已有连接
+func newPlayer(id, name string, seq int, conn *websocket.Conn, lang string) *Player {
+	wait := pongWait
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+	return &Player{
+		id:      id,
+		name:    name,
+		seq:     seq,
+		lang:    lang,
+		conn:    conn,
+		ping:    pingPeriod,
+		out:     make(chan []byte, sendQueueSize),
+		final:   make(chan []byte, 1),
+		done:    make(chan struct{}),
+		flushed: make(chan struct{}),
+	}
+}
+
+// 按玩家的语言给他发送一条消息
+func (p *Player) send(m any) {
+	p.write(render(m, p.lang))
+}
+
+// 把已编码的消息放进发送队列，不会阻塞；队列满时丢弃消息，持续满超过sendStall则断开
+func (p *Player) write(data []byte) {
+	if data == nil {
+		return
+	}
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	select {
+	case p.out <- data:
+		p.mu.Lock()
+		p.fullSince = time.Time{}
+		p.mu.Unlock()
+		return
+	default:
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	if p.fullSince.IsZero() {
+		p.fullSince = now
+	}
+	stalled := now.Sub(p.fullSince) > sendStall
+	p.mu.Unlock()
+	if stalled {
+		fmt.Println("发送队列持续已满，断开玩家:", p.name)
+		p.close()
+	}
+}
+
+// 关闭连接，读循环随之退出并处理离开；可以重复调用
+func (p *Player) close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.conn.Close()
+	})
+}
+
+// 写完队列中已有的消息后发送关闭帧并断开，不会阻塞
+func (p *Player) closeWith(code int, text string) {
+	select {
+	case p.final <- websocket.FormatCloseMessage(code, text):
+	default:
+	}
+}
+
+// 把发送队列写到连接并定时发送ping，连接关闭或发送关闭帧后退出
+func (p *Player) writePump() {
+	defer close(p.flushed)
+	ticker := time.NewTicker(p.ping)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				p.close()
+				return
+			}
+		case data := <-p.out:
+			if !p.writeText(data) {
+				return
+			}
+		case frame := <-p.final:
+			for len(p.out) > 0 {
+				if !p.writeText(<-p.out) {
+					return
+				}
+			}
+			p.conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(writeWait))
+			p.close()
+			return
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// 写一条文本消息，失败时关闭连接并返回false，只在writePump中调用
+func (p *Player) writeText(data []byte) bool {
+	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := p.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		p.close()
+		return false
+	}
+	return true
 }
 
 type Room struct {
 	name    string
 	players map[string]*Player
-	lock    sync.RWMutex
+	// 观战连接，只接收广播
+	watchers map[string]*Player
+	lock     sync.RWMutex
+	// 玩法，创建时确定。secret是猜数字的答案，code是数字牛的答案，hangman是猜单词的本轮状态
+	game    string
 	secret  int
-	db      *sql.DB
+	code    string
+	hangman *hangman
+	// 猜单词玩法的词库和每轮允许的猜错次数
+	words     []string
+	maxMisses int
+	min       int // 本房间的数字范围，创建时确定
+	max       int
+	// 创建时选择的难度，范围和次数以min、max、maxAttempts为准
+	difficulty Difficulty
+	// 每轮所有玩家合计的猜测次数上限，0表示不限；attempts为本轮已用次数
+	maxAttempts int
+	attempts    int
+	// 本轮ID（开始时的纳秒时间戳，与房间名一起唯一确定一轮）和每位玩家本轮的猜测次数
+	roundID int64
+	guesses map[string]int
+	// 房间创建以来的轮数和本轮计时的起点，用于房间列表
+	round        int
+	roundStarted time.Time
+	// 房间最近一次变空的时间，有人时无意义
+	emptySince time.Time
+	// 当前连胜的玩家（显示名）和连胜局数
+	streakName  string
+	streakCount int
+	// 每位玩家（显示名）在本房间的累计积分
+	points map[string]int
+	mode   string
+	// 是否把每次猜测的结果广播给所有人，关闭时只有猜测者看到（猜单词玩法总是广播）
+	sharedInfo bool
+	// 每轮的时间限制，0表示不限；计时器在有人时运行，序号用法同回合计时器
+	roundTime  time.Duration
+	roundTimer *time.Timer
+	roundSeq   int
+	// 一轮结束后的倒计时，0表示立即开始下一轮；paused为true时正在倒计时，不接受猜测
+	countdown      time.Duration
+	paused         bool
+	countdownLeft  int
+	countdownTimer *time.Timer
+	countdownSeq   int
+	// 回合制状态：按加入顺序排列的玩家ID、当前回合下标和回合计时器
+	order     []*Player
+	turn      int
+	turnTimer *time.Timer
+	turnSeq   int
+	nextID    int // 玩家编号计数，避免有人离开后编号重复
+	// 断线保留期内的玩家，key是会话令牌，见detach
+	away     map[string]*Player
+	closed   bool   // 已经关闭，见Close
+	passHash []byte // 口令的SHA-256，公开房间为nil，创建后不变
+	// 玩家和观战者的人数上限，创建时确定
+	maxPlayers    int
+	maxSpectators int
+	results       *resultWriter
+	server        *GameServer // 房间空了时由服务器移除，见removeIfEmpty
+}
+
+// 房间玩法
+const (
+	gameNumber = "number" // 猜数字，猜测后提示大了还是小了
+	gameDigits = "digits" // 数字牛，猜4位各不相同的数字，提示xAyB
+	gameWord   = "word"   // 猜单词，全房间共用猜错次数
+)
+
+// 创建房间时的参数，加入已有房间时被忽略，房间空了被移除后才能换成新的参数
+type roomOptions struct {
+	game          string
+	maxMisses     int
+	difficulty    Difficulty
+	min, max      int
+	maxAttempts   int
+	mode          string
+	sharedInfo    bool
+	roundTime     time.Duration
+	countdown     time.Duration
+	maxPlayers    int
+	maxSpectators int
+	passHash      []byte
 }
 
 type GameServer struct {
-	rooms map[string]*Room
-	lock  sync.RWMutex
-	db    *sql.DB
+	rooms    map[string]*Room
+	lock     sync.RWMutex
+	db       *sql.DB
+	dbHealth dbHealth
+	results  *resultWriter
+	words    []string      // 猜单词玩法的词库
+	emptyTTL time.Duration // 空房间在房间列表中保留的时间
+	closing  bool          // 正在关闭，不再创建房间
 }
 
 func NewGameServer(db *sql.DB) *GameServer {
 	return &GameServer{
-		rooms: make(map[string]*Room),
-		db:    db,
+		rooms:    make(map[string]*Room),
+		db:       db,
+		results:  newResultWriter(db),
+		words:    defaultWords,
+		emptyTTL: defaultEmptyRoomTTL,
 	}
 }
 
-// 修复：getRoom 需要写锁创建房间，读锁只用于查找
-func (s *GameServer) getRoom(name string) *Room {
+// 从查询参数解析房间参数：?difficulty=easy|normal|hard 难度预设，
+// ?min=&max= 数字范围，?attempts=20 每轮猜测次数上限（0表示不限），后两者会覆盖难度中的设置，
+// ?mode=turns 回合制，?mode=digits 数字牛玩法，?mode=word 猜单词玩法（回合制可以与玩法同时给出），
+// ?misses=6 猜单词每轮允许的猜错次数，?round=120 每轮限时秒数（0表示不限），
+// ?sharedinfo=0 猜测结果只发给猜测者，?countdown=5 每轮结束后到下一轮开始的秒数（0表示立即开始），
+// ?maxplayers=10 玩家人数上限，?maxspectators=50 观战人数上限，?pass= 私人房间的口令
+func parseRoomOptions(c *gin.Context) roomOptions {
+	d := lookupDifficulty(c.Query("difficulty"))
+	opts := roomOptions{
+		game:          gameNumber,
+		maxMisses:     defaultMisses,
+		difficulty:    d,
+		maxAttempts:   d.Attempts,
+		mode:          modeFree,
+		sharedInfo:    c.Query("sharedinfo") != "0",
+		roundTime:     defaultRoundTime,
+		countdown:     defaultCountdown,
+		maxPlayers:    defaultMaxPlayers,
+		maxSpectators: defaultMaxSpectators,
+		passHash:      hashPass(c.Query("pass")),
+	}
+	if v, err := strconv.Atoi(c.Query("round")); err == nil && v >= 0 {
+		opts.roundTime = min(time.Duration(v)*time.Second, maxRoundTime)
+	}
+	if v, err := strconv.Atoi(c.Query("countdown")); err == nil && v >= 0 {
+		opts.countdown = min(time.Duration(v)*time.Second, maxCountdown)
+	}
+	if v, err := strconv.Atoi(c.Query("maxplayers")); err == nil && v > 0 {
+		opts.maxPlayers = min(v, playersLimit)
+	}
+	if v, err := strconv.Atoi(c.Query("maxspectators")); err == nil && v >= 0 {
+		opts.maxSpectators = min(v, spectatorsLimit)
+	}
+	for _, m := range c.QueryArray("mode") {
+		switch m {
+		case modeTurns:
+			opts.mode = modeTurns
+		case gameDigits, gameWord:
+			opts.game = m
+		}
+	}
+	if v, err := strconv.Atoi(c.Query("misses")); err == nil && v > 0 {
+		opts.maxMisses = min(v, missesLimit)
+	}
+	opts.min, opts.max = parseRange(c.Query("min"), c.Query("max"), d.Min, d.Max)
+	if v, err := strconv.Atoi(c.Query("attempts")); err == nil && v >= 0 {
+		opts.maxAttempts = min(v, attemptsLimit)
+	}
+	return opts
+}
+
+// 从查询参数解析数字范围（?min=1&max=1000），没给出的一端使用[defLo, defHi]中的值，
+// 超出[1, rangeLimit]的值会被截断，下限不小于上限时使用[defLo, defHi]
+func parseRange(minStr, maxStr string, defLo, defHi int) (int, int) {
+	lo, hi := defLo, defHi
+	if v, err := strconv.Atoi(minStr); err == nil {
+		lo = clamp(v, 1, rangeLimit)
+	}
+	if v, err := strconv.Atoi(maxStr); err == nil {
+		hi = clamp(v, 1, rangeLimit)
+	}
+	if lo >= hi {
+		return defLo, defHi
+	}
+	return lo, hi
+}
+
+// 把v限制在[lo, hi]范围内
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// 在房间的范围内生成新的答案
+func (r *Room) newSecret() int {
+	return r.min + rand.Intn(r.max-r.min+1)
+}
+
+// 开始新的一轮：换答案、清零猜测次数并重新计时，调用方需持有房间写锁
+func (r *Room) newRound() {
+	switch r.game {
+	case gameDigits:
+		r.code = newCode()
+	case gameWord:
+		r.hangman = newHangman(r.words[rand.Intn(len(r.words))], r.maxMisses)
+	default:
+		r.secret = r.newSecret()
+	}
+	r.attempts = 0
+	r.round++
+	r.roundID = time.Now().UnixNano()
+	r.guesses = make(map[string]int)
+	r.resetRoundTimer()
+}
+
+// 本轮的答案，用于公布，调用方需持有房间锁
+func (r *Room) answer() any {
+	switch r.game {
+	case gameDigits:
+		return r.code
+	case gameWord:
+		return r.hangman.word
+	default:
+		return r.secret
+	}
+}
+
+// 提示玩家该猜什么，调用方需持有房间锁
+func (r *Room) prompt() text {
+	switch r.game {
+	case gameDigits:
+		return tr("prompt.digits", digitsLen)
+	case gameWord:
+		return tr("prompt.word", r.hangman.text())
+	default:
+		return tr("prompt.number", r.min, r.max)
+	}
+}
+
+// 消息中的数字范围，只有猜数字玩法有范围
+func (r *Room) rangeField() []int {
+	if r.game != gameNumber {
+		return nil
+	}
+	return []int{r.min, r.max}
+}
+
+// 消息中的单词状态，只有猜单词玩法有，调用方需持有房间锁
+func (r *Room) wordField() *wordState {
+	if r.game != gameWord {
+		return nil
+	}
+	return r.hangman.state()
+}
+
+// 本轮剩余次数，不限次数时为nil，调用方需持有房间锁
+func (r *Room) remaining() *int {
+	if r.maxAttempts == 0 {
+		return nil
+	}
+	n := r.maxAttempts - r.attempts
+	return &n
+}
+
+// 反馈文字后附带的剩余次数
+func remainingText(left *int) text {
+	if left == nil {
+		return nil
+	}
+	return tr("guess.remaining", *left)
+}
+
+// 新一轮开始的消息
+func (r *Room) roundStartMessage() roundStartMsg {
+	return roundStartMsg{
+		baseMsg:    base(msgRoundStart, tr("round.start", r.prompt(), r.difficultyText())),
+		Difficulty: r.difficulty.Name,
+		Game:       r.game,
+		Range:      r.rangeField(),
+		Word:       r.wordField(),
+		Attempts:   r.maxAttempts,
+		TimeLimit:  int(r.roundTime / time.Second),
+	}
+}
+
+// 消息中注明的难度，未选择难度时为空
+func (r *Room) difficultyText() text {
+	if r.difficulty.Name == "" {
+		return nil
+	}
+	return tr("difficulty", tr("difficulty."+r.difficulty.Name))
+}
+
+// 发给新玩家的欢迎消息，调用方需持有房间锁
+func (r *Room) welcomeMessage(player *Player) welcomeMsg {
+	turn := r.currentTurnName()
+	m := welcomeMsg{
+		Room:       r.name,
+		Player:     player.name,
+		Difficulty: r.difficulty.Name,
+		Game:       r.game,
+		Range:      r.rangeField(),
+		Word:       r.wordField(),
+		Attempts:   r.maxAttempts,
+		TimeLimit:  int(r.roundTime / time.Second),
+		Mode:       r.mode,
+		SharedInfo: r.sharedInfo,
+		Turn:       turn,
+	}
+	m.baseMsg = base(msgWelcome, tr("welcome", r.name, player.name, r.prompt(), r.difficultyText()))
+	if r.maxAttempts > 0 {
+		m.add(tr("welcome.attempts", r.maxAttempts))
+	}
+	if r.roundTime > 0 {
+		m.add(tr("welcome.time", m.TimeLimit))
+	}
+	if r.mode == modeTurns {
+		m.add(tr("welcome.turns", turn))
+	}
+	if !r.sharedInfo && r.game != gameWord {
+		m.add(tr("welcome.private"))
+	}
+	if r.paused {
+		m.add(tr("welcome.countdown", r.countdownLeft))
+	}
+	return m
+}
+
+// 一轮结束时的快照：轮次ID、轮数、答案、全房间的猜测次数和在场每位玩家（按显示名）本轮的猜测次数，
+// 有人猜对时还有赢家、他的连胜局数和本轮得分；reason为结束原因，由调用方填写
+type roundResult struct {
+	id       int64
+	round    int
+	answer   any
+	attempts int
+	guesses  map[string]int
+	winner   string
+	streak   int
+	points   int
+	reason   string
+}
+
+// 记下本轮在场玩家的猜测次数，没猜过的玩家记为0，调用方需持有房间锁
+func (r *Room) snapshotRound() roundResult {
+	rr := roundResult{
+		id:       r.roundID,
+		round:    r.round,
+		answer:   r.answer(),
+		attempts: r.attempts,
+		guesses:  make(map[string]int, len(r.players)),
+	}
+	for id, p := range r.players {
+		rr.guesses[p.name] = r.guesses[id]
+	}
+	return rr
+}
+
+// 把一轮的结果写入数据库：每位玩家一行game_results，整轮一行guess_rounds，
+// result给出每位玩家的结果
+func (r *Room) saveRound(rr roundResult, result func(name string) string) {
+	r.results.enqueue(r.roundRow(rr))
+	for name, n := range rr.guesses {
+		streak, points := 0, 0
+		if name == rr.winner {
+			streak, points = rr.streak, rr.points
+		}
+		r.saveResult(rr.id, name, result(name), n, streak, points)
+	}
+}
+
+// 一次猜测的判定结果
+type verdict struct {
+	feedback  feedbackMsg // 发给猜测者的反馈，剩余次数由guess补上
+	solved    bool
+	failed    bool // 本轮失败，所有玩家记为输（猜单词玩法的猜错次数用完）
+	counted   bool // 不计入次数的猜测（如超出范围）只回复猜测者
+	broadcast bool // 反馈发给所有人，否则只发给猜测者
+}
+
+// 猜数字玩法的判定，调用方需持有房间锁
+func (r *Room) judgeNumber(input string) (verdict, bool) {
+	n, err := parseNumber(input)
+	if err != nil {
+		return verdict{}, false
+	}
+	if n < r.min || n > r.max {
+		return verdict{feedback: feedbackMsg{
+			baseMsg: base(msgFeedback, tr("guess.out_of_range", r.min, r.max)),
+			Result:  resultOutOfRange,
+			Guess:   n,
+		}}, true
+	}
+	if n == r.secret {
+		return verdict{feedback: feedbackMsg{Result: resultCorrect, Guess: n}, solved: true, counted: true}, true
+	}
+	fb := feedbackMsg{baseMsg: base(msgFeedback, tr("guess.low")), Result: resultLow, Guess: n}
+	if n > r.secret {
+		fb.Result = resultHigh
+		fb.baseMsg = base(msgFeedback, tr("guess.high"))
+	}
+	if r.difficulty.Hints {
+		fb.Hint = hotCold(n, r.secret, r.min, r.max)
+		fb.add(tr("clause", tr("hint."+fb.Hint)))
+	}
+	return verdict{feedback: fb, counted: true}, true
+}
+
+// 按房间玩法判定一次猜测，输入无法解析时返回false，调用方需持有房间锁
+func (r *Room) judge(player *Player, input string) (verdict, bool) {
+	switch r.game {
+	case gameDigits:
+		return r.judgeDigits(input)
+	case gameWord:
+		return r.judgeWord(player, input)
+	default:
+		return r.judgeNumber(input)
+	}
+}
+
+// 输入无法解析时的提示
+func (r *Room) invalidText() text {
+	switch r.game {
+	case gameDigits:
+		return tr("error.invalid_digits", digitsLen)
+	case gameWord:
+		return tr("error.invalid_word")
+	default:
+		return tr("error.invalid_number")
+	}
+}
+
+// 处理一次猜测。轮次状态在房间锁内更新，广播和写库放在解锁之后
+func (r *Room) guess(player *Player, input string) {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return
+	}
+	if r.paused {
+		r.lock.Unlock()
+		player.send(errorMsg{baseMsg: base(msgError, tr("error.round_not_started")), Code: errRoundNotStarted})
+		return
+	}
+	if r.mode == modeTurns && r.currentTurn() != player {
+		r.lock.Unlock()
+		player.send(errorMsg{baseMsg: base(msgError, tr("error.not_your_turn")), Code: errNotYourTurn})
+		return
+	}
+	v, ok := r.judge(player, input)
+	if !ok {
+		body := r.invalidText()
+		r.lock.Unlock()
+		player.send(errorMsg{baseMsg: base(msgError, body), Code: errInvalidGuess})
+		return
+	}
+	feedback := v.feedback
+	if !v.counted {
+		// 不计入次数
+		feedback.Remaining = r.remaining()
+		feedback.add(remainingText(feedback.Remaining))
+		r.lock.Unlock()
+		player.send(feedback)
+		return
+	}
+	if r.sharedInfo && !v.broadcast {
+		// 公开房间里每次计入次数的猜测都广播给所有人，大家可以一起缩小范围
+		v.broadcast = true
+		feedback.Player = player.name
+		feedback.body = tr("guess.by", player.name, feedback.Guess, feedback.body)
+	}
+	r.attempts++
+	r.guesses[player.id]++
+	answer := r.answer()
+	next := r.nextTurn()
+	if v.solved {
+		rr := r.snapshotRound()
+		rr.winner, rr.streak = player.name, r.recordWin(player.name)
+		rr.reason = reasonSolved
+		rr.points = roundPoints(rr.guesses[player.name])
+		r.addPoints(player.name, rr.points)
+		left := r.remaining()
+		scores := r.scoreboardMessage()
+		start := r.nextRound()
+		r.lock.Unlock()
+
+		feedback.baseMsg = base(msgFeedback, tr("guess.solved",
+			player.name, answer, rr.points, streakText(player.name, rr.streak)))
+		feedback.Player = player.name
+		feedback.Remaining = left
+		feedback.Streak = rr.streak
+		feedback.Points = rr.points
+		r.broadcast(feedback)
+		r.broadcast(scores)
+		// 记录结果到数据库
+		r.saveRound(rr, func(name string) string {
+			if name == player.name {
+				return "win"
+			}
+			return "lose"
+		})
+		r.broadcast(start)
+		r.announceTurn(next)
+		return
+	}
+
+	feedback.Remaining = r.remaining()
+	feedback.add(remainingText(feedback.Remaining))
+	reply := player.send
+	if v.broadcast {
+		reply = func(m any) { r.broadcast(m) }
+	}
+	var end roundEndMsg
+	var result string
+	switch {
+	case v.failed:
+		// 猜错次数用完，公布答案，所有玩家记为输
+		end = roundEndMsg{
+			baseMsg: base(msgRoundEnd, tr("round.out_of_misses", r.maxMisses, answer)),
+			Answer:  answer,
+			Reason:  reasonOutOfMisses,
+		}
+		result = "lose"
+	case r.maxAttempts > 0 && r.attempts >= r.maxAttempts:
+		// 次数用完，公布答案，所有玩家记为超时
+		end = roundEndMsg{
+			baseMsg: base(msgRoundEnd, tr("round.out_of_attempts", r.maxAttempts, answer)),
+			Answer:  answer,
+			Reason:  reasonOutOfAttempts,
+		}
+		result = "timeout"
+	default:
+		r.lock.Unlock()
+		reply(feedback)
+		r.announceTurn(next)
+		return
+	}
+
+	rr := r.snapshotRound()
+	rr.reason = end.Reason
+	scores := r.scoreboardMessage()
+	start := r.nextRound()
+	r.lock.Unlock()
+	reply(feedback)
+	r.broadcast(end)
+	r.broadcast(scores)
+	r.saveRound(rr, func(string) string { return result })
+	r.broadcast(start)
+	r.announceTurn(next)
+}
+
+// 修复：getRoom 需要写锁创建房间，读锁只用于查找；参数只在创建房间时生效，
+// 房间空了会被移除（见removeIfEmpty），之后同名房间按新的参数创建。服务器正在关闭时返回nil
+func (s *GameServer) getRoom(name string, opts roomOptions) *Room {
 	s.lock.RLock()
 	room, exists := s.rooms[name]
 	s.lock.RUnlock()
@@ -53,15 +738,37 @@ func (s *GameServer) getRoom(name string) *Room {
 	}
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if s.closing {
+		return nil
+	}
 	// 再次检查，防止并发重复创建
 	room, exists = s.rooms[name]
 	if !exists {
 		room = &Room{
-			name:    name,
-			players: make(map[string]*Player),
-			secret:  rand.Intn(100) + 1,
-			db:      s.db,
+			name:          name,
+			game:          opts.game,
+			players:       make(map[string]*Player),
+			watchers:      make(map[string]*Player),
+			away:          make(map[string]*Player),
+			points:        make(map[string]int),
+			difficulty:    opts.difficulty,
+			min:           opts.min,
+			max:           opts.max,
+			maxAttempts:   opts.maxAttempts,
+			mode:          opts.mode,
+			sharedInfo:    opts.sharedInfo,
+			roundTime:     opts.roundTime,
+			countdown:     opts.countdown,
+			maxPlayers:    opts.maxPlayers,
+			maxSpectators: opts.maxSpectators,
+			passHash:      opts.passHash,
+			words:         s.words,
+			maxMisses:     opts.maxMisses,
+			emptySince:    time.Now(),
+			results:       s.results,
+			server:        s,
 		}
+		room.newRound()
 		s.rooms[name] = room
 	}
 	return room
@@ -69,29 +776,99 @@ func (s *GameServer) getRoom(name string) *Room {
 
 func (s *GameServer) handleConnections(c *gin.Context) {
 	roomName := c.Param("room")
-	room := s.getRoom(roomName)
+	lang := parseLang(c.Query("lang"))
+	name, err := cleanName(c.Query("name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts := parseRoomOptions(c)
+	room := s.getRoom(roomName, opts)
+	if room == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server shutting down"})
+		return
+	}
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		fmt.Println("Upgrade error:", err)
+		// 刚创建的房间没人加入，不留下
+		s.removeIfEmpty(room)
+		return
+	}
+	if slices.Contains(c.QueryArray("mode"), modeSpectator) {
+		s.spectate(room, opts, conn, name, c.Query("pass"), lang)
 		return
 	}
 
-	playerID := fmt.Sprintf("P%d", len(room.players)+1)
-	player := &Player{id: playerID, conn: conn}
-	room.lock.Lock()
-	room.players[playerID] = player
+	if room = s.lockRoom(room, opts); room == nil {
+		rejectClosed(conn)
+		return
+	}
+	if !room.checkPass(c.Query("pass")) {
+		room.lock.Unlock()
+		rejectWrongPass(conn, lang)
+		return
+	}
+	// ?resume=<token> 在保留期内接回断线的玩家，令牌无效时按新玩家加入
+	player := room.resume(c.Query("resume"), conn, lang)
+	resumed := player != nil
+	var first *Player
+	if resumed {
+		first = room.resumeTurns()
+	} else {
+		if len(room.players) >= room.maxPlayers {
+			limit := room.maxPlayers
+			room.lock.Unlock()
+			rejectFull(conn, lang, tr("error.room_full", limit), limit)
+			return
+		}
+		room.nextID++
+		playerID := fmt.Sprintf("P%d", room.nextID)
+		if name == "" {
+			name = playerID
+		}
+		player = newPlayer(playerID, room.uniqueName(name), room.nextID, conn, lang)
+		player.token = newToken()
+		room.players[playerID] = player
+		first = room.joinTurns(player)
+		if len(room.players) == 1 {
+			// 房间从空到有人，本轮重新计时
+			room.resetRoundTimer()
+		}
+	}
+	welcome := room.welcomeMessage(player)
+	welcome.Token = player.token
+	welcome.Resumed = resumed
+	roster := room.roster()
+	spectators := len(room.watchers)
 	room.lock.Unlock()
 
-	room.broadcast(fmt.Sprintf("玩家 %s 加入了房间 %s，当前玩家数: %d", playerID, roomName, len(room.players)))
+	go player.writePump()
+	player.send(welcome)
+	if resumed {
+		// 重连不算离开和加入，只通知一声
+		room.broadcast(playerMsg{
+			baseMsg:    base(msgPlayerResumed, tr("player.resumed", player.name)),
+			Player:     player.name,
+			Count:      len(roster),
+			Players:    roster,
+			Spectators: spectators,
+		})
+	} else {
+		room.broadcast(playerMsg{
+			baseMsg: base(msgPlayerJoined, tr("player.joined",
+				player.name, roomName, len(roster), list(roster), spectatorText(spectators))),
+			Player:     player.name,
+			Count:      len(roster),
+			Players:    roster,
+			Spectators: spectators,
+		})
+	}
+	room.announceTurn(first)
 
 	go func() {
-		defer func() {
-			room.lock.Lock()
-			delete(room.players, playerID)
-			room.lock.Unlock()
-			conn.Close()
-			room.broadcast(fmt.Sprintf("玩家 %s 离开了房间 %s，当前玩家数: %d", playerID, roomName, len(room.players)))
-		}()
+		// 断线后先保留玩家，保留期结束仍未重连才离开
+		defer room.detach(player)
 
 		for {
 			_, msg, err := conn.ReadMessage()
@@ -99,50 +876,75 @@ func (s *GameServer) handleConnections(c *gin.Context) {
 				fmt.Println("Read error:", err)
 				break
 			}
-			var guess int
-			// 修复：使用 fmt.Sscanf 而不是 fmt.Scanf
-			_, err = fmt.Sscanf(string(msg), "%d", &guess)
+			guess, err := decodeGuess(msg)
 			if err != nil {
-				player.conn.WriteMessage(websocket.TextMessage, []byte("请输入有效的数字"))
+				player.send(errorMsg{baseMsg: base(msgError, tr("error.bad_message")), Code: errInvalidGuess})
 				continue
 			}
 
-			if guess < room.secret {
-				player.conn.WriteMessage(websocket.TextMessage, []byte("太小了"))
-			} else if guess > room.secret {
-				player.conn.WriteMessage(websocket.TextMessage, []byte("太大了"))
-			} else {
-				result := fmt.Sprintf("玩家 %s 猜对了！答案是 %d", playerID, room.secret)
-				room.broadcast(result)
-				// 记录结果到数据库
-				room.saveResult(playerID, "win")
-				for _, p := range room.players {
-					if p.id != playerID {
-						room.saveResult(p.id, "lose")
-					}
-				}
-				// 新一轮开始，重置 secret
-				room.secret = rand.Intn(100) + 1
-				room.broadcast("新一轮开始！请继续猜数字")
-			}
+			room.guess(player, guess)
 		}
 	}()
 }
 
-func (r *Room) broadcast(msg string) {
+// 玩家离开房间：移出回合顺序，房间空了就停止计时，然后广播离开。玩家已不在房间时什么也不做
+func (r *Room) leave(p *Player) {
+	r.lock.Lock()
+	if r.players[p.id] != p {
+		r.lock.Unlock()
+		return
+	}
+	delete(r.players, p.id)
+	next := r.leaveTurns(p)
+	roster := r.roster()
+	spectators := len(r.watchers)
+	if len(roster) == 0 {
+		// 没人时停止计时，避免空房间的计时器一直运行；倒计时取消，直接换好下一轮的答案
+		r.stopCountdown(true)
+		r.resetRoundTimer()
+		r.emptySince = time.Now()
+	}
+	r.lock.Unlock()
+	r.broadcast(playerMsg{
+		baseMsg: base(msgPlayerLeft, tr("player.left",
+			p.name, r.name, len(roster), spectatorText(spectators))),
+		Player:     p.name,
+		Count:      len(roster),
+		Players:    roster,
+		Spectators: spectators,
+	})
+	r.announceTurn(next)
+	if len(roster) == 0 {
+		r.server.removeIfEmpty(r)
+	}
+}
+
+// 发给所有玩家和观战者，每人按自己的语言
+func (r *Room) broadcast(m any) {
+	enc := encodings{m: m}
 	r.lock.RLock()
 	defer r.lock.RUnlock()
 	for _, p := range r.players {
-		p.conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		p.write(enc.get(p.lang))
+	}
+	for _, w := range r.watchers {
+		w.write(enc.get(w.lang))
 	}
 }
 
-// 修复：SQL语句参数数量与字段数量一致
-func (r *Room) saveResult(playerID, result string) {
-	_, err := r.db.Exec("INSERT INTO game_results (player_id, room_name, result) VALUES (?, ?, ?)", playerID, r.name, result)
-	if err != nil {
-		fmt.Println("保存结果失败:", err)
-	}
+// 把一位玩家的结果交给异步写入队列，不会阻塞；streak和points只在赢家的行上记录
+func (r *Room) saveResult(roundID int64, name, result string, attempts, streak, points int) {
+	r.results.enqueue(resultRow{
+		room:       r.name,
+		roundID:    roundID,
+		name:       name,
+		result:     result,
+		attempts:   attempts,
+		difficulty: r.difficulty.Name,
+		game:       r.game,
+		streak:     streak,
+		points:     points,
+	})
 }
 
 func main() {
@@ -154,8 +956,63 @@ func main() {
 	}
 	defer db.Close()
 
-	r := gin.Default()
 	server := NewGameServer(db)
+	if server.words, err = loadWords(os.Getenv("WORD_LIST")); err != nil {
+		fmt.Fprintf(os.Stderr, "读取词库失败: %v\n", err)
+		os.Exit(1)
+	}
+	// 空房间在房间列表中保留的时间，如 EMPTY_ROOM_TTL=10m
+	if v := os.Getenv("EMPTY_ROOM_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl < 0 {
+			fmt.Fprintf(os.Stderr, "EMPTY_ROOM_TTL无效: %s\n", v)
+			os.Exit(1)
+		}
+		server.emptyTTL = ttl
+	}
+	// sql.Open不会真正连接，启动时先ping一次，连不上直接退出
+	if err := server.dbHealth.check(db, startupPingTimeout); err != nil {
+		addr := "?"
+		if cfg, perr := mysql.ParseDSN(dsn); perr == nil {
+			addr = cfg.Addr + "/" + cfg.DBName
+		}
+		fmt.Fprintf(os.Stderr, "无法连接数据库 %s: %v\n", addr, err)
+		os.Exit(1)
+	}
+	if err := migrate(db); err != nil {
+		fmt.Fprintf(os.Stderr, "初始化数据库表失败: %v\n", err)
+		os.Exit(1)
+	}
+	go server.dbHealth.run(db)
+	// 迁移完成后再开始写结果
+	go server.results.run()
+
+	r := gin.Default()
 	r.GET("/ws/:room", server.handleConnections)
-	r.Run(":8080")
+	r.GET("/api/leaderboard", server.leaderboard)
+	r.GET("/api/rooms", server.listRooms)
+	r.GET("/api/rooms/:room/rounds", server.roundHistory)
+	r.GET("/health", server.health)
+
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "监听失败: %v\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	// 收到SIGINT/SIGTERM后先停止接受新连接，再关闭房间并写完结果
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+	fmt.Println("正在关闭服务器...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println("关闭HTTP服务失败:", err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Println("关闭房间失败:", err)
+	}
 }
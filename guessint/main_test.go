@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// 只挂了WebSocket接口的测试服务器，不连数据库
+func newTestServer(t *testing.T) (*GameServer, *httptest.Server) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := NewGameServer(nil)
+	r := gin.New()
+	r.GET("/ws/:room", s.handleConnections)
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// 连接房间，query为 ?后面的参数
+func dial(t *testing.T, ts *httptest.Server, room, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/" + room + "?" + query
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// 读消息直到出现typ类型的一条并返回，其他消息跳过
+func expect(t *testing.T, c *websocket.Conn, typ string) map[string]any {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("bad message %s: %v", data, err)
+		}
+		if m["type"] == typ {
+			return m
+		}
+	}
+}
+
+// 有人离开后新加入的玩家不会拿到已有玩家的ID
+func TestPlayerIDsNotReused(t *testing.T) {
+	s, ts := newTestServer(t)
+	c1 := dial(t, ts, "ids", "")
+	p1 := expect(t, c1, msgWelcome)["player"]
+	c2 := dial(t, ts, "ids", "")
+	p2 := expect(t, c2, msgWelcome)["player"]
+
+	c1.Close()
+	c3 := dial(t, ts, "ids", "")
+	p3 := expect(t, c3, msgWelcome)["player"]
+	if p1 == p2 || p2 == p3 || p1 == p3 {
+		t.Fatalf("player IDs %v, %v, %v are not distinct", p1, p2, p3)
+	}
+
+	// P2的连接不受影响，仍能收到第三位玩家加入的广播（之前先收到自己加入的广播）
+	for expect(t, c2, msgPlayerJoined)["player"] != p3 {
+	}
+	room := s.rooms["ids"]
+	room.lock.RLock()
+	defer room.lock.RUnlock()
+	if p := room.players["P2"]; p == nil || p.name != p2 {
+		t.Errorf("players[P2] = %+v, want the second player", p)
+	}
+}
+
+// 不回应ping的客户端在读超时后按断线处理，持续读取（自动回pong）的客户端保持连接
+func TestKeepaliveDetachesSilentClient(t *testing.T) {
+	oldPing, oldPong := pingPeriod, pongWait
+	const wait = 100 * time.Millisecond
+	pingPeriod, pongWait = 20*time.Millisecond, wait
+	// 连接在newPlayer中取走间隔，之后即可恢复，其他测试的连接仍用默认值；
+	// 中途失败时由Cleanup恢复，不影响同一包中后面的测试
+	restore := func() { pingPeriod, pongWait = oldPing, oldPong }
+	t.Cleanup(restore)
+
+	s, ts := newTestServer(t)
+	dial(t, ts, "keepalive", "name=silent")
+	alive := dial(t, ts, "keepalive", "name=alive")
+	// gorilla/websocket在读取时自动回复pong，silent从不读取
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	room := s.rooms["keepalive"]
+	waitAway(t, room, 1)
+	room.lock.RLock()
+	n := len(room.players)
+	room.lock.RUnlock()
+	if n != 2 {
+		t.Fatalf("players = %d, want 2", n)
+	}
+	restore()
+	// 再等几个超时周期，确认持续回应的客户端没有被误判
+	time.Sleep(3 * wait)
+	room.lock.RLock()
+	defer room.lock.RUnlock()
+	for _, p := range room.players {
+		if away := room.isAway(p); away != (p.name == "silent") {
+			t.Errorf("%s: away = %v", p.name, away)
+		}
+	}
+}
+
+// 几位玩家连续快速猜测，同时不断有人加入离开、每几次猜测就换一轮产生广播：
+// 配合 -race 检查广播和直接回复都只经writePump写连接。每个客户端读到的帧都是完整的JSON，
+// 自己的每次猜测都收到反馈
+func TestRapidGuessesDuringBroadcasts(t *testing.T) {
+	s, ts := newTestServer(t)
+	// 每个客户端收到的帧：自己的反馈60条、约24轮各3条广播、加入离开40条，
+	// 总数小于发送队列长度，读得慢也不会因队列满丢消息
+	const players, guesses, churns = 4, 60, 20
+	query := "countdown=0&sharedinfo=0&attempts=10&maxplayers=100&name="
+	conns := make([]*websocket.Conn, players)
+	for i := range conns {
+		conns[i] = dial(t, ts, "rapid", query+fmt.Sprintf("p%d", i))
+		expect(t, conns[i], msgWelcome)
+	}
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/rapid?" + query + "churn"
+
+	// 反复加入离开，产生player_joined和player_left广播
+	churned := make(chan struct{})
+	go func() {
+		defer close(churned)
+		for i := 0; i < churns; i++ {
+			if c, _, err := websocket.DefaultDialer.Dial(url, nil); err == nil {
+				c.Close()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*players)
+	for i, c := range conns {
+		name := fmt.Sprintf("p%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < guesses; j++ {
+				if err := c.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(j%100+1))); err != nil {
+					errs <- fmt.Errorf("%s: write: %v", name, err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c.SetReadDeadline(time.Now().Add(10 * time.Second))
+			for own := 0; own < guesses; {
+				_, data, err := c.ReadMessage()
+				if err != nil {
+					errs <- fmt.Errorf("%s: got %d of %d feedbacks: %v", name, own, guesses, err)
+					return
+				}
+				var m map[string]any
+				if err := json.Unmarshal(data, &m); err != nil {
+					errs <- fmt.Errorf("%s: bad frame %q: %v", name, data, err)
+					return
+				}
+				// 猜中的反馈带上猜中者广播给所有人，其他反馈只发给猜测者
+				if m["type"] == msgFeedback && (m["player"] == nil || m["player"] == name) {
+					own++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	<-churned
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	room := s.rooms["rapid"]
+	room.lock.RLock()
+	defer room.lock.RUnlock()
+	if n := len(room.players); n < players {
+		t.Errorf("players = %d, want at least %d still connected", n, players)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// 服务器发出的消息类型
+const (
+	msgWelcome       = "welcome"
+	msgFeedback      = "feedback"
+	msgRoundStart    = "round_start"
+	msgRoundEnd      = "round_end"
+	msgRoundWarning  = "round_warning"
+	msgPlayerJoined  = "player_joined"
+	msgPlayerLeft    = "player_left"
+	msgPlayerResumed = "player_resumed" // 断线的玩家在保留期内重新连接
+	msgTurn          = "turn"
+	msgTurnTimeout   = "turn_timeout"
+	msgScoreboard    = "scoreboard"
+	msgServerClosing = "server_closing"
+	msgCountdown     = "countdown"
+	msgError         = "error"
+)
+
+// 猜测结果
+const (
+	resultLow        = "low"
+	resultHigh       = "high"
+	resultCorrect    = "correct"
+	resultOutOfRange = "out_of_range"
+	resultMiss       = "miss"     // 数字牛玩法中没有全中的猜测，猜单词玩法中猜错的字母或单词
+	resultHit        = "hit"      // 猜单词玩法中猜中的字母
+	resultRepeated   = "repeated" // 猜单词玩法中已经猜过的字母，不计入次数
+)
+
+// 一轮没人猜对而结束的原因
+const (
+	reasonOutOfAttempts = "out_of_attempts"
+	reasonTimeUp        = "time_up"
+	reasonOutOfMisses   = "out_of_misses" // 猜单词玩法中猜错次数用完
+	reasonClosed        = "closed"        // 房间关闭时进行中的一轮，只记入guess_rounds
+)
+
+// 错误码
+const (
+	errInvalidGuess    = "invalid_guess"
+	errNotYourTurn     = "not_your_turn"
+	errSpectator       = "spectator" // 观战连接发来了消息
+	errRoundNotStarted = "round_not_started"
+	errRoomFull        = "room_full"
+	errWrongPass       = "wrong_pass"
+)
+
+// 所有服务器消息共有的字段，text是给只显示文字的简单客户端准备的描述，
+// 发送时由body按接收者的语言渲染
+type baseMsg struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	body text
+}
+
+// 生成消息头
+func base(typ string, body text) baseMsg {
+	return baseMsg{Type: typ, body: body}
+}
+
+// 在文本后面追加一段
+func (b *baseMsg) add(t text) {
+	b.body = append(b.body, t...)
+}
+
+// 把文本渲染成lang语言，只在render的副本上调用
+func (b *baseMsg) localize(lang string) {
+	b.Text = b.body.render(lang)
+}
+
+// 加入房间后发给本人的欢迎消息
+type welcomeMsg struct {
+	baseMsg
+	Room       string     `json:"room"`
+	Player     string     `json:"player"`
+	Difficulty string     `json:"difficulty,omitempty"` // 难度名，未选择时省略
+	Game       string     `json:"game"`
+	Range      []int      `json:"range,omitempty"`      // 数字玩法的范围，其他玩法省略
+	Word       *wordState `json:"word,omitempty"`       // 猜单词玩法的当前状态
+	Attempts   int        `json:"attempts,omitempty"`   // 每轮次数上限，不限时省略
+	TimeLimit  int        `json:"time_limit,omitempty"` // 每轮限时秒数，不限时省略
+	Mode       string     `json:"mode"`
+	SharedInfo bool       `json:"shared_info"`         // 猜测结果是否广播给所有人
+	Turn       string     `json:"turn,omitempty"`      // 回合制下当前回合的玩家
+	Spectator  bool       `json:"spectator,omitempty"` // 观战连接
+	Token      string     `json:"token,omitempty"`     // 会话令牌，断线后用 ?resume= 带上它重连
+	Resumed    bool       `json:"resumed,omitempty"`   // 本次连接接回了断线前的玩家
+}
+
+// 猜测结果，公开房间和猜对时广播给所有人并带上猜测的玩家
+type feedbackMsg struct {
+	baseMsg
+	Result    string        `json:"result"`
+	Guess     any           `json:"guess"` // 数字玩法为数字，其他玩法为字符串
+	Player    string        `json:"player,omitempty"`
+	Remaining *int          `json:"remaining,omitempty"` // 本轮剩余次数，不限次数时省略
+	Hint      string        `json:"hint,omitempty"`      // 简单难度的冷热提示：hot、warm、cold
+	Digits    *digitsResult `json:"digits,omitempty"`    // 数字牛玩法的xAyB
+	Word      *wordState    `json:"word,omitempty"`      // 猜单词玩法猜测后的状态
+	Streak    int           `json:"streak,omitempty"`    // 猜对时赢家的连胜局数
+	Points    int           `json:"points,omitempty"`    // 猜对时赢家本轮的得分
+}
+
+// 数字牛玩法的反馈：a为位置和数字都对的个数，b为数字对但位置不对的个数
+type digitsResult struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+// 猜单词玩法的状态：mask中没猜到的字母为_，letters为按字母表排列的已猜字母，猜错次数全房间共用
+type wordState struct {
+	Mask      string `json:"mask"`
+	Letters   string `json:"letters"`
+	Misses    int    `json:"misses"`
+	MaxMisses int    `json:"max_misses"`
+}
+
+// 新一轮开始
+type roundStartMsg struct {
+	baseMsg
+	Difficulty string     `json:"difficulty,omitempty"`
+	Game       string     `json:"game"`
+	Range      []int      `json:"range,omitempty"`
+	Word       *wordState `json:"word,omitempty"`
+	Attempts   int        `json:"attempts,omitempty"`
+	TimeLimit  int        `json:"time_limit,omitempty"`
+}
+
+// 下一轮开始前的倒计时，每秒一条
+type countdownMsg struct {
+	baseMsg
+	RemainingSec int `json:"remaining_sec"`
+}
+
+// 本轮时间快到了
+type roundWarningMsg struct {
+	baseMsg
+	RemainingSec int `json:"remaining_sec"`
+}
+
+// 没人猜对而结束的一轮，公布答案
+type roundEndMsg struct {
+	baseMsg
+	Answer any    `json:"answer"` // 数字玩法为数字，其他玩法为字符串
+	Reason string `json:"reason"`
+}
+
+// 与某个玩家有关的通知：加入、离开、轮到谁、谁超时，player为显示名
+type playerMsg struct {
+	baseMsg
+	Player     string   `json:"player"`
+	Count      int      `json:"count,omitempty"`      // 加入和离开时的当前玩家数，不含观战者
+	Players    []string `json:"players,omitempty"`    // 加入和离开时按加入顺序的全部玩家
+	Spectators int      `json:"spectators,omitempty"` // 加入和离开时的观战人数
+}
+
+// 只发给本人的错误提示
+type errorMsg struct {
+	baseMsg
+	Code string `json:"code"`
+}
+
+// 客户端发来的JSON消息：{"type":"guess","guess":42}，guess也可以是字符串，如"0123"、"e"
+type clientMsg struct {
+	Type  string          `json:"type"`
+	Guess json.RawMessage `json:"guess"`
+}
+
+// 把消息编码成JSON，失败时返回nil
+func marshal(m any) []byte {
+	data, err := json.Marshal(m)
+	if err != nil {
+		fmt.Println("消息编码失败:", err)
+		return nil
+	}
+	return data
+}
+
+// 取出客户端发来的猜测文本，支持JSON消息和老客户端的纯文本，由各玩法自己解析
+func decodeGuess(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return string(trimmed), nil
+	}
+	var m clientMsg
+	if err := json.Unmarshal(trimmed, &m); err != nil {
+		return "", err
+	}
+	if m.Type != "guess" {
+		return "", fmt.Errorf("unknown message type %q", m.Type)
+	}
+	if len(m.Guess) == 0 || string(m.Guess) == "null" {
+		return "", errors.New("missing guess")
+	}
+	// 字符串按内容取出，数字保留原文，数字牛玩法的"0123"不会丢掉前导0
+	var s string
+	if err := json.Unmarshal(m.Guess, &s); err == nil {
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(m.Guess, &n); err != nil {
+		return "", fmt.Errorf("bad guess %s", m.Guess)
+	}
+	return n.String(), nil
+}
+
+// 把猜测文本解析成数字
+func parseNumber(s string) (int, error) {
+	var n int
+	// 修复：使用 fmt.Sscanf 而不是 fmt.Scanf
+	if _, err := fmt.Sscanf(s, "%d", &n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
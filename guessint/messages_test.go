@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// JSON消息和老客户端的纯文本都能取出猜测
+func TestDecodeGuess(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"42", "42", false},
+		{"  42\n", "42", false},
+		{"e", "e", false},
+		{`{"type":"guess","guess":42}`, "42", false},
+		{`{"type":"guess","guess":"0123"}`, "0123", false},
+		{`{"type":"guess","guess":"apple"}`, "apple", false},
+		{`{"type":"guess","guess":-7}`, "-7", false},
+		{`{"type":"chat","guess":42}`, "", true},
+		{`{"type":"guess"}`, "", true},
+		{`{"type":"guess","guess":null}`, "", true},
+		{`{"type":"guess","guess":[1]}`, "", true},
+		{`{"type":`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := decodeGuess([]byte(tt.in))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("decodeGuess(%q) = %q, %v, want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// 服务器消息编码成带type和text的JSON帧，text按接收者的语言渲染
+func TestMessageEncoding(t *testing.T) {
+	remaining := 3
+	tests := []struct {
+		name string
+		msg  any
+		want map[string]any
+	}{
+		{
+			name: "feedback",
+			msg: feedbackMsg{
+				baseMsg:   base(msgFeedback, tr("guess.low")),
+				Result:    resultLow,
+				Guess:     42,
+				Remaining: &remaining,
+			},
+			want: map[string]any{"type": "feedback", "result": "low", "guess": 42.0, "remaining": 3.0, "text": "too low"},
+		},
+		{
+			name: "round start",
+			msg: roundStartMsg{
+				baseMsg: base(msgRoundStart, tr("round.start", tr("prompt.number", 1, 100), "")),
+				Game:    "number",
+				Range:   []int{1, 100},
+			},
+			want: map[string]any{"type": "round_start", "game": "number", "text": "New round! Guess a number between 1 and 100"},
+		},
+		{
+			name: "player joined",
+			msg: playerMsg{
+				baseMsg: base(msgPlayerJoined, nil),
+				Player:  "alice",
+				Count:   2,
+				Players: []string{"bob", "alice"},
+			},
+			want: map[string]any{"type": "player_joined", "player": "alice", "count": 2.0, "text": ""},
+		},
+		{
+			name: "error",
+			msg:  errorMsg{baseMsg: base(msgError, tr("error.invalid_number")), Code: errInvalidGuess},
+			want: map[string]any{"type": "error", "code": "invalid_guess", "text": "Please enter a valid number"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			if err := json.Unmarshal(render(tt.msg, langEn), &got); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %v (frame %v)", k, got[k], v, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 一个版本的迁移
+type migration struct {
+	version int
+	stmts   []string
+}
+
+// 数据库迁移，按版本号顺序执行，已执行的版本记录在schema_migrations表中。
+// 只能追加新版本，不能修改已发布的版本
+var migrations = []migration{
+	{
+		version: 1,
+		stmts: []string{`
+			CREATE TABLE IF NOT EXISTS game_results (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				player_id VARCHAR(50) NOT NULL,
+				room_name VARCHAR(50) NOT NULL,
+				result VARCHAR(20) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX idx_game_results_room ON game_results (room_name)`,
+			`CREATE INDEX idx_game_results_player ON game_results (player_id)`,
+		},
+	},
+	{
+		version: 2,
+		stmts: []string{
+			`ALTER TABLE game_results ADD COLUMN attempts INT NOT NULL DEFAULT 0`,
+			`ALTER TABLE game_results ADD COLUMN round_id BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 3,
+		stmts: []string{
+			`ALTER TABLE game_results ADD COLUMN difficulty VARCHAR(10) NOT NULL DEFAULT ''`,
+			`CREATE INDEX idx_game_results_difficulty ON game_results (difficulty)`,
+		},
+	},
+	{
+		version: 4,
+		stmts: []string{
+			`ALTER TABLE game_results ADD COLUMN streak INT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 5,
+		stmts: []string{
+			`ALTER TABLE game_results ADD COLUMN game_mode VARCHAR(10) NOT NULL DEFAULT 'number'`,
+		},
+	},
+	{
+		version: 6,
+		stmts: []string{
+			`ALTER TABLE game_results ADD COLUMN points INT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 7,
+		stmts: []string{`
+			CREATE TABLE IF NOT EXISTS guess_rounds (
+				id BIGINT AUTO_INCREMENT PRIMARY KEY,
+				room_name VARCHAR(50) NOT NULL,
+				round_id BIGINT NOT NULL,
+				round_no INT NOT NULL,
+				game_mode VARCHAR(10) NOT NULL,
+				answer VARCHAR(50) NOT NULL,
+				winner VARCHAR(50) NOT NULL,
+				end_reason VARCHAR(20) NOT NULL,
+				guesses INT NOT NULL,
+				duration_ms BIGINT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX idx_guess_rounds_room ON guess_rounds (room_name, id)`,
+		},
+	},
+}
+
+// MySQL的重复列名和重复索引名错误，之前手动建过表或加过列时跳过
+const (
+	errDupFieldName = 1060
+	errDupKeyName   = 1061
+)
+
+// 执行尚未应用的迁移，重复启动时为空操作
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		for _, stmt := range m.stmts {
+			if _, err := db.Exec(stmt); err != nil && !alreadyApplied(err) {
+				return fmt.Errorf("migration %d: %w", m.version, err)
+			}
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", m.version); err != nil {
+			return fmt.Errorf("record migration %d: %w", m.version, err)
+		}
+		fmt.Println("已执行数据库迁移", m.version)
+	}
+	return nil
+}
+
+// 列或索引已经存在
+func alreadyApplied(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && (me.Number == errDupFieldName || me.Number == errDupKeyName)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 显示名最多的字符数
+const maxNameLen = 16
+
+// 整理 ?name= 传入的显示名：去掉首尾空白，为空时返回空串由调用方使用连接ID，
+// 过长或含控制字符时返回错误
+func cleanName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxNameLen {
+		return "", fmt.Errorf("name must be at most %d characters", maxNameLen)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", errors.New("name must not contain control characters")
+		}
+	}
+	return name, nil
+}
+
+// 显示名在房间内已被使用，调用方需持有房间锁
+func (r *Room) nameTaken(name string) bool {
+	for _, p := range r.players {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 房间内唯一的显示名，重名时依次加上 #2、#3……，调用方需持有房间锁
+func (r *Room) uniqueName(name string) string {
+	if !r.nameTaken(name) {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s#%d", name, i)
+		if !r.nameTaken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// 按加入顺序排列的在场玩家显示名，调用方需持有房间锁
+func (r *Room) roster() []string {
+	list := make([]*Player, 0, len(r.players))
+	for _, p := range r.players {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+	names := make([]string, len(list))
+	for i, p := range list {
+		names[i] = p.name
+	}
+	return names
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"github.com/gorilla/websocket"
+)
+
+// 私人房间：创建者用 ?pass=<code> 设置口令，之后加入的玩家和观战者都要提供相同口令。
+// 房间只保存口令的SHA-256，口令不会出现在房间列表和广播消息中
+
+// 口令错误时的关闭码
+const closeWrongPass = 4003
+
+// 口令的哈希，未设置口令时为nil
+func hashPass(code string) []byte {
+	if code == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(code))
+	return sum[:]
+}
+
+// 房间是否设置了口令
+func (r *Room) locked() bool {
+	return r.passHash != nil
+}
+
+// 校验口令，比较哈希而不是原文，耗时与口令内容无关；公开房间总是通过
+func (r *Room) checkPass(code string) bool {
+	if !r.locked() {
+		return true
+	}
+	sum := sha256.Sum256([]byte(code))
+	return subtle.ConstantTimeCompare(sum[:], r.passHash) == 1
+}
+
+// 口令错误时拒绝连接：发送wrong_pass错误后以closeWrongPass关闭
+func rejectWrongPass(conn *websocket.Conn, lang string) {
+	rejectConn(conn, lang, errorMsg{baseMsg: base(msgError, tr("error.wrong_pass")), Code: errWrongPass}, closeWrongPass, "wrong pass")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 私人房间有人时不带口令被拒绝；所有人离开后房间被移除，同名房间不带口令重新创建，可以直接加入
+func TestLockedRoomRemovedWhenEmpty(t *testing.T) {
+	s, ts := newTestServer(t)
+	owner := dial(t, ts, "private", "name=owner&pass=secret123")
+	expect(t, owner, msgWelcome)
+	guest := dial(t, ts, "private", "name=guest")
+	if m := expect(t, guest, msgError); m["code"] != errWrongPass {
+		t.Fatalf("join without pass: code %v, want %s", m["code"], errWrongPass)
+	}
+
+	r := s.findRoom("private")
+	owner.Close()
+	waitAway(t, r, 1)
+	// 不等保留期，直接按到期处理
+	r.lock.RLock()
+	var p *Player
+	for _, p = range r.away {
+	}
+	r.lock.RUnlock()
+	r.expire(p)
+	if s.findRoom("private") != nil {
+		t.Fatal("empty room still registered")
+	}
+	r.lock.RLock()
+	closed := r.closed
+	r.lock.RUnlock()
+	if !closed {
+		t.Error("removed room is not closed")
+	}
+
+	guest = dial(t, ts, "private", "name=guest")
+	expect(t, guest, msgWelcome)
+	again := s.findRoom("private")
+	if again == nil || again == r {
+		t.Fatalf("room after rejoin = %p, want a new room (old %p)", again, r)
+	}
+	again.lock.RLock()
+	defer again.lock.RUnlock()
+	if again.locked() || len(again.players) != 1 {
+		t.Errorf("new room: locked %v, %d players, want public with 1 player", again.locked(), len(again.players))
+	}
+}
+
+// 只有观战者的房间在最后一位观战者离开后同样移除
+func TestSpectatorOnlyRoomRemoved(t *testing.T) {
+	s, ts := newTestServer(t)
+	w := dial(t, ts, "watched", "mode=spectator&pass=secret123")
+	expect(t, w, msgWelcome)
+	w.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.findRoom("watched") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("spectator-only room not removed after the spectator left")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
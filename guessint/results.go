@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 结果写入队列
+const (
+	resultQueueSize    = 1024            // 队列满时丢弃新的结果，不阻塞游戏
+	resultWriteTimeout = 5 * time.Second // 单行写入的超时，避免卡住的数据库拖住整个队列
+)
+
+// game_results的一行
+type resultRow struct {
+	room       string
+	roundID    int64
+	name       string
+	result     string
+	attempts   int
+	difficulty string
+	game       string
+	streak     int
+	points     int
+}
+
+// 写入队列中的一行：game_results的resultRow或guess_rounds的roundRow
+type dbRow interface {
+	insert(ctx context.Context, db *sql.DB) error
+}
+
+// 异步写入比赛结果和轮次记录，游戏协程只负责入队，由run协程逐行写库。
+// 进程退出时队列中尚未写入的行会丢失
+type resultWriter struct {
+	db     *sql.DB
+	queue  chan dbRow
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{} // run退出时关闭
+}
+
+func newResultWriter(db *sql.DB) *resultWriter {
+	return &resultWriter{db: db, queue: make(chan dbRow, resultQueueSize), done: make(chan struct{})}
+}
+
+// 入队，队列满或已经关闭时丢弃并打印日志
+func (w *resultWriter) enqueue(row dbRow) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		fmt.Printf("结果队列已关闭，丢弃: %+v\n", row)
+		return
+	}
+	select {
+	case w.queue <- row:
+	default:
+		fmt.Printf("结果队列已满，丢弃: %+v\n", row)
+	}
+}
+
+// 依次写入队列中的行，直到Close
+func (w *resultWriter) run() {
+	defer close(w.done)
+	for row := range w.queue {
+		w.write(row)
+	}
+}
+
+// 不再接受新的行，等待队列中已有的行写完。run必须已经启动
+func (w *resultWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
+
+func (w *resultWriter) write(row dbRow) {
+	ctx, cancel := context.WithTimeout(context.Background(), resultWriteTimeout)
+	defer cancel()
+	if err := row.insert(ctx, w.db); err != nil {
+		fmt.Println("保存结果失败:", err)
+	}
+}
+
+// 修复：SQL语句参数数量与字段数量一致
+func (row resultRow) insert(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO game_results (player_id, room_name, result, attempts, round_id, difficulty, game_mode, streak, points) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		row.name, row.room, row.result, row.attempts, row.roundID, row.difficulty, row.game, row.streak, row.points)
+	return err
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// 连续两轮里每位玩家的次数只算本轮的猜测，同一轮的行共用round_id，换轮后次数清零
+func TestAttemptsPerRound(t *testing.T) {
+	s, ts := newTestServer(t)
+	// 猜错的反馈只发给猜测者，每次读到的feedback都是自己这次猜测的
+	alice := dial(t, ts, "attempts", "name=alice&countdown=0&sharedinfo=0")
+	expect(t, alice, msgWelcome)
+	bob := dial(t, ts, "attempts", "name=bob&countdown=0&sharedinfo=0")
+	expect(t, bob, msgWelcome)
+	r := s.rooms["attempts"]
+
+	secret := func() int {
+		r.lock.RLock()
+		defer r.lock.RUnlock()
+		return r.secret
+	}
+	guess := func(c *websocket.Conn, n int) {
+		t.Helper()
+		if err := c.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(n))); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, c, msgFeedback)
+	}
+	wrong := func() int {
+		if secret() == r.min {
+			return r.max
+		}
+		return r.min
+	}
+
+	// 第一轮：alice猜错两次后猜中，bob猜错一次
+	guess(alice, wrong())
+	guess(bob, wrong())
+	guess(alice, wrong())
+	guess(alice, secret())
+	// 猜中的反馈广播给所有人，两边都读到新一轮开始再继续
+	expect(t, alice, msgRoundStart)
+	expect(t, bob, msgRoundStart)
+	// 第二轮：bob猜错一次后猜中，alice没有猜
+	guess(bob, wrong())
+	guess(bob, secret())
+	expect(t, bob, msgRoundStart)
+	expect(t, alice, msgRoundStart)
+
+	type key struct {
+		round int
+		name  string
+	}
+	got := map[key]resultRow{}
+	var rounds []int64
+	for len(s.results.queue) > 0 {
+		row, ok := (<-s.results.queue).(resultRow)
+		if !ok {
+			continue
+		}
+		if len(rounds) == 0 || rounds[len(rounds)-1] != row.roundID {
+			rounds = append(rounds, row.roundID)
+		}
+		got[key{len(rounds), row.name}] = row
+	}
+	if len(rounds) != 2 {
+		t.Fatalf("result rows span round IDs %v, want 2 distinct rounds", rounds)
+	}
+	tests := []struct {
+		key      key
+		result   string
+		attempts int
+	}{
+		{key{1, "alice"}, "win", 3},
+		{key{1, "bob"}, "lose", 1},
+		{key{2, "alice"}, "lose", 0},
+		{key{2, "bob"}, "win", 2},
+	}
+	for _, tt := range tests {
+		row, ok := got[tt.key]
+		if !ok {
+			t.Errorf("round %d: no row for %s", tt.key.round, tt.key.name)
+			continue
+		}
+		if row.result != tt.result || row.attempts != tt.attempts {
+			t.Errorf("round %d %s: %s with %d attempts, want %s with %d",
+				tt.key.round, tt.key.name, row.result, row.attempts, tt.result, tt.attempts)
+		}
+	}
+}
+
+// 有人猜中的同时其他玩家不断加入：配合 -race 检查胜负记录取名单时的加锁，
+// 每轮的win行、lose行和guess_rounds行用同一个round_id关联
+func TestWinWhileJoining(t *testing.T) {
+	s, ts := newTestServer(t)
+	winner := dial(t, ts, "joining", "name=winner&countdown=0&maxplayers=100")
+	expect(t, winner, msgWelcome)
+	r := s.rooms["joining"]
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/joining?"
+
+	const rounds, joiners = 4, 5
+	var mu sync.Mutex
+	var conns []*websocket.Conn
+	t.Cleanup(func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+	for i := 0; i < rounds; i++ {
+		var wg sync.WaitGroup
+		errs := make(chan error, joiners)
+		for j := 0; j < joiners; j++ {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				c, _, err := websocket.DefaultDialer.Dial(url+"name="+name, nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				mu.Lock()
+				conns = append(conns, c)
+				mu.Unlock()
+			}(fmt.Sprintf("p%d-%d", i, j))
+		}
+		r.lock.RLock()
+		secret := r.secret
+		r.lock.RUnlock()
+		if err := winner.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(secret))); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, winner, msgRoundStart)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+	}
+
+	// 按round_id分组：每轮一行guess_rounds，winner一行win，其余都是lose
+	type round struct {
+		saved       bool
+		wins, loses int
+	}
+	byID := map[int64]*round{}
+	get := func(id int64) *round {
+		if byID[id] == nil {
+			byID[id] = &round{}
+		}
+		return byID[id]
+	}
+	for len(s.results.queue) > 0 {
+		switch row := (<-s.results.queue).(type) {
+		case roundRow:
+			if row.winner != "winner" {
+				t.Errorf("round %d: winner %q, want winner", row.roundID, row.winner)
+			}
+			get(row.roundID).saved = true
+		case resultRow:
+			rd := get(row.roundID)
+			switch {
+			case row.result == "win" && row.name == "winner":
+				rd.wins++
+			case row.result == "lose" && row.name != "winner":
+				rd.loses++
+			default:
+				t.Errorf("round %d: unexpected row %+v", row.roundID, row)
+			}
+		}
+	}
+	if len(byID) != rounds {
+		t.Fatalf("rows span %d round IDs, want %d", len(byID), rounds)
+	}
+	// round_id是开始时的时间戳，按顺序排列后第i轮至少有前几轮加入的人记为lose
+	ids := slices.Sorted(maps.Keys(byID))
+	for i, id := range ids {
+		rd := byID[id]
+		if !rd.saved || rd.wins != 1 {
+			t.Errorf("round %d: round row %v, %d win rows, want a round row and 1 win", id, rd.saved, rd.wins)
+		}
+		if rd.loses < i*joiners {
+			t.Errorf("round %d: %d lose rows, want at least %d", id, rd.loses, i*joiners)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 房间空了超过这个时间就不再出现在房间列表中，可用环境变量EMPTY_ROOM_TTL修改
+const defaultEmptyRoomTTL = 5 * time.Minute
+
+// 房间列表中的一项
+type RoomInfo struct {
+	Name          string `json:"name"`
+	Players       int    `json:"players"`
+	Spectators    int    `json:"spectators"`
+	MaxPlayers    int    `json:"max_players"`
+	MaxSpectators int    `json:"max_spectators"`
+	Locked        bool   `json:"locked"` // 私人房间，加入和观战需要口令
+	Game          string `json:"game"`
+	Mode          string `json:"mode"`
+	Difficulty    string `json:"difficulty,omitempty"`
+	Round         int    `json:"round"`     // 当前是第几轮，从1开始
+	RoundSec      int64  `json:"round_sec"` // 本轮已进行的秒数
+}
+
+// 房间概要，调用方需持有房间锁
+func (r *Room) info(now time.Time) RoomInfo {
+	return RoomInfo{
+		Name:          r.name,
+		Players:       len(r.players),
+		Spectators:    len(r.watchers),
+		MaxPlayers:    r.maxPlayers,
+		MaxSpectators: r.maxSpectators,
+		Locked:        r.locked(),
+		Game:          r.game,
+		Mode:          r.mode,
+		Difficulty:    r.difficulty.Name,
+		Round:         r.round,
+		RoundSec:      int64(now.Sub(r.roundStarted) / time.Second),
+	}
+}
+
+// 查找房间，不存在时不创建
+func (s *GameServer) findRoom(name string) *Room {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.rooms[name]
+}
+
+// 房间列表接口：GET /api/rooms，按玩家数降序，空了超过emptyTTL的房间不列出
+func (s *GameServer) listRooms(c *gin.Context) {
+	now := time.Now()
+	out := []RoomInfo{}
+	s.lock.RLock()
+	for _, r := range s.rooms {
+		r.lock.RLock()
+		stale := len(r.players) == 0 && now.Sub(r.emptySince) > s.emptyTTL
+		info := r.info(now)
+		r.lock.RUnlock()
+		if !stale {
+			out = append(out, info)
+		}
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Players != out[j].Players {
+			return out[i].Players > out[j].Players
+		}
+		return out[i].Name < out[j].Name
+	})
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
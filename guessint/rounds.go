@@ -0,0 +1,136 @@
+package main
+
+import (
+	"time"
+)
+
+// 每轮的时间限制
+const (
+	defaultRoundTime = 3 * time.Minute
+	maxRoundTime     = time.Hour
+	roundWarning     = 30 * time.Second // 剩余这么多时间时广播提醒
+)
+
+// 一轮结束到下一轮开始之间的倒计时
+const (
+	defaultCountdown = 5 * time.Second
+	maxCountdown     = time.Minute
+)
+
+// 重新开始本轮计时，不限时、房间没人或正在倒计时时只停止计时器。调用方需持有房间写锁
+func (r *Room) resetRoundTimer() {
+	if r.roundTimer != nil {
+		r.roundTimer.Stop()
+		r.roundTimer = nil
+	}
+	// 序号让已经触发但还在等锁的旧计时器失效
+	r.roundSeq++
+	r.roundStarted = time.Now()
+	if r.roundTime == 0 || len(r.players) == 0 || r.paused {
+		return
+	}
+	seq := r.roundSeq
+	if r.roundTime > roundWarning {
+		r.roundTimer = time.AfterFunc(r.roundTime-roundWarning, func() { r.roundWarn(seq) })
+	} else {
+		r.roundTimer = time.AfterFunc(r.roundTime, func() { r.roundExpired(seq) })
+	}
+}
+
+// 本轮只剩roundWarning，广播提醒并等待到期
+func (r *Room) roundWarn(seq int) {
+	r.lock.Lock()
+	if seq != r.roundSeq {
+		r.lock.Unlock()
+		return
+	}
+	r.roundTimer = time.AfterFunc(roundWarning, func() { r.roundExpired(seq) })
+	r.lock.Unlock()
+	secs := int(roundWarning / time.Second)
+	r.broadcast(roundWarningMsg{baseMsg: base(msgRoundWarning, tr("round.warning", secs)), RemainingSec: secs})
+}
+
+// 本轮时间到，公布答案，所有玩家记为未解出，然后开始新一轮
+func (r *Room) roundExpired(seq int) {
+	r.lock.Lock()
+	if seq != r.roundSeq {
+		r.lock.Unlock()
+		return
+	}
+	answer := r.answer()
+	rr := r.snapshotRound()
+	rr.reason = reasonTimeUp
+	scores := r.scoreboardMessage()
+	start := r.nextRound()
+	r.lock.Unlock()
+
+	r.broadcast(roundEndMsg{
+		baseMsg: base(msgRoundEnd, tr("round.time_up", answer)),
+		Answer:  answer,
+		Reason:  reasonTimeUp,
+	})
+	r.broadcast(scores)
+	r.saveRound(rr, func(string) string { return "unsolved" })
+	r.broadcast(start)
+}
+
+// 一轮结束后进入下一轮：有倒计时则开始倒计时，到时再生成新答案，否则立即开始新一轮。
+// 返回应该广播的消息（第一条倒计时或新一轮开始），调用方需持有房间写锁
+func (r *Room) nextRound() any {
+	if r.countdown == 0 {
+		r.newRound()
+		return r.roundStartMessage()
+	}
+	r.paused = true
+	r.resetRoundTimer()
+	r.countdownSeq++
+	r.countdownLeft = int(r.countdown / time.Second)
+	seq := r.countdownSeq
+	r.countdownTimer = time.AfterFunc(time.Second, func() { r.countdownTick(seq) })
+	return r.countdownMessage()
+}
+
+func (r *Room) countdownMessage() countdownMsg {
+	return countdownMsg{
+		baseMsg:      base(msgCountdown, tr("round.countdown", r.countdownLeft)),
+		RemainingSec: r.countdownLeft,
+	}
+}
+
+// 倒计时每秒一次，到0时开始新一轮
+func (r *Room) countdownTick(seq int) {
+	r.lock.Lock()
+	if seq != r.countdownSeq {
+		r.lock.Unlock()
+		return
+	}
+	r.countdownLeft--
+	if r.countdownLeft > 0 {
+		r.countdownTimer = time.AfterFunc(time.Second, func() { r.countdownTick(seq) })
+		msg := r.countdownMessage()
+		r.lock.Unlock()
+		r.broadcast(msg)
+		return
+	}
+	r.countdownTimer = nil
+	r.paused = false
+	r.newRound()
+	start := r.roundStartMessage()
+	r.lock.Unlock()
+	r.broadcast(start)
+}
+
+// 取消倒计时，房间变空或关闭时调用。resume为true时立即开始新一轮，调用方需持有房间写锁
+func (r *Room) stopCountdown(resume bool) {
+	if r.countdownTimer != nil {
+		r.countdownTimer.Stop()
+		r.countdownTimer = nil
+	}
+	r.countdownSeq++
+	if r.paused {
+		r.paused = false
+		if resume {
+			r.newRound()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+CREATE DATABASE IF NOT EXISTS game_db DEFAULT CHARACTER SET utf8mb4;
+
+USE game_db;
+
+-- 表结构由服务启动时自动迁移创建（见 migrate.go），这里仅供手动建库参考
+
+-- 每轮结束时每位在场玩家一行：win / lose / timeout（次数用完）/ unsolved（时间到）
+CREATE TABLE IF NOT EXISTS game_results (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    player_id VARCHAR(50) NOT NULL,
+    room_name VARCHAR(50) NOT NULL,
+    result VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0, -- 该玩家本轮的猜测次数（超出范围的不算）
+    round_id BIGINT NOT NULL DEFAULT 0, -- 本轮开始时的纳秒时间戳，与room_name一起确定一轮
+    difficulty VARCHAR(10) NOT NULL DEFAULT '', -- easy / normal / hard，未选择难度时为空
+    streak INT NOT NULL DEFAULT 0, -- win行记录赢家当时的连胜局数，其他行为0
+    game_mode VARCHAR(10) NOT NULL DEFAULT 'number', -- number（猜数字）/ digits（数字牛）/ word（猜单词）
+    points INT NOT NULL DEFAULT 0, -- win行记录赢家本轮的得分，其他行为0
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_game_results_room (room_name),
+    INDEX idx_game_results_player (player_id),
+    INDEX idx_game_results_difficulty (difficulty)
+);
+
+-- 每轮结束时一行，用于回顾一场游戏的经过
+CREATE TABLE IF NOT EXISTS guess_rounds (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    room_name VARCHAR(50) NOT NULL,
+    round_id BIGINT NOT NULL, -- 同game_results.round_id
+    round_no INT NOT NULL, -- 房间创建以来的第几轮，服务重启后从1开始
+    game_mode VARCHAR(10) NOT NULL,
+    answer VARCHAR(50) NOT NULL, -- 本轮答案，数字也按文本保存
+    winner VARCHAR(50) NOT NULL, -- 猜对的玩家，没人猜对时为unsolved
+    end_reason VARCHAR(20) NOT NULL, -- solved / out_of_attempts / out_of_misses / time_up / closed
+    guesses INT NOT NULL, -- 全房间本轮计入次数的猜测数
+    duration_ms BIGINT NOT NULL, -- 本轮从开始到结束的毫秒数
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_guess_rounds_room (room_name, id)
+);
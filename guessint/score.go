@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 一轮的得分：赢家在本轮第1次就猜中得100分，每多猜一次少10分，最少1分；没赢的玩家不得分
+func roundPoints(attempts int) int {
+	return max(1, 100-10*(attempts-1))
+}
+
+// 给赢家加分。按显示名记录，同名重连不会丢失积分，调用方需持有房间写锁
+func (r *Room) addPoints(name string, n int) {
+	r.points[name] += n
+}
+
+// 积分榜的一行
+type scoreEntry struct {
+	Player string `json:"player"`
+	Points int    `json:"points"`
+}
+
+// 每轮结束后广播的积分榜
+type scoreboardMsg struct {
+	baseMsg
+	Scores []scoreEntry `json:"scores"`
+}
+
+// 在场玩家的累计积分，按积分降序，同分按加入顺序，调用方需持有房间锁
+func (r *Room) scoreboardMessage() scoreboardMsg {
+	players := make([]*Player, 0, len(r.players))
+	for _, p := range r.players {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		pi, pj := r.points[players[i].name], r.points[players[j].name]
+		if pi != pj {
+			return pi > pj
+		}
+		return players[i].seq < players[j].seq
+	})
+	scores := make([]scoreEntry, len(players))
+	parts := make(list, len(players))
+	for i, p := range players {
+		scores[i] = scoreEntry{Player: p.name, Points: r.points[p.name]}
+		parts[i] = fmt.Sprintf("%s %d", p.name, scores[i].Points)
+	}
+	return scoreboardMsg{
+		baseMsg: base(msgScoreboard, tr("scoreboard", parts)),
+		Scores:  scores,
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoundPoints(t *testing.T) {
+	tests := []struct{ attempts, want int }{
+		{1, 100},
+		{2, 90},
+		{10, 10},
+		{11, 1},
+		{50, 1},
+	}
+	for _, tt := range tests {
+		if got := roundPoints(tt.attempts); got != tt.want {
+			t.Errorf("roundPoints(%d) = %d, want %d", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// 积分跨轮累计，积分榜按积分降序，同分按加入顺序
+func TestScoreboardAccumulates(t *testing.T) {
+	r := &Room{players: make(map[string]*Player), points: make(map[string]int)}
+	for i, name := range []string{"alice", "bob", "carol"} {
+		r.players[name] = &Player{id: name, name: name, seq: i + 1}
+	}
+	// 三轮：bob第1次猜中，alice第10次猜中，carol第3次猜中，bob第11次猜中
+	r.addPoints("bob", roundPoints(1))
+	r.addPoints("alice", roundPoints(10))
+	r.addPoints("carol", roundPoints(3))
+	r.addPoints("bob", roundPoints(11))
+
+	got := r.scoreboardMessage().Scores
+	want := []scoreEntry{{"bob", 101}, {"carol", 80}, {"alice", 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scoreboard = %v, want %v", got, want)
+	}
+
+	r.addPoints("alice", roundPoints(4))
+	got = r.scoreboardMessage().Scores
+	want = []scoreEntry{{"bob", 101}, {"alice", 80}, {"carol", 80}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after a tie, scoreboard = %v, want %v (alice joined first)", got, want)
+	}
+}
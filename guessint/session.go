@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 断线后保留玩家的时长，期间用 ?resume=<token> 连接可以接回原来的玩家
+const resumeGrace = 60 * time.Second
+
+// 生成随机会话令牌
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 玩家断线：留在房间里（显示名、回合位置不变），保留期内没有重连再按离开处理。
+// 断线期间回合跳过他，正轮到他时立即交给下一位。房间已关闭或玩家已不在房间时什么也不做
+func (r *Room) detach(p *Player) {
+	defer p.close()
+	r.lock.Lock()
+	if r.players[p.id] != p {
+		r.lock.Unlock()
+		return
+	}
+	r.away[p.token] = p
+	p.awayTimer = time.AfterFunc(resumeGrace, func() { r.expire(p) })
+	var next *Player
+	if r.mode == modeTurns && r.currentTurn() == p {
+		next = r.nextTurn()
+	}
+	r.lock.Unlock()
+	r.announceTurn(next)
+}
+
+// 玩家是否在断线保留期内，调用方需持有房间锁
+func (r *Room) isAway(p *Player) bool {
+	return r.away[p.token] == p
+}
+
+// 保留期结束仍未重连，玩家离开房间
+func (r *Room) expire(p *Player) {
+	r.lock.Lock()
+	if r.away[p.token] != p {
+		// 已经重连或房间已关闭
+		r.lock.Unlock()
+		return
+	}
+	delete(r.away, p.token)
+	r.lock.Unlock()
+	r.leave(p)
+}
+
+// 用会话令牌接回保留期内的玩家：新连接沿用原来的ID、显示名、加入顺序和回合位置，
+// 连胜、积分和本轮次数都按这些记录，自然延续；语言按新连接的选择。
+// 令牌无效或已过期时返回nil，调用方需持有房间写锁
+func (r *Room) resume(token string, conn *websocket.Conn, lang string) *Player {
+	old := r.away[token]
+	if token == "" || old == nil {
+		return nil
+	}
+	delete(r.away, token)
+	old.awayTimer.Stop()
+	p := newPlayer(old.id, old.name, old.seq, conn, lang)
+	p.token = old.token
+	r.players[p.id] = p
+	for i, q := range r.order {
+		if q == old {
+			r.order[i] = p
+		}
+	}
+	return p
+}
+
+// 关闭房间时停止所有保留期计时器并清空令牌，调用方需持有房间写锁
+func (r *Room) clearAway() {
+	for _, p := range r.away {
+		p.awayTimer.Stop()
+	}
+	r.away = make(map[string]*Player)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 等待房间里断线保留中的玩家达到n人
+func waitAway(t *testing.T, r *Room, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r.lock.RLock()
+		got := len(r.away)
+		r.lock.RUnlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("away players = %d, want %d", got, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 回合制房间里断线的玩家被跳过：轮到他时立即交给下一位，所有人都断线时暂停，重连后继续
+func TestTurnSkipsAwayPlayers(t *testing.T) {
+	s, ts := newTestServer(t)
+	c1 := dial(t, ts, "turns", "mode=turns&name=alice")
+	w1 := expect(t, c1, msgWelcome)
+	if w1["turn"] != "alice" {
+		t.Fatalf("first turn = %v, want alice", w1["turn"])
+	}
+	c2 := dial(t, ts, "turns", "mode=turns&name=bob")
+	w2 := expect(t, c2, msgWelcome)
+
+	// 正轮到alice时断线，回合不等超时直接交给bob
+	c1.Close()
+	if turn := expect(t, c2, msgTurn); turn["player"] != "bob" {
+		t.Fatalf("after alice dropped, turn = %v, want bob", turn["player"])
+	}
+
+	// bob也断线后回合暂停，alice重连时从她继续
+	c2.Close()
+	waitAway(t, s.rooms["turns"], 2)
+	c1 = dial(t, ts, "turns", "mode=turns&resume="+w1["token"].(string))
+	w1 = expect(t, c1, msgWelcome)
+	if w1["resumed"] != true || w1["turn"] != "alice" {
+		t.Fatalf("alice resumed: resumed=%v turn=%v, want true, alice", w1["resumed"], w1["turn"])
+	}
+	if turn := expect(t, c1, msgTurn); turn["player"] != "alice" {
+		t.Errorf("after resume, turn = %v, want alice", turn["player"])
+	}
+
+	// 轮到alice时bob重连，回合不变
+	c2 = dial(t, ts, "turns", "mode=turns&resume="+w2["token"].(string))
+	if w := expect(t, c2, msgWelcome); w["turn"] != "alice" {
+		t.Errorf("bob resumed: turn = %v, want alice", w["turn"])
+	}
+}
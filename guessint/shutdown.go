@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 收到SIGINT/SIGTERM后关闭服务器的总时长上限
+const shutdownGrace = 10 * time.Second
+
+// 关闭所有房间：通知客户端、发送关闭帧并等待写出，最后等待结果队列写完。
+// 之后的新连接会收到503。整个过程受ctx的超时约束
+func (s *GameServer) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	s.closing = true
+	rooms := make([]*Room, 0, len(s.rooms))
+	for name, r := range s.rooms {
+		rooms = append(rooms, r)
+		delete(s.rooms, name)
+	}
+	s.lock.Unlock()
+
+	var conns []*Player
+	for _, r := range rooms {
+		r.broadcast(base(msgServerClosing, tr("server.closing")))
+		conns = append(conns, r.Close()...)
+	}
+
+	// 等待关闭帧写出
+	for _, p := range conns {
+		select {
+		case <-p.flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// 等待结果队列写完
+	drained := make(chan struct{})
+	go func() {
+		s.results.Close()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 关闭房间：停止所有计时器（包括断线保留期）、作废会话令牌，把进行中且有人猜过的一轮记为未解出，
+// 向所有玩家和观战者发送关闭帧，返回这些连接以便等待写出。
+// 服务器关闭和空房间清理都用它，可以重复调用
+func (r *Room) Close() []*Player {
+	conns, _ := r.close(false)
+	return conns
+}
+
+// 房间里没有玩家（包括断线保留期内的）和观战者时关闭，返回是否关闭。
+// 检查和关闭在同一次加锁内完成，不会关掉刚加入的玩家
+func (r *Room) closeIfEmpty() bool {
+	_, ok := r.close(true)
+	return ok
+}
+
+func (r *Room) close(ifEmpty bool) ([]*Player, bool) {
+	r.lock.Lock()
+	if r.closed || ifEmpty && (len(r.players) > 0 || len(r.watchers) > 0) {
+		r.lock.Unlock()
+		return nil, false
+	}
+	r.closed = true
+	var rr *roundResult
+	if r.attempts > 0 {
+		snap := r.snapshotRound()
+		snap.reason = reasonClosed
+		rr = &snap
+	}
+	conns := make([]*Player, 0, len(r.players)+len(r.watchers))
+	for _, p := range r.players {
+		conns = append(conns, p)
+	}
+	for _, w := range r.watchers {
+		conns = append(conns, w)
+	}
+	// 清空后读循环退出时的离开处理成为空操作，计时器也不会再启动
+	r.players = make(map[string]*Player)
+	r.watchers = make(map[string]*Player)
+	r.order = nil
+	r.clearAway()
+	r.stopCountdown(false)
+	r.startTurn()
+	r.resetRoundTimer()
+	r.lock.Unlock()
+
+	if rr != nil {
+		// 这一轮没有打完，不算任何人的输赢，只记入guess_rounds
+		r.results.enqueue(r.roundRow(*rr))
+	}
+	for _, p := range conns {
+		p.closeWith(websocket.CloseGoingAway, "room closed")
+	}
+	return conns, true
+}
+
+// 最后一位玩家和观战者离开后关闭房间并从服务器移除，口令和其他参数随之作废，
+// 同名的下一个连接按自己的参数重新创建房间
+func (s *GameServer) removeIfEmpty(r *Room) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.rooms[r.name] != r || !r.closeIfEmpty() {
+		return
+	}
+	delete(s.rooms, r.name)
+}
+
+// 锁住一个未关闭的房间并返回，调用方负责解锁。room在拿到后被清理时按同样的参数重新获取，
+// 服务器正在关闭时返回nil
+func (s *GameServer) lockRoom(room *Room, opts roomOptions) *Room {
+	for room != nil {
+		room.lock.Lock()
+		if !room.closed {
+			return room
+		}
+		room.lock.Unlock()
+		next := s.getRoom(room.name, opts)
+		if next == room {
+			return nil
+		}
+		room = next
+	}
+	return nil
+}
+
+// 拒绝已经关闭的房间的新连接
+func rejectClosed(conn *websocket.Conn) {
+	closeConn(conn, websocket.CloseGoingAway, "room closed")
+}
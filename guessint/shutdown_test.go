@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// 关闭房间时进行中的一轮只写guess_rounds，不给玩家记输赢
+func TestCloseSavesOnlyRound(t *testing.T) {
+	s := NewGameServer(nil)
+	r := s.getRoom("closing", roomOptions{game: gameNumber, mode: modeFree, min: 1, max: 100})
+	r.lock.Lock()
+	r.attempts = 2
+	r.guesses["alice"] = 1
+	r.guesses["bob"] = 1
+	r.lock.Unlock()
+	r.Close()
+
+	if n := len(s.results.queue); n != 1 {
+		t.Fatalf("queued %d rows, want 1", n)
+	}
+	v := <-s.results.queue
+	row, ok := v.(roundRow)
+	if !ok {
+		t.Fatalf("queued %T, want roundRow", v)
+	}
+	if row.reason != reasonClosed || row.winner != noWinner || row.guesses != 2 {
+		t.Errorf("round row = %+v, want reason closed, no winner, 2 guesses", row)
+	}
+}
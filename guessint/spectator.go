@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// 观战连接：?mode=spectator，收到所有广播但不能猜测，不计入玩家数、回合和比赛结果
+const modeSpectator = "spectator"
+
+// 处理观战连接，连接已经升级。观战者加入和离开不广播，只体现在玩家消息的观战人数中
+func (s *GameServer) spectate(room *Room, opts roomOptions, conn *websocket.Conn, name, pass, lang string) {
+	if room = s.lockRoom(room, opts); room == nil {
+		rejectClosed(conn)
+		return
+	}
+	if !room.checkPass(pass) {
+		room.lock.Unlock()
+		rejectWrongPass(conn, lang)
+		return
+	}
+	if len(room.watchers) >= room.maxSpectators {
+		limit := room.maxSpectators
+		room.lock.Unlock()
+		rejectFull(conn, lang, tr("error.spectators_full", limit), limit)
+		return
+	}
+	room.nextID++
+	id := fmt.Sprintf("S%d", room.nextID)
+	if name == "" {
+		name = id
+	}
+	w := newPlayer(id, name, room.nextID, conn, lang)
+	room.watchers[id] = w
+	welcome := room.welcomeMessage(w)
+	room.lock.Unlock()
+
+	welcome.Spectator = true
+	welcome.add(tr("welcome.spectator"))
+	go w.writePump()
+	w.send(welcome)
+
+	defer func() {
+		room.lock.Lock()
+		delete(room.watchers, id)
+		room.lock.Unlock()
+		w.close()
+		s.removeIfEmpty(room)
+	}()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		w.send(errorMsg{baseMsg: base(msgError, tr("error.spectator")), Code: errSpectator})
+	}
+}
+
+// 玩家消息中附带的观战人数
+func spectatorText(n int) text {
+	if n == 0 {
+		return nil
+	}
+	return tr("player.spectators", n)
+}
@@ -0,0 +1,119 @@
+//go:build sqlite
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// 排行榜查询在SQLite上的结果，用 go test -tags sqlite 运行。
+// 表结构只取排行榜用到的列，数据经resultRow.insert写入，与结果写入器相同
+func TestSQLiteLeaderboard(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "guess.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE game_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		player_id VARCHAR(50) NOT NULL,
+		room_name VARCHAR(50) NOT NULL,
+		result VARCHAR(20) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		round_id BIGINT NOT NULL DEFAULT 0,
+		difficulty VARCHAR(10) NOT NULL DEFAULT '',
+		streak INT NOT NULL DEFAULT 0,
+		game_mode VARCHAR(10) NOT NULL DEFAULT 'number',
+		points INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	rows := []resultRow{
+		{room: "r1", roundID: 1, name: "alice", result: "win", attempts: 4, difficulty: "easy", streak: 1, points: 60},
+		{room: "r1", roundID: 1, name: "bob", result: "lose", attempts: 3, difficulty: "easy"},
+		{room: "r1", roundID: 2, name: "alice", result: "win", attempts: 6, difficulty: "easy", streak: 2, points: 40},
+		{room: "r1", roundID: 2, name: "bob", result: "lose", attempts: 5, difficulty: "easy"},
+		{room: "r2", roundID: 3, name: "bob", result: "win", attempts: 1, difficulty: "hard", streak: 1, points: 200},
+		{room: "r2", roundID: 3, name: "carol", result: "timeout", attempts: 8, difficulty: "hard"},
+		// 记录次数之前的旧数据，attempts为0，不参与平均次数
+		{room: "r2", roundID: 0, name: "carol", result: "win"},
+	}
+	for _, row := range rows {
+		if err := row.insert(context.Background(), db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewGameServer(db)
+
+	type want struct {
+		wins, losses int
+		rate         float64
+		avg          float64 // -1表示null
+		streak       int
+		points       int
+	}
+	tests := []struct {
+		query string
+		order []string
+		want  map[string]want
+	}{
+		// 胜场相同时输得少的在前
+		{"", []string{"alice", "carol", "bob"}, map[string]want{
+			"alice": {2, 0, 1, 5, 2, 100},
+			"bob":   {1, 2, 1.0 / 3, 1, 1, 200},
+			"carol": {1, 1, 0.5, -1, 0, 0},
+		}},
+		{"sort=points", []string{"bob", "alice", "carol"}, nil},
+		{"limit=1", []string{"alice"}, nil},
+		{"room=r2", []string{"bob", "carol"}, map[string]want{
+			"bob":   {1, 0, 1, 1, 1, 200},
+			"carol": {1, 1, 0.5, -1, 0, 0},
+		}},
+		{"difficulty=easy", []string{"alice", "bob"}, map[string]want{
+			"bob": {0, 2, 0, -1, 0, 0},
+		}},
+		// 房间名按参数传入，不会拼进SQL
+		{"room=r1'%20OR%20'1'='1", nil, nil},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Data []LeaderRow `json:"data"`
+		}
+		if code := getLeaderboard(t, s, context.Background(), tt.query, &resp); code != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", tt.query, code)
+		}
+		var order []string
+		for _, row := range resp.Data {
+			order = append(order, row.PlayerID)
+			w, ok := tt.want[row.PlayerID]
+			if !ok {
+				continue
+			}
+			avg := -1.0
+			if row.AvgAttempts != nil {
+				avg = *row.AvgAttempts
+			}
+			got := want{row.Wins, row.Losses, row.WinRate, avg, row.BestStreak, row.Points}
+			if got != w {
+				t.Errorf("%s: %s = %+v, want %+v", tt.query, row.PlayerID, got, w)
+			}
+		}
+		if len(order) != len(tt.order) {
+			t.Errorf("%s: players %v, want %v", tt.query, order, tt.order)
+			continue
+		}
+		for i := range order {
+			if order[i] != tt.order[i] {
+				t.Errorf("%s: players %v, want %v", tt.query, order, tt.order)
+				break
+			}
+		}
+	}
+}
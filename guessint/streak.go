@@ -0,0 +1,20 @@
+package main
+
+// 记录一次胜利，返回该玩家当前的连胜局数；别人赢了连胜就中断。
+// 按显示名记录，玩家断线后用同一个名字重连不会丢失连胜。调用方需持有房间写锁
+func (r *Room) recordWin(name string) int {
+	if r.streakName == name {
+		r.streakCount++
+	} else {
+		r.streakName, r.streakCount = name, 1
+	}
+	return r.streakCount
+}
+
+// 胜利广播后附带的连胜文字，不足两局时为空
+func streakText(name string, n int) text {
+	if n < 2 {
+		return nil
+	}
+	return tr("guess.streak", name, n)
+}
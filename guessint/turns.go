@@ -0,0 +1,126 @@
+package main
+
+import (
+	"time"
+)
+
+// 房间模式
+const (
+	modeFree  = "free"  // 自由抢答，默认
+	modeTurns = "turns" // 按加入顺序轮流猜
+)
+
+// 回合制下每位玩家的思考时间，超时自动轮到下一位
+const turnTimeout = 30 * time.Second
+
+// 回合制下当前应该猜数字的玩家，没有玩家时为nil，调用方需持有房间锁
+func (r *Room) currentTurn() *Player {
+	if len(r.order) == 0 {
+		return nil
+	}
+	return r.order[r.turn]
+}
+
+// 当前回合玩家的显示名，没有时为空串，调用方需持有房间锁
+func (r *Room) currentTurnName() string {
+	if p := r.currentTurn(); p != nil {
+		return p.name
+	}
+	return ""
+}
+
+// 从r.turn指向的玩家开始新回合并重新计时，返回该玩家；断线保留中的玩家跳过。
+// 没有玩家或所有人都断线时停止计时并返回nil，有人重连后由resumeTurns继续。
+// 调用方需持有房间写锁
+func (r *Room) startTurn() *Player {
+	if r.turnTimer != nil {
+		r.turnTimer.Stop()
+		r.turnTimer = nil
+	}
+	// 序号让已经触发但还在等锁的旧计时器失效
+	r.turnSeq++
+	if len(r.order) == 0 {
+		r.turn = 0
+		return nil
+	}
+	r.turn %= len(r.order)
+	for i := 0; i < len(r.order) && r.isAway(r.order[r.turn]); i++ {
+		r.turn = (r.turn + 1) % len(r.order)
+	}
+	if r.isAway(r.order[r.turn]) {
+		return nil
+	}
+	seq := r.turnSeq
+	r.turnTimer = time.AfterFunc(turnTimeout, func() { r.turnExpired(seq) })
+	return r.order[r.turn]
+}
+
+// 轮到下一位玩家，自由模式下什么也不做并返回nil，调用方需持有房间写锁
+func (r *Room) nextTurn() *Player {
+	if r.mode != modeTurns || len(r.order) == 0 {
+		return nil
+	}
+	r.turn = (r.turn + 1) % len(r.order)
+	return r.startTurn()
+}
+
+// 玩家加入回合顺序的末尾，第一位玩家加入时开始计时并返回该玩家，调用方需持有房间写锁
+func (r *Room) joinTurns(p *Player) *Player {
+	if r.mode != modeTurns {
+		return nil
+	}
+	r.order = append(r.order, p)
+	if len(r.order) == 1 {
+		r.turn = 0
+		return r.startTurn()
+	}
+	return nil
+}
+
+// 所有人断线而暂停的回合在有人重连时继续，返回轮到的玩家，回合没有暂停时返回nil。
+// 调用方需持有房间写锁
+func (r *Room) resumeTurns() *Player {
+	if r.mode != modeTurns || r.turnTimer != nil {
+		return nil
+	}
+	return r.startTurn()
+}
+
+// 把离开的玩家移出回合顺序。轮到他时回合交给下一位并返回新玩家，调用方需持有房间写锁
+func (r *Room) leaveTurns(p *Player) *Player {
+	for i, q := range r.order {
+		if q != p {
+			continue
+		}
+		r.order = append(r.order[:i], r.order[i+1:]...)
+		if i < r.turn {
+			r.turn--
+		} else if i == r.turn {
+			// 删除后r.turn已经指向下一位
+			return r.startTurn()
+		}
+		return nil
+	}
+	return nil
+}
+
+// 回合超时，跳过当前玩家
+func (r *Room) turnExpired(seq int) {
+	r.lock.Lock()
+	if seq != r.turnSeq {
+		r.lock.Unlock()
+		return
+	}
+	prev := r.currentTurnName()
+	next := r.nextTurn()
+	r.lock.Unlock()
+	r.broadcast(playerMsg{baseMsg: base(msgTurnTimeout, tr("turn.timeout", prev)), Player: prev})
+	r.announceTurn(next)
+}
+
+// 广播回合变化，p为nil时不发送
+func (r *Room) announceTurn(p *Player) {
+	if p != nil {
+		r.broadcast(playerMsg{baseMsg: base(msgTurn, tr("turn", p.name)), Player: p.name})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 猜单词玩法（?mode=word）每轮允许的猜错次数
+const (
+	defaultMisses = 6
+	missesLimit   = 26
+)
+
+// 内置词库，设置环境变量WORD_LIST为文件路径时改用文件中的单词
+var defaultWords = []string{
+	"apple", "banana", "orange", "grape", "lemon", "mango", "peach",
+	"tiger", "rabbit", "monkey", "panda", "zebra", "eagle", "dolphin",
+	"school", "teacher", "student", "pencil", "window", "garden", "bridge",
+	"planet", "rocket", "forest", "river", "island", "winter", "summer",
+	"golang", "network", "server", "browser", "keyboard", "program",
+}
+
+// 读取词库文件：每行一个单词，忽略空行和#开头的行，单词只能由英文字母组成，统一转成小写。
+// path为空时返回内置词库
+func loadWords(path string) ([]string, error) {
+	if path == "" {
+		return defaultWords, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		w := strings.ToLower(strings.TrimSpace(sc.Text()))
+		if w == "" || strings.HasPrefix(w, "#") {
+			continue
+		}
+		if !isLetters(w) {
+			return nil, fmt.Errorf("%s:%d: invalid word %q", path, line, w)
+		}
+		words = append(words, w)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%s: no words", path)
+	}
+	return words, nil
+}
+
+// 是否全部由小写英文字母组成
+func isLetters(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// 猜单词一轮的状态，房间内所有玩家共用，调用方需持有房间锁
+type hangman struct {
+	word      string
+	guessed   [26]bool // 已经猜过的字母
+	misses    int      // 全房间合计的猜错次数
+	maxMisses int
+}
+
+func newHangman(word string, maxMisses int) *hangman {
+	return &hangman{word: word, maxMisses: maxMisses}
+}
+
+// 猜一个小写字母，返回是否在单词中；已经猜过的字母返回dup，不算猜错
+func (h *hangman) guessLetter(c byte) (hit, dup bool) {
+	if h.guessed[c-'a'] {
+		return false, true
+	}
+	h.guessed[c-'a'] = true
+	if strings.IndexByte(h.word, c) < 0 {
+		h.misses++
+		return false, false
+	}
+	return true, false
+}
+
+// 猜整个单词，猜对时揭开所有字母，猜错算一次猜错
+func (h *hangman) guessWord(w string) bool {
+	if w != h.word {
+		h.misses++
+		return false
+	}
+	for i := 0; i < len(h.word); i++ {
+		h.guessed[h.word[i]-'a'] = true
+	}
+	return true
+}
+
+// 已揭开的单词，没猜到的字母显示为_
+func (h *hangman) mask() string {
+	b := []byte(h.word)
+	for i, c := range b {
+		if !h.guessed[c-'a'] {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// 单词的字母是否全部揭开
+func (h *hangman) solved() bool {
+	return !strings.Contains(h.mask(), "_")
+}
+
+// 猜错次数是否已用完
+func (h *hangman) failed() bool {
+	return h.misses >= h.maxMisses
+}
+
+// 按字母表顺序列出已经猜过的字母
+func (h *hangman) letters() string {
+	var b strings.Builder
+	for i, ok := range h.guessed {
+		if ok {
+			b.WriteByte(byte('a' + i))
+		}
+	}
+	return b.String()
+}
+
+// 消息中的单词状态
+func (h *hangman) state() *wordState {
+	return &wordState{Mask: h.mask(), Letters: h.letters(), Misses: h.misses, MaxMisses: h.maxMisses}
+}
+
+// 给只显示文字的客户端看的单词状态，如"_ p p _ e（猜错 2/6 次）"
+func (h *hangman) text() text {
+	return tr("word.state", strings.Join(strings.Split(h.mask(), ""), " "), h.misses, h.maxMisses)
+}
+
+// 猜单词玩法的判定：单个字母猜字母，多个字母猜整个单词，调用方需持有房间锁
+func (r *Room) judgeWord(player *Player, input string) (verdict, bool) {
+	s := strings.ToLower(strings.TrimSpace(input))
+	if !isLetters(s) {
+		return verdict{}, false
+	}
+	h := r.hangman
+	fb := feedbackMsg{Result: resultMiss, Guess: s, Player: player.name}
+	if len(s) == 1 {
+		hit, dup := h.guessLetter(s[0])
+		if dup {
+			fb.baseMsg = base(msgFeedback, tr("word.repeated", s))
+			fb.Result = resultRepeated
+			fb.Player = ""
+			fb.Word = h.state()
+			return verdict{feedback: fb}, true
+		}
+		fb.baseMsg = base(msgFeedback, tr("word.letter_miss", player.name, s))
+		if hit {
+			fb.Result = resultHit
+			fb.baseMsg = base(msgFeedback, tr("word.letter_hit", player.name, s))
+		}
+	} else {
+		fb.baseMsg = base(msgFeedback, tr("word.wrong", player.name, s))
+		h.guessWord(s)
+	}
+	fb.Word = h.state()
+	v := verdict{feedback: fb, counted: true, broadcast: true}
+	switch {
+	case h.solved():
+		v.feedback.Result = resultCorrect
+		v.solved = true
+	case h.failed():
+		v.failed = true
+	default:
+		v.feedback.add(tr("clause", h.text()))
+	}
+	return v, true
+}
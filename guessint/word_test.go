@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 依次猜字母和整个单词后的揭开状态、已猜字母和猜错次数
+func TestHangman(t *testing.T) {
+	type step struct {
+		guess       string // 单个字母猜字母，否则猜整个单词
+		hit, dup    bool   // 猜字母的返回值，猜单词时hit表示猜对
+		mask        string
+		letters     string
+		misses      int
+		solved, out bool
+	}
+	tests := []struct {
+		name  string
+		word  string
+		max   int
+		steps []step
+	}{
+		{
+			name: "letters until solved",
+			word: "apple", max: 3,
+			steps: []step{
+				{"p", true, false, "_pp__", "p", 0, false, false},
+				{"z", false, false, "_pp__", "pz", 1, false, false},
+				{"z", false, true, "_pp__", "pz", 1, false, false}, // 重复不算猜错
+				{"p", false, true, "_pp__", "pz", 1, false, false},
+				{"a", true, false, "app__", "apz", 1, false, false},
+				{"e", true, false, "app_e", "aepz", 1, false, false},
+				{"l", true, false, "apple", "aelpz", 1, true, false},
+			},
+		},
+		{
+			name: "whole word",
+			word: "mango", max: 6,
+			steps: []step{
+				{"o", true, false, "____o", "o", 0, false, false},
+				{"melon", false, false, "____o", "o", 1, false, false},
+				{"mango", true, false, "mango", "agmno", 1, true, false},
+			},
+		},
+		{
+			name: "out of misses",
+			word: "kiwi", max: 2,
+			steps: []step{
+				{"x", false, false, "____", "x", 1, false, false},
+				{"kiwis", false, false, "____", "x", 2, false, true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHangman(tt.word, tt.max)
+			if h.mask() != strings.Repeat("_", len(tt.word)) || h.solved() || h.failed() {
+				t.Fatalf("new round: mask %q, solved %v, failed %v", h.mask(), h.solved(), h.failed())
+			}
+			for _, st := range tt.steps {
+				var hit, dup bool
+				if len(st.guess) == 1 {
+					hit, dup = h.guessLetter(st.guess[0])
+				} else {
+					hit = h.guessWord(st.guess)
+				}
+				if hit != st.hit || dup != st.dup {
+					t.Errorf("%s: hit %v, dup %v, want %v, %v", st.guess, hit, dup, st.hit, st.dup)
+				}
+				got := step{st.guess, st.hit, st.dup, h.mask(), h.letters(), h.misses, h.solved(), h.failed()}
+				if got != st {
+					t.Errorf("after %s: %+v, want %+v", st.guess, got, st)
+				}
+			}
+			ws := h.state()
+			if ws.Mask != h.mask() || ws.Letters != h.letters() || ws.Misses != h.misses || ws.MaxMisses != tt.max {
+				t.Errorf("state = %+v, want mask %q, letters %q, misses %d/%d", ws, h.mask(), h.letters(), h.misses, tt.max)
+			}
+		})
+	}
+}
+
+// 词库文件跳过空行和注释并转成小写，含非字母的单词或没有单词时报错
+func TestLoadWords(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{"", defaultWords, false},
+		{write("ok.txt", "# fruits\nApple\n\n  pear \n"), []string{"apple", "pear"}, false},
+		{write("digit.txt", "apple\nb4nana\n"), nil, true},
+		{write("space.txt", "ice cream\n"), nil, true},
+		{write("empty.txt", "# nothing\n\n"), nil, true},
+		{filepath.Join(dir, "missing.txt"), nil, true},
+	}
+	for _, tt := range tests {
+		got, err := loadWords(tt.path)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("loadWords(%q) = %v, %v, want %v, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+	for _, w := range defaultWords {
+		if !isLetters(w) {
+			t.Errorf("built-in word %q is not all lowercase letters", w)
+		}
+	}
+}
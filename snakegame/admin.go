@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// 管理接口鉴权头
+const adminTokenHeader = "X-Admin-Token"
+
+// 管理接口鉴权：请求头中的令牌必须与ADMIN_TOKEN一致，或者 jwtAuth 已校验的令牌带有admin声明；
+// 两者都未配置时拒绝所有请求
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, ok := claimsFrom(c); ok && claims.Admin {
+			c.Next()
+			return
+		}
+		got := c.GetHeader(adminTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// 踢出玩家：保存分数，通知并关闭其连接，从房间移除
+// DELETE /admin/rooms/:room/players/:id
+func (s *GameServer) kickPlayer(c *gin.Context) {
+	room := s.findRoom(c.Param("room"))
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+
+	room.lock.Lock()
+	snake := room.players[c.Param("id")]
+	if snake == nil {
+		room.lock.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "player not found"})
+		return
+	}
+	if conn := snake.conn; conn != nil {
+		// 先解除连接，读循环退出时的detach因此不会进入保留期
+		snake.conn = nil
+		data, _ := json.Marshal(map[string]string{"type": "kicked"})
+		conn.sendText(data)
+		conn.closeWith(websocket.ClosePolicyViolation, "kicked")
+	}
+	room.lock.Unlock()
+
+	s.removePlayer(room, snake)
+	room.log.Info("player kicked by admin", "player", snake.ID, "name", snake.Name)
+	c.JSON(http.StatusOK, gin.H{"ok": true, "player": snake.ID})
+}
+
+// 关闭房间：通知所有连接，停止循环并从服务器移除
+// DELETE /admin/rooms/:room
+func (s *GameServer) closeRoom(c *gin.Context) {
+	name := c.Param("room")
+	s.lock.Lock()
+	room := s.rooms[name]
+	if room != nil {
+		delete(s.rooms, name)
+	}
+	s.lock.Unlock()
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+	if s.cluster != nil {
+		s.cluster.release(name)
+	}
+
+	conns := room.terminate(CauseAdminClose, "room_closed", websocket.CloseNormalClosure, "room closed by admin")
+	c.JSON(http.StatusOK, gin.H{"ok": true, "room": name, "connections": len(conns)})
+}
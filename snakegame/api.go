@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"golearn/snakegame/protocol"
+)
+
+// 房间列表中的一项
+type RoomInfo struct {
+	Name       string `json:"name"`
+	Width      int    `json:"w"`
+	Height     int    `json:"h"`
+	Players    int    `json:"players"` // 真人玩家数
+	Bots       int    `json:"bots"`
+	MaxPlayers int    `json:"max_players"`
+	Spectators int    `json:"spectators"`
+	Map        string `json:"map,omitempty"`
+	Wrap       bool   `json:"wrap"`
+	RunningSec int64  `json:"running_sec"` // 房间已运行秒数
+	Dormant    bool   `json:"dormant"`     // 没有存活的蛇，循环已降频
+	Locked     bool   `json:"locked"`      // 私人房间，加入和观战需要口令
+}
+
+// 房间概要，调用方需持有房间锁
+func (r *Room) infoLocked() RoomInfo {
+	return RoomInfo{
+		Name:       r.name,
+		Width:      r.width,
+		Height:     r.height,
+		Players:    r.humanCount(),
+		Bots:       len(r.players) - r.humanCount(),
+		MaxPlayers: r.maxPlay,
+		Spectators: len(r.watchers),
+		Map:        r.mapName,
+		Wrap:       r.wrap,
+		RunningSec: int64(time.Since(r.createdAt) / time.Second),
+		Dormant:    r.dormant,
+		Locked:     r.locked(),
+	}
+}
+
+// 当前所有房间，只在复制列表时持有服务器锁
+func (s *GameServer) roomList() []*Room {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	out := make([]*Room, 0, len(s.rooms))
+	for _, r := range s.rooms {
+		out = append(out, r)
+	}
+	return out
+}
+
+// 房间列表接口，?nonempty=1 只返回有玩家的房间，按玩家数降序
+func (s *GameServer) listRooms(c *gin.Context) {
+	nonEmpty := c.Query("nonempty") == "1"
+
+	out := []RoomInfo{}
+	for _, r := range s.roomList() {
+		r.lock.Lock()
+		info := r.infoLocked()
+		r.lock.Unlock()
+		if nonEmpty && info.Players == 0 {
+			continue
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Players != out[j].Players {
+			return out[i].Players > out[j].Players
+		}
+		return out[i].Name < out[j].Name
+	})
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// 查找房间，不存在时不创建
+func (s *GameServer) findRoom(name string) *Room {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.rooms[name]
+}
+
+// 房间快照，状态字段与WebSocket的state消息相同
+type roomSnapshot struct {
+	*protocol.State
+	Ticks       int64  `json:"ticks"`
+	ScoresSaved int    `json:"scores_saved"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// 只读快照：与WebSocket的state消息相同，另附运行统计
+func (r *Room) snapshot() roomSnapshot {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return roomSnapshot{
+		State:       r.stateMessage(),
+		Ticks:       r.tick,
+		ScoresSaved: r.scoresSaved,
+		CreatedAt:   r.createdAt.Format(time.RFC3339),
+	}
+}
+
+// 单个房间的实时状态接口，房间不存在返回404，私人房间口令错误返回403
+func (s *GameServer) roomStats(c *gin.Context) {
+	room := s.findRoom(c.Param("room"))
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+	if !requirePass(c, room) {
+		return
+	}
+	c.JSON(http.StatusOK, room.snapshot())
+}
+
+// 查询失败时计数并返回错误，超时返回504；客户端已断开时不计数
+func (s *GameServer) dbQueryFailed(c *gin.Context, op string, err error) {
+	if c.Request.Context().Err() != nil {
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	s.db.record(op, err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "db query timeout"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "db query error"})
+}
+
+// 玩家在某个房间的统计
+type PlayerRoomStats struct {
+	Room     string  `json:"room"`
+	Games    int     `json:"games"`
+	Best     int     `json:"best_score"`
+	Average  float64 `json:"avg_score"`
+	LastPlay string  `json:"last_play"`
+}
+
+// 玩家统计
+type PlayerStats struct {
+	PlayerID string            `json:"player_id"`
+	Games    int               `json:"games"`
+	Best     int               `json:"best_score"`
+	Average  float64           `json:"avg_score"`
+	LastPlay string            `json:"last_play"`
+	Rooms    []PlayerRoomStats `json:"rooms"`
+}
+
+// 玩家统计接口，没有任何记录时返回404
+func (s *GameServer) playerStats(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), s.cfg.DBReadTimeout)
+	defer cancel()
+
+	stats, found, err := s.store.PlayerStats(ctx, c.Param("id"))
+	if err != nil {
+		s.dbQueryFailed(c, "player stats", err)
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "player not found"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
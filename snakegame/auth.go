@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 可选的JWT鉴权（设置 AUTH_SECRET 时启用）：只接受HS256签名的令牌，
+// sub作为玩家的稳定ID写入snake_score，admin为true的令牌可以调用管理接口
+
+// 令牌中使用的声明
+type Claims struct {
+	Sub   string  `json:"sub"`            // 玩家ID
+	Name  string  `json:"name,omitempty"` // 显示昵称，为空时使用sub
+	Admin bool    `json:"admin,omitempty"`
+	Exp   float64 `json:"exp,omitempty"` // 过期时间（Unix秒），0表示不过期
+	Nbf   float64 `json:"nbf,omitempty"` // 生效时间（Unix秒）
+}
+
+// 与snake_score.player_id的列宽一致
+const maxSubjectLen = 50
+
+// gin上下文中保存声明的键
+const claimsKey = "auth.claims"
+
+var (
+	errTokenMissing   = errors.New("token required")
+	errTokenMalformed = errors.New("malformed token")
+	errTokenAlg       = errors.New("unsupported token algorithm")
+	errTokenSignature = errors.New("invalid token signature")
+	errTokenExpired   = errors.New("token expired")
+	errTokenNotYet    = errors.New("token not yet valid")
+	errTokenSubject   = errors.New("invalid token subject")
+)
+
+// 校验HS256令牌并返回其中的声明
+func verifyToken(token string, secret []byte, now time.Time) (Claims, error) {
+	var claims Claims
+	if token == "" {
+		return claims, errTokenMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errTokenMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, errTokenMalformed
+	}
+	// 拒绝alg=none等未签名或非对称算法的令牌
+	if header.Alg != "HS256" {
+		return claims, errTokenAlg
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, errTokenMalformed
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return claims, errTokenSignature
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, errTokenMalformed
+	}
+	unix := float64(now.Unix())
+	if claims.Exp != 0 && unix >= claims.Exp {
+		return claims, errTokenExpired
+	}
+	if claims.Nbf != 0 && unix < claims.Nbf {
+		return claims, errTokenNotYet
+	}
+	if claims.Sub == "" || len(claims.Sub) > maxSubjectLen {
+		return claims, errTokenSubject
+	}
+	return claims, nil
+}
+
+// 解码base64url编码的JSON段
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// 请求中的令牌：Authorization: Bearer <jwt> 优先，其次是 ?token=
+func requestToken(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	return c.Query("token")
+}
+
+// 校验WebSocket连接的 ?token=，未启用鉴权时返回零值
+func (s *GameServer) authenticate(q url.Values) (Claims, error) {
+	if s.authSecret == nil {
+		return Claims{}, nil
+	}
+	return verifyToken(q.Get("token"), s.authSecret, time.Now())
+}
+
+// 解析请求中的令牌并存入上下文，供后续处理函数用 claimsFrom 查询；
+// 没有令牌时照常放行，令牌无效时返回401
+func jwtAuth(secret []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := requestToken(c)
+		if secret == nil || token == "" {
+			c.Next()
+			return
+		}
+		claims, err := verifyToken(token, secret, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// jwtAuth 存入的声明，请求未携带有效令牌时ok为false
+func claimsFrom(c *gin.Context) (Claims, bool) {
+	v, ok := c.Get(claimsKey)
+	if !ok {
+		return Claims{}, false
+	}
+	claims, ok := v.(Claims)
+	return claims, ok
+}
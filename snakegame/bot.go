@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golearn/snakegame/protocol"
+)
+
+// 每个房间最多的机器人数量
+const maxBots = 4
+
+// 机器人死亡后重生的等待时间
+const botRespawnDelay = 3 * time.Second
+
+// 添加一条机器人蛇，调用方需持有房间锁
+func (r *Room) addBot() {
+	r.nextID++
+	id := fmt.Sprintf("B%d", r.nextID)
+	body, dir := r.spawnPlacement()
+	bot := &Snake{Player: protocol.Player{
+		ID:    id,
+		Name:  r.uniqueName("Bot"),
+		Body:  body,
+		Dir:   dir,
+		Alive: len(body) > 0,
+		Color: r.colors.alloc(),
+		Bot:   true,
+	}}
+	r.startSpawn(bot)
+	r.players[id] = bot
+}
+
+// 真人玩家数量（不含机器人），调用方需持有房间锁
+func (r *Room) humanCount() int {
+	n := 0
+	for _, s := range r.players {
+		if !s.Bot {
+			n++
+		}
+	}
+	return n
+}
+
+// 每tick驱动机器人：存活的选择方向，死亡的在无尽模式下倒计时重生，调用方需持有房间锁
+func (r *Room) driveBots() {
+	for _, s := range r.sortedPlayers() {
+		if !s.Bot {
+			continue
+		}
+		if !s.Alive {
+			if r.match == nil {
+				r.botRespawnTick(s)
+			}
+			continue
+		}
+		if s.Spawning || len(s.Body) == 0 {
+			continue
+		}
+		s.pending = []string{r.botDir(s)}
+	}
+}
+
+// 死亡机器人的重生倒计时，到期后在安全位置重生
+func (r *Room) botRespawnTick(s *Snake) {
+	if s.respawnIn == 0 {
+		s.respawnIn = int(botRespawnDelay/r.interval) + 1
+	}
+	s.respawnIn--
+	if s.respawnIn > 0 {
+		return
+	}
+	body, dir := r.spawnPlacement()
+	if len(body) == 0 {
+		s.respawnIn = 1 // 棋盘已满，下个tick再试
+		return
+	}
+	s.Body, s.Dir = body, dir
+	s.Score = 0
+	s.Alive = true
+	s.pending = nil
+	s.moveAcc = 0
+	r.startSpawn(s)
+}
+
+// 机器人的决策：朝最近的食物前进，排除下一步会撞墙、障碍物或蛇身的方向，
+// 距离相同时随机选择；无路可走时保持原方向
+func (r *Room) botDir(s *Snake) string {
+	head := s.Body[0]
+	target, hasFood := r.nearestFood(head)
+
+	dirs := []string{"up", "down", "left", "right"}
+	r.rng.Shuffle(len(dirs), func(i, j int) { dirs[i], dirs[j] = dirs[j], dirs[i] })
+
+	best, bestDist := "", 0
+	for _, d := range dirs {
+		if len(s.Body) > 1 && d == opposite[s.Dir] {
+			continue
+		}
+		next := r.nextHead(head, d)
+		if !r.safeCell(next) {
+			continue
+		}
+		dist := 0
+		if hasFood {
+			dist = r.distance(next, target)
+		}
+		if best == "" || dist < bestDist {
+			best, bestDist = d, dist
+		}
+	}
+	if best == "" {
+		return s.Dir
+	}
+	return best
+}
+
+// 距离p最近的食物
+func (r *Room) nearestFood(p Point) (Point, bool) {
+	var best Point
+	found := false
+	for _, f := range r.foods {
+		if f.Kind == FoodShrink {
+			continue
+		}
+		if !found || r.distance(p, f.Point) < r.distance(p, best) {
+			best, found = f.Point, true
+		}
+	}
+	return best, found
+}
+
+// 两点间的曼哈顿距离，环形地图按较短的一侧计算
+func (r *Room) distance(a, b Point) int {
+	dx, dy := abs(a.X-b.X), abs(a.Y-b.Y)
+	if r.wrap {
+		dx = min(dx, r.width-dx)
+		dy = min(dy, r.height-dy)
+	}
+	return dx + dy
+}
+
+// 蛇头进入p是否安全：不出界、不是障碍物、不在任何蛇身上（保守地包含会腾出的尾巴）
+func (r *Room) safeCell(p Point) bool {
+	if p.X < 0 || p.X >= r.width || p.Y < 0 || p.Y >= r.height || r.isObstacle(p) || !r.inBounds(p) {
+		return false
+	}
+	for _, o := range r.players {
+		if o.Spawning {
+			continue
+		}
+		for _, b := range o.Body {
+			if b == p {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 机器人在构造的棋盘上的选择：朝最近的食物，避开墙和蛇身，无路可走时保持原方向
+func TestBotDir(t *testing.T) {
+	p := func(x, y int) Point { return Point{X: x, Y: y} }
+	tests := []struct {
+		name   string
+		bot    *Snake
+		others []*Snake
+		foods  []Point
+		want   []string // 可以接受的方向，多个表示随机选择其中之一
+	}{
+		{
+			name:  "food ahead",
+			bot:   snakeAt("B", "right", p(3, 5), p(2, 5), p(1, 5)),
+			foods: []Point{p(8, 5)},
+			want:  []string{"right"},
+		},
+		{
+			name:  "nearest food",
+			bot:   snakeAt("B", "right", p(5, 5), p(4, 5), p(3, 5)),
+			foods: []Point{p(9, 5), p(5, 3)},
+			want:  []string{"up"},
+		},
+		{
+			name:  "wall ahead",
+			bot:   snakeAt("B", "right", p(9, 5), p(8, 5), p(7, 5)),
+			foods: []Point{p(9, 0)},
+			want:  []string{"up"},
+		},
+		{
+			name:   "body ahead",
+			bot:    snakeAt("B", "right", p(5, 5), p(4, 5), p(3, 5)),
+			others: []*Snake{snakeAt("P1", "down", p(6, 6), p(6, 5), p(6, 4))},
+			foods:  []Point{p(8, 5)},
+			want:   []string{"up", "down"},
+		},
+		{
+			name: "no food",
+			bot:  snakeAt("B", "right", p(5, 5), p(4, 5), p(3, 5)),
+			want: []string{"up", "down", "right"},
+		},
+		{
+			name:   "boxed in",
+			bot:    snakeAt("B", "up", p(0, 0), p(0, 1), p(0, 2)),
+			others: []*Snake{snakeAt("P1", "right", p(2, 0), p(1, 0))},
+			foods:  []Point{p(5, 5)},
+			want:   []string{"up"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := map[string]bool{}
+			for seed := int64(0); seed < 30; seed++ {
+				tt.bot.Bot = true
+				r := newMoveRoom(10, 10, append([]*Snake{tt.bot}, tt.others...)...)
+				for _, f := range tt.foods {
+					r.foods = append(r.foods, Food{Point: f})
+				}
+				r.rng = rand.New(rand.NewSource(seed))
+				got := r.botDir(tt.bot)
+				if !slices.Contains(tt.want, got) {
+					t.Fatalf("seed %d: botDir = %s, want one of %v", seed, got, tt.want)
+				}
+				seen[got] = true
+			}
+			// 距离相同的方向随机选择，多个种子下都会出现
+			if len(seen) != len(tt.want) {
+				t.Errorf("directions chosen = %v, want all of %v", seen, tt.want)
+			}
+		})
+	}
+}
+
+// 死亡的机器人在botRespawnDelay后重生，分数清零
+func TestBotRespawn(t *testing.T) {
+	bot := snakeAt("B", "right")
+	bot.Bot, bot.Alive, bot.Score = true, false, 7
+	r := newMoveRoom(10, 10, bot)
+	r.interval = 200 * time.Millisecond
+	r.rng = rand.New(rand.NewSource(1))
+
+	wait := int(botRespawnDelay / r.interval)
+	for i := 0; i < wait; i++ {
+		r.driveBots()
+		if bot.Alive {
+			t.Fatalf("respawned after %d ticks, want %d", i+1, wait+1)
+		}
+	}
+	r.driveBots()
+	if !bot.Alive || len(bot.Body) != spawnLength || bot.Score != 0 || !bot.Spawning {
+		t.Errorf("after %d ticks: alive=%v body=%v score=%d spawning=%v", wait+1, bot.Alive, bot.Body, bot.Score, bot.Spawning)
+	}
+}
+
+// 机器人的分数不写入存储
+func TestBotScoreNotSaved(t *testing.T) {
+	bot := snakeAt("B", "right", Point{X: 1, Y: 1})
+	bot.Bot = true
+	r := newMoveRoom(10, 10, bot)
+	if r.queueScore(bot, CauseWall, nil, nil) {
+		t.Error("queueScore for a bot = true, want false")
+	}
+}
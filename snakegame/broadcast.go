@@ -0,0 +1,28 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 状态没有变化时，至少每隔该时长仍广播一帧，让客户端知道服务器还在运行
+const keepaliveInterval = 5 * time.Second
+
+// 状态帧统计
+type frameStats struct {
+	sent       atomic.Int64 // 已广播的状态帧（按房间计，不按连接）
+	suppressed atomic.Int64 // 因状态未变化而跳过的状态帧
+}
+
+// 本tick是否需要广播状态帧：移动、得分、死亡、加入、离开、食物和道具增减等都会设置dirty，
+// 没有变化时跳过，但距上次广播超过keepaliveInterval时仍发送一帧。调用方需持有房间锁
+func (r *Room) shouldBroadcast(now time.Time) bool {
+	if !r.dirty && now.Sub(r.lastFrame) < keepaliveInterval {
+		r.frames.suppressed.Add(1)
+		return false
+	}
+	r.dirty = false
+	r.lastFrame = now
+	r.frames.sent.Add(1)
+	return true
+}
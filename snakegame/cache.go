@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 排行榜缓存默认有效期
+const defaultRankCacheTTL = 5 * time.Second
+
+// 缓存条目数超过该值时清理过期条目
+const rankCacheSweepSize = 1000
+
+// 一页排行榜结果
+type rankPage struct {
+	Rows  []RankRow
+	Total int
+}
+
+// 缓存条目，ready关闭前表示查询进行中
+type rankEntry struct {
+	ready    chan struct{}
+	page     rankPage
+	err      error
+	cachedAt time.Time
+}
+
+// 排行榜进程内缓存：TTL内直接返回旧结果（不随新分数失效），
+// 相同key的并发请求只触发一次查询
+type rankCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*rankEntry
+}
+
+// 创建排行榜缓存
+func newRankCache(ttl time.Duration) *rankCache {
+	return &rankCache{ttl: ttl, entries: make(map[string]*rankEntry)}
+}
+
+// 读取缓存，缺失或过期时调用load；返回结果及其查询时间
+func (c *rankCache) get(key string, load func() (rankPage, error)) (rankPage, time.Time, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		select {
+		case <-e.ready:
+			if e.err == nil && time.Since(e.cachedAt) < c.ttl {
+				c.mu.Unlock()
+				return e.page, e.cachedAt, nil
+			}
+		default:
+			// 已有相同查询在进行，等待其结果
+			c.mu.Unlock()
+			<-e.ready
+			return e.page, e.cachedAt, e.err
+		}
+	}
+	if len(c.entries) >= rankCacheSweepSize {
+		c.sweepLocked()
+	}
+	e := &rankEntry{ready: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.page, e.err = load()
+	e.cachedAt = time.Now()
+	close(e.ready)
+
+	if e.err != nil {
+		c.mu.Lock()
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	return e.page, e.cachedAt, e.err
+}
+
+// 清理已过期的条目，调用方需持有c.mu
+func (c *rankCache) sweepLocked() {
+	for k, e := range c.entries {
+		select {
+		case <-e.ready:
+			if time.Since(e.cachedAt) >= c.ttl {
+				delete(c.entries, k)
+			}
+		default:
+		}
+	}
+}
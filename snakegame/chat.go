@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 聊天限制
+const (
+	maxChatLen = 200 // 单条消息最多字符数
+	chatRate   = 1   // 每秒允许的聊天条数
+	chatBurst  = 3   // 允许的突发条数
+)
+
+// 广播给房间的聊天消息
+type ChatMsg struct {
+	Type string `json:"type"`
+	From string `json:"from"` // 玩家ID
+	Name string `json:"name"` // 玩家昵称
+	Text string `json:"text"`
+	TS   int64  `json:"ts"` // 毫秒时间戳
+}
+
+// 处理聊天消息：校验长度和频率后通过各连接的发送队列广播给房间内所有人，
+// 不修改游戏状态；返回需要回复给发送者的错误，nil表示成功
+func (r *Room) chat(conn *Conn, snake *Snake, text string) interface{} {
+	if snake == nil {
+		return errorReply("forbidden", "spectators cannot chat")
+	}
+	text = strings.TrimSpace(strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) {
+			return -1
+		}
+		return c
+	}, text))
+	if text == "" {
+		return errorReply("bad_chat", "empty chat message")
+	}
+	if utf8.RuneCountInString(text) > maxChatLen {
+		return errorReply("chat_too_long", "chat message exceeds %d characters", maxChatLen)
+	}
+	if !conn.chatLimit.Allow() {
+		return errorReply("rate_limited", "too many chat messages, limit is %d/s", chatRate)
+	}
+
+	r.lock.Lock()
+	r.emit(ChatMsg{
+		Type: MsgChat,
+		From: snake.ID,
+		Name: snake.Name,
+		Text: text,
+		TS:   time.Now().UnixMilli(),
+	})
+	r.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,368 @@
+// Package client 是贪吃蛇服务器的Go客户端库，用于编写机器人：
+// 把服务器消息解析成 protocol 包中的类型交给回调，自动回复延迟探测，
+// 开启重连后在连接意外断开时凭会话令牌（?resume=）接管原来的蛇
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"golearn/snakegame/protocol"
+)
+
+// 重连参数的默认值
+const (
+	defaultMaxRetries = 5
+	defaultRetryDelay = time.Second
+	writeWait         = 5 * time.Second // 单次写超时
+)
+
+var (
+	// 方向与当前方向相反，服务器会忽略这样的指令
+	ErrReverse = errors.New("client: cannot reverse direction")
+	// 连接已关闭或正在重连
+	ErrClosed = errors.New("client: connection closed")
+)
+
+// 连接选项。回调都在同一个读协程中依次调用，回调内可以调用 SendDir，
+// 但不应长时间阻塞，否则会耽误后续消息和延迟探测的回复
+type Options struct {
+	Name  string // 昵称，为空时由服务器分配
+	Pass  string // 私人房间的口令
+	Token string // JWT，服务器设置了 AUTH_SECRET 时需要
+
+	Reconnect  bool          // 连接意外断开后自动重连
+	MaxRetries int           // 每次断开后最多重试的次数，0表示默认值
+	RetryDelay time.Duration // 重试间隔，0表示默认值
+
+	Dialer *websocket.Dialer // 为nil时使用 websocket.DefaultDialer
+	Header http.Header       // 握手时附加的请求头，如 Origin
+
+	OnWelcome func(c *Client, w *protocol.Welcome)
+	OnState   func(c *Client, st *protocol.State)
+	OnDeath   func(c *Client, ev *protocol.DeathEvent)
+	OnError   func(c *Client, e *protocol.ErrorMsg)
+}
+
+// 一个到服务器的WebSocket连接
+type Client struct {
+	url  url.URL
+	opts Options
+
+	mu     sync.Mutex // 保护以下字段，并保证同一时间只有一个写操作
+	ws     *websocket.Conn
+	player string       // 欢迎消息中的玩家ID
+	token  string       // 会话令牌，重连时使用
+	dir    protocol.Dir // 最近一帧状态中自己的方向
+	sent   protocol.Dir // 该帧之后已发送的方向，为空表示没有
+	closed bool
+
+	done chan struct{}
+	err  error // done关闭后有效
+}
+
+// 连接到服务器，rawurl形如 ws://localhost:8080/ws/lobby?w=40，
+// 查询串中的房间参数原样保留，昵称、口令和令牌取自opts。
+// 握手成功后在后台读取消息，Done在连接最终结束时关闭
+func Dial(rawurl string, opts Options) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("client: parse url: %w", err)
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultMaxRetries
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = defaultRetryDelay
+	}
+	if opts.Dialer == nil {
+		opts.Dialer = websocket.DefaultDialer
+	}
+	c := &Client{url: *u, opts: opts, done: make(chan struct{})}
+	ws, err := c.dial("")
+	if err != nil {
+		return nil, err
+	}
+	c.ws = ws
+	go c.run(ws)
+	return c, nil
+}
+
+// 建立一次连接，resume不为空时带上 ?resume= 接管原来的蛇
+func (c *Client) dial(resume string) (*websocket.Conn, error) {
+	u := c.url
+	q := u.Query()
+	if c.opts.Name != "" {
+		q.Set("name", c.opts.Name)
+	}
+	if c.opts.Pass != "" {
+		q.Set("pass", c.opts.Pass)
+	}
+	if c.opts.Token != "" {
+		q.Set("token", c.opts.Token)
+	}
+	if resume != "" {
+		q.Set("resume", resume)
+	}
+	u.RawQuery = q.Encode()
+	ws, resp, err := c.opts.Dialer.Dial(u.String(), c.opts.Header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("client: dial: %w (HTTP %d)", err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("client: dial: %w", err)
+	}
+	return ws, nil
+}
+
+// 读循环，连接断开后按设置重连，最终结束时关闭done
+func (c *Client) run(ws *websocket.Conn) {
+	for {
+		err := c.read(ws)
+		ws.Close()
+
+		c.mu.Lock()
+		closed, token := c.closed, c.token
+		c.ws = nil
+		c.mu.Unlock()
+		if closed {
+			c.finish(nil)
+			return
+		}
+		if !c.opts.Reconnect || isFinal(err) {
+			c.finish(err)
+			return
+		}
+		if ws = c.redial(token); ws == nil {
+			c.mu.Lock()
+			if c.closed {
+				err = nil
+			}
+			c.mu.Unlock()
+			c.finish(err)
+			return
+		}
+	}
+}
+
+// 按重试次数和间隔重新连接，期间被关闭或全部失败时返回nil
+func (c *Client) redial(token string) *websocket.Conn {
+	for i := 0; i < c.opts.MaxRetries; i++ {
+		time.Sleep(c.opts.RetryDelay)
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+		if closed {
+			return nil
+		}
+		ws, err := c.dial(token)
+		if err != nil {
+			continue
+		}
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			ws.Close()
+			return nil
+		}
+		c.ws = ws
+		c.mu.Unlock()
+		return ws
+	}
+	return nil
+}
+
+// 服务器有意的关闭不再重连：口令错误、房间已满、房间被关闭等；
+// 网络错误、服务器重启（1001）和房间迁移（1013）仍会重连
+func isFinal(err error) bool {
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	switch ce.Code {
+	case websocket.CloseAbnormalClosure, websocket.CloseGoingAway, websocket.CloseTryAgainLater:
+		return false
+	}
+	return true
+}
+
+// 记录结束原因并关闭done
+func (c *Client) finish(err error) {
+	c.err = err
+	close(c.done)
+}
+
+// 读取并分发消息直到连接出错
+func (c *Client) read(ws *websocket.Conn) error {
+	for {
+		mt, data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		// 二进制帧只在 ?enc=bin 时出现，本库使用JSON
+		if mt != websocket.TextMessage {
+			continue
+		}
+		c.handle(data)
+	}
+}
+
+// 按type字段解码一条服务器消息并调用对应的回调，其他类型的消息忽略
+func (c *Client) handle(data []byte) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(data, &head) != nil {
+		return
+	}
+	switch head.Type {
+	case protocol.TypeWelcome:
+		var w protocol.Welcome
+		if json.Unmarshal(data, &w) != nil {
+			return
+		}
+		c.mu.Lock()
+		c.player, c.token = w.Player, w.Token
+		c.dir, c.sent = c.ownDir(w.Players), ""
+		c.mu.Unlock()
+		if c.opts.OnWelcome != nil {
+			c.opts.OnWelcome(c, &w)
+		}
+	case protocol.TypeState:
+		var st protocol.State
+		if json.Unmarshal(data, &st) != nil {
+			return
+		}
+		c.mu.Lock()
+		c.dir, c.sent = c.ownDir(st.Players), ""
+		c.mu.Unlock()
+		if c.opts.OnState != nil {
+			c.opts.OnState(c, &st)
+		}
+	case protocol.TypeDeath:
+		var ev protocol.DeathEvent
+		if json.Unmarshal(data, &ev) != nil {
+			return
+		}
+		if c.opts.OnDeath != nil {
+			c.opts.OnDeath(c, &ev)
+		}
+	case protocol.TypePing:
+		var p protocol.PingMsg
+		if json.Unmarshal(data, &p) != nil {
+			return
+		}
+		_ = c.send(protocol.ClientMsg{Type: protocol.MsgPong, ID: p.ID})
+	case protocol.TypeError:
+		var e protocol.ErrorMsg
+		if json.Unmarshal(data, &e) != nil {
+			return
+		}
+		if c.opts.OnError != nil {
+			c.opts.OnError(c, &e)
+		}
+	}
+}
+
+// 自己的蛇在玩家列表中的方向，调用方需持有c.mu
+func (c *Client) ownDir(players map[string]*protocol.Player) protocol.Dir {
+	if p := players[c.player]; p != nil {
+		return protocol.Dir(p.Dir)
+	}
+	return ""
+}
+
+// 发送一条消息
+func (c *Client) send(msg protocol.ClientMsg) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeLocked(msg)
+}
+
+// 写出一条消息，调用方需持有c.mu
+func (c *Client) writeLocked(msg protocol.ClientMsg) error {
+	if c.ws == nil || c.closed {
+		return ErrClosed
+	}
+	_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.ws.WriteJSON(msg)
+}
+
+// 改变方向。与当前方向（或本帧已发送的方向）相反时返回 ErrReverse，
+// 与之相同时不发送
+func (c *Client) SendDir(d protocol.Dir) error {
+	if !d.Valid() {
+		return fmt.Errorf("client: invalid direction %q", d)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last := c.dir
+	if c.sent != "" {
+		last = c.sent
+	}
+	if d == last {
+		return nil
+	}
+	if d == last.Opposite() {
+		return ErrReverse
+	}
+	if err := c.writeLocked(protocol.ClientMsg{Type: protocol.MsgDir, Dir: string(d)}); err != nil {
+		return err
+	}
+	c.sent = d
+	return nil
+}
+
+// 发送聊天消息
+func (c *Client) Chat(text string) error {
+	return c.send(protocol.ClientMsg{Type: protocol.MsgChat, Text: text})
+}
+
+// 欢迎消息中的玩家ID，收到欢迎消息之前为空
+func (c *Client) Player() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.player
+}
+
+// 连接最终结束（关闭、被拒绝或重连失败）时关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// 连接结束的原因，主动关闭时为nil；Done关闭之前调用返回nil
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// 关闭连接，不再重连
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	ws := c.ws
+	if ws != nil {
+		_ = ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+	}
+	c.mu.Unlock()
+	if ws != nil {
+		return ws.Close()
+	}
+	return nil
+}
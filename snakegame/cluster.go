@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 多实例模式（设置 REDIS_ADDR 时启用）：每个房间的游戏循环只在一个实例（房主）上运行，
+// 归属通过 SET room:<name>:owner <实例ID> NX PX 抢占（即带TTL的SETNX），房主定期续期。
+// 连到其他实例的客户端由该实例转发：入站消息发布到 room:<name>:input，
+// 房主为每个远程客户端创建远程Conn，广播等发给它的消息发布到 room:<name>:state，
+// 各实例把其中属于本地连接的消息转给客户端。
+
+// 房间归属的有效期和续期间隔
+const (
+	ownerTTL     = 10 * time.Second
+	ownerRefresh = ownerTTL / 3
+)
+
+// 续期脚本：仍是房主或归属已过期时重新设置，被其他实例占用时返回0
+const refreshScript = `local v = redis.call('get', KEYS[1])
+if v == false or v == ARGV[1] then
+	redis.call('set', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`
+
+// 释放脚本：只删除自己持有的归属
+const releaseScript = `if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('del', KEYS[1])
+end
+return 0`
+
+// Redis键和频道名
+func ownerKey(room string) string     { return "room:" + room + ":owner" }
+func inputChannel(room string) string { return "room:" + room + ":input" }
+func stateChannel(room string) string { return "room:" + room + ":state" }
+
+// 转发实例发给房主的消息
+type clusterInput struct {
+	Op    string `json:"op"`              // join、msg 或 leave
+	Conn  string `json:"conn"`            // 远程连接键：<实例ID>/<序号>
+	Query string `json:"query,omitempty"` // join：客户端的URL参数
+	Data  string `json:"data,omitempty"`  // msg：客户端消息原文
+}
+
+// 房主发给远程连接的消息
+type clusterOutput struct {
+	Conn string `json:"conn"`           // 远程连接键
+	MT   int    `json:"mt"`             // WebSocket消息类型
+	Data []byte `json:"data,omitempty"` // 消息内容
+}
+
+// 本实例转发的客户端连接
+type relayConn struct {
+	room string
+	conn *Conn
+}
+
+// 本实例房间中来自其他实例的客户端
+type remotePlayer struct {
+	room  *Room
+	snake *Snake // 观战者为nil
+	conn  *Conn
+}
+
+// 多实例协调器
+type cluster struct {
+	id     string // 本实例ID
+	server *GameServer
+	rdb    *redisClient
+	sub    *redisSub
+	seq    atomic.Int64 // 转发连接序号
+	stop   chan struct{}
+
+	// Redis不可用时是否在本地运行房间。默认拒绝加入，因为无法确认归属时在本地运行，
+	// 其他实例可能同时运行同名房间
+	localFallback bool
+
+	mu      sync.Mutex
+	relayed map[string]*relayConn    // 连接键 -> 本实例转发的连接
+	remotes map[string]*remotePlayer // 连接键 -> 本实例房间中的远程客户端
+}
+
+// 连接Redis并启动订阅和续期协程
+func newCluster(server *GameServer, addr string) (*cluster, error) {
+	cl := &cluster{
+		id:      newToken()[:16],
+		server:  server,
+		rdb:     newRedisClient(addr),
+		stop:    make(chan struct{}),
+		relayed: make(map[string]*relayConn),
+		remotes: make(map[string]*remotePlayer),
+
+		localFallback: server.cfg.ClusterLocalFallback,
+	}
+	if _, err := cl.rdb.do("PING"); err != nil {
+		return nil, err
+	}
+	sub, err := newRedisSub(addr, cl.dispatch)
+	if err != nil {
+		return nil, err
+	}
+	cl.sub = sub
+	go cl.refreshLoop()
+	return cl, nil
+}
+
+// 抢占房间归属，本实例已是房主或抢占成功时返回true。
+// 无法确认归属（Redis不可用）时返回错误，开启localFallback时改为在本地运行
+func (cl *cluster) claim(name string) (bool, error) {
+	ttl := strconv.FormatInt(ownerTTL.Milliseconds(), 10)
+	err := fmt.Errorf("ownership of room %s keeps expiring", name)
+	for i := 0; i < 3; i++ {
+		var reply, owner interface{}
+		if reply, err = cl.rdb.do("SET", ownerKey(name), cl.id, "NX", "PX", ttl); err != nil {
+			break
+		}
+		if reply == "OK" {
+			return true, nil
+		}
+		if owner, err = cl.rdb.do("GET", ownerKey(name)); err != nil {
+			break
+		}
+		if owner != nil {
+			return owner == cl.id, nil
+		}
+		// 归属恰好过期，重新抢占
+	}
+	if cl.localFallback {
+		slog.Warn("redis unavailable, running room locally", "room", name, "err", err)
+		return true, nil
+	}
+	return false, err
+}
+
+// 本实例开始运行房间：订阅输入频道。会访问Redis，调用方不能持有服务器锁或房间锁
+func (cl *cluster) own(name string) {
+	cl.sub.subscribe(inputChannel(name))
+}
+
+// 本实例不再运行房间：退订输入频道、释放归属并丢弃远程客户端。
+// 会访问Redis，调用方不能持有服务器锁或房间锁
+func (cl *cluster) release(name string) {
+	cl.sub.unsubscribe(inputChannel(name))
+	if _, err := cl.rdb.do("EVAL", releaseScript, "1", ownerKey(name), cl.id); err != nil {
+		slog.Warn("release room ownership failed", "room", name, "err", err)
+	}
+	cl.mu.Lock()
+	for key, rp := range cl.remotes {
+		if rp.room.name == name {
+			delete(cl.remotes, key)
+		}
+	}
+	cl.mu.Unlock()
+}
+
+// 发布一条消息，值序列化为JSON
+func (cl *cluster) publish(channel string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = cl.rdb.do("PUBLISH", channel, string(data))
+	return err
+}
+
+// 转发连接到其他实例运行的房间，直到连接断开
+func (cl *cluster) relay(name string, q url.Values, conn *Conn) {
+	key := fmt.Sprintf("%s/%d", cl.id, cl.seq.Add(1))
+	cl.mu.Lock()
+	cl.relayed[key] = &relayConn{room: name, conn: conn}
+	cl.mu.Unlock()
+	// 先订阅再加入，避免漏掉欢迎信息
+	cl.sub.subscribe(stateChannel(name))
+	defer func() {
+		cl.mu.Lock()
+		delete(cl.relayed, key)
+		cl.mu.Unlock()
+		cl.sub.unsubscribe(stateChannel(name))
+		_ = cl.publish(inputChannel(name), clusterInput{Op: "leave", Conn: key})
+		conn.Close()
+	}()
+
+	if err := cl.publish(inputChannel(name), clusterInput{Op: "join", Conn: key, Query: q.Encode()}); err != nil {
+		conn.logger().Warn("relay join failed", "err", err)
+		conn.closeWith(websocket.CloseTryAgainLater, "room unavailable")
+		return
+	}
+	// 限流在本实例完成，房主直接处理收到的消息
+	readLimited(conn, func(msg []byte) {
+		if err := cl.publish(inputChannel(name), clusterInput{Op: "msg", Conn: key, Data: string(msg)}); err != nil {
+			conn.logger().Warn("relay message failed", "err", err)
+		}
+	})
+}
+
+// 处理订阅到的消息，在订阅读协程中调用
+func (cl *cluster) dispatch(channel string, payload []byte) {
+	switch {
+	case strings.HasSuffix(channel, ":input"):
+		var in clusterInput
+		if err := json.Unmarshal(payload, &in); err != nil {
+			slog.Warn("bad cluster input", "channel", channel, "err", err)
+			return
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(channel, "room:"), ":input")
+		cl.handleInput(name, in)
+	case strings.HasSuffix(channel, ":state"):
+		var out clusterOutput
+		if err := json.Unmarshal(payload, &out); err != nil {
+			slog.Warn("bad cluster output", "channel", channel, "err", err)
+			return
+		}
+		cl.deliver(out)
+	}
+}
+
+// 房主处理转发实例发来的加入、消息和离开
+func (cl *cluster) handleInput(name string, in clusterInput) {
+	switch in.Op {
+	case "join":
+		sink := func(mt int, data []byte) error {
+			return cl.publish(stateChannel(name), clusterOutput{Conn: in.Conn, MT: mt, Data: data})
+		}
+		if local, err := cl.claim(name); err != nil {
+			slog.Warn("claim room failed", "room", name, "relay", in.Conn, "err", err)
+			_ = sink(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "cluster unavailable"))
+			return
+		} else if !local {
+			// 房间已由其他实例接管，让客户端重连
+			_ = sink(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "room moved"))
+			return
+		}
+		q, _ := url.ParseQuery(in.Query)
+		room, snake, conn := cl.server.join(name, q, func(stall time.Duration) *Conn {
+			conn := newRemoteConn(sink, stall)
+			conn.setLogger(slog.With("room", name, "relay", in.Conn))
+			return conn
+		})
+		if room == nil {
+			return
+		}
+		cl.mu.Lock()
+		cl.remotes[in.Conn] = &remotePlayer{room: room, snake: snake, conn: conn}
+		cl.mu.Unlock()
+	case "msg":
+		cl.mu.Lock()
+		rp := cl.remotes[in.Conn]
+		cl.mu.Unlock()
+		if rp != nil {
+			rp.room.handleMessage(rp.conn, rp.snake, []byte(in.Data))
+		}
+	case "leave":
+		cl.mu.Lock()
+		rp := cl.remotes[in.Conn]
+		delete(cl.remotes, in.Conn)
+		cl.mu.Unlock()
+		if rp == nil {
+			return
+		}
+		if rp.snake == nil {
+			cl.server.unwatch(rp.room, rp.conn)
+		} else {
+			cl.server.detach(rp.room, rp.snake, rp.conn)
+		}
+	}
+}
+
+// 把房主发来的消息交给本地连接，不属于本实例的忽略
+func (cl *cluster) deliver(out clusterOutput) {
+	if !strings.HasPrefix(out.Conn, cl.id+"/") {
+		return
+	}
+	cl.mu.Lock()
+	rc := cl.relayed[out.Conn]
+	cl.mu.Unlock()
+	if rc == nil {
+		return
+	}
+	if out.MT == websocket.CloseMessage && len(out.Data) == 0 {
+		// 房主侧连接已关闭
+		rc.conn.Close()
+		return
+	}
+	rc.conn.enqueue(out.MT, out.Data)
+}
+
+// 定期续期本实例的房间归属；转发中的房间若已没有房主（房主实例退出），
+// 关闭对应的本地连接，客户端重连后由某个实例重新抢占
+func (cl *cluster) refreshLoop() {
+	ticker := time.NewTicker(ownerRefresh)
+	defer ticker.Stop()
+	ttl := strconv.FormatInt(ownerTTL.Milliseconds(), 10)
+	for {
+		select {
+		case <-ticker.C:
+		case <-cl.stop:
+			return
+		}
+
+		cl.server.lock.Lock()
+		owned := make([]string, 0, len(cl.server.rooms))
+		for name := range cl.server.rooms {
+			owned = append(owned, name)
+		}
+		cl.server.lock.Unlock()
+		for _, name := range owned {
+			reply, err := cl.rdb.do("EVAL", refreshScript, "1", ownerKey(name), cl.id, ttl)
+			if err != nil {
+				slog.Warn("refresh room ownership failed", "room", name, "err", err)
+			} else if reply == int64(0) {
+				slog.Warn("room ownership held by another instance", "room", name)
+			}
+		}
+
+		cl.mu.Lock()
+		relayed := make(map[string][]*Conn)
+		for _, rc := range cl.relayed {
+			relayed[rc.room] = append(relayed[rc.room], rc.conn)
+		}
+		cl.mu.Unlock()
+		for name, conns := range relayed {
+			owner, err := cl.rdb.do("GET", ownerKey(name))
+			if err != nil || owner != nil {
+				continue
+			}
+			slog.Warn("room owner gone, closing relayed connections", "room", name, "conns", len(conns))
+			for _, c := range conns {
+				c.closeWith(websocket.CloseTryAgainLater, "room owner gone")
+			}
+		}
+	}
+}
+
+// 通知所有转发中的连接服务器即将关闭，返回这些连接
+func (cl *cluster) shutdown() []*Conn {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	conns := make([]*Conn, 0, len(cl.relayed))
+	for _, rc := range cl.relayed {
+		rc.conn.sendJSON(map[string]string{"type": "server_closing"})
+		rc.conn.closeWith(websocket.CloseGoingAway, "server shutting down")
+		conns = append(conns, rc.conn)
+	}
+	return conns
+}
+
+// 停止续期并断开Redis
+func (cl *cluster) Close() {
+	close(cl.stop)
+	cl.sub.Close()
+	cl.rdb.Close()
+}
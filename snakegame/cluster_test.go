@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+// Redis不可用时默认拒绝抢占，开启localFallback时在本地运行
+func TestClaimRedisUnavailable(t *testing.T) {
+	for _, fallback := range []bool{false, true} {
+		// 端口1没有服务，连接立即被拒绝
+		cl := &cluster{id: "test", rdb: newRedisClient("127.0.0.1:1"), localFallback: fallback}
+		local, err := cl.claim("r1")
+		if fallback {
+			if !local || err != nil {
+				t.Errorf("fallback: claim = %v, %v, want true, nil", local, err)
+			}
+		} else if local || err == nil {
+			t.Errorf("no fallback: claim = %v, %v, want false and an error", local, err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+// 调色板大小，客户端按下标取色
+const paletteSize = 16
+
+// 颜色分配器：优先分配空闲的调色板下标，释放的下标可以复用；
+// 超过paletteSize条蛇时选择使用者最少的下标（相同则取最小的）
+type colorAllocator struct {
+	used [paletteSize]int // 每个下标当前的使用者数量
+}
+
+// 分配一个颜色下标
+func (a *colorAllocator) alloc() int {
+	best := 0
+	for i := 1; i < paletteSize; i++ {
+		if a.used[i] < a.used[best] {
+			best = i
+		}
+	}
+	a.used[best]++
+	return best
+}
+
+// 释放颜色下标
+func (a *colorAllocator) release(i int) {
+	if i >= 0 && i < paletteSize && a.used[i] > 0 {
+		a.used[i]--
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// 前16条蛇各占一个下标，之后循环使用使用者最少的下标，释放的下标优先复用
+func TestColorAllocator(t *testing.T) {
+	var a colorAllocator
+	seen := map[int]bool{}
+	for i := 0; i < paletteSize; i++ {
+		c := a.alloc()
+		if c < 0 || c >= paletteSize || seen[c] {
+			t.Fatalf("alloc #%d = %d, want a new index in [0,%d)", i+1, c, paletteSize)
+		}
+		seen[c] = true
+	}
+	// 超出调色板后从最小的下标开始第二轮
+	for i := 0; i < paletteSize; i++ {
+		if c := a.alloc(); c != i {
+			t.Fatalf("alloc #%d = %d, want %d", paletteSize+i+1, c, i)
+		}
+	}
+	if c := a.alloc(); c != 0 {
+		t.Errorf("third round starts at %d, want 0", c)
+	}
+	for _, n := range a.used {
+		if n < 2 || n > 3 {
+			t.Fatalf("used = %v, want every index shared by 2 or 3 snakes", a.used)
+		}
+	}
+
+	a.release(7)
+	a.release(7)
+	if c := a.alloc(); c != 7 {
+		t.Errorf("after releasing 7: alloc = %d, want 7", c)
+	}
+	// 越界和未分配的下标忽略
+	a.release(-1)
+	a.release(paletteSize)
+}
+
+// 颜色跟随会话：断线重连后不变，离开后下标被新玩家复用
+func TestColorStableAcrossResume(t *testing.T) {
+	s, _ := newTestServer(t, newMemoryStore())
+	open := func(time.Duration) *Conn { return allocConn(time.Second) }
+	join := func(q url.Values) (*Room, *Snake) {
+		room, snake, _ := s.join("colors", q, open)
+		if snake == nil {
+			t.Fatalf("join %v: no snake", q)
+		}
+		return room, snake
+	}
+
+	room, alice := join(url.Values{"name": {"alice"}})
+	_, bob := join(url.Values{"name": {"bob"}})
+	color := alice.Color
+	s.detach(room, alice, alice.conn)
+	if _, again := join(url.Values{"resume": {alice.token}}); again != alice || again.Color != color {
+		t.Errorf("resumed color = %d, want %d", again.Color, color)
+	}
+
+	s.removePlayer(room, alice)
+	if _, carol := join(url.Values{"name": {"carol"}}); carol.Color != color {
+		t.Errorf("carol color = %d, want freed %d", carol.Color, color)
+	}
+	room.lock.Lock()
+	for _, sn := range room.players {
+		if sn != bob {
+			delete(room.players, sn.ID)
+		}
+	}
+	room.lock.Unlock()
+	s.removePlayer(room, bob)
+}
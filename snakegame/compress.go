@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 一类连接的发送统计：payload为消息原始字节数，wire为实际写入TCP的字节数（含帧头）
+type wireStats struct {
+	conns   atomic.Int64
+	payload atomic.Int64
+	wire    atomic.Int64
+}
+
+// 压缩统计，区分启用和未启用permessage-deflate的连接
+type compressionMetrics struct {
+	compressed wireStats
+	plain      wireStats
+}
+
+// 统计写入字节数的连接
+type countingConn struct {
+	net.Conn
+	n *atomic.Int64
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// 在Hijack时把底层连接换成countingConn，升级后的WebSocket写入因此被计数
+type countingWriter struct {
+	gin.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return countingConn{Conn: conn, n: w.n}, rw, nil
+}
+
+// 客户端是否提供了permessage-deflate扩展；upgrader开启压缩时服务器会接受
+func offersDeflate(r *http.Request) bool {
+	for _, v := range r.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(v, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+// 压缩和房间统计接口：GET /api/metrics
+func (s *GameServer) metrics(c *gin.Context) {
+	stat := func(w *wireStats) gin.H {
+		payload, wire := w.payload.Load(), w.wire.Load()
+		ratio := 0.0
+		if payload > 0 {
+			ratio = float64(wire) / float64(payload)
+		}
+		return gin.H{
+			"connections":   w.conns.Load(), // 累计连接数
+			"payload_bytes": payload,
+			"wire_bytes":    wire,
+			"ratio":         ratio,
+		}
+	}
+	active, dormant := 0, 0
+	for _, r := range s.roomList() {
+		r.lock.Lock()
+		if r.dormant {
+			dormant++
+		} else {
+			active++
+		}
+		r.lock.Unlock()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"compressed":   stat(&s.compression.compressed),
+		"uncompressed": stat(&s.compression.plain),
+		"rooms":        gin.H{"active": active, "dormant": dormant},
+		"frames": gin.H{
+			"sent":       s.frames.sent.Load(),
+			"suppressed": s.frames.suppressed.Load(), // 状态未变化而跳过的帧
+		},
+	})
+}
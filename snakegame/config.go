@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// 服务器配置：命令行参数优先，未指定时读取同名环境变量（如 -max-players 对应 MAX_PLAYERS），
+// 都没有时使用默认值
+type Config struct {
+	Addr       string        // 监听地址
+	DSN        string        // 数据库DSN
+	Static     string        // 前端页面文件
+	Tick       time.Duration // 新房间默认tick间隔
+	Board      int           // 新房间默认棋盘边长
+	MaxPlayers int           // 新房间默认玩家人数上限
+	RoomTTL    time.Duration // 房间空闲多久后关闭，0表示不过期
+	ReplayDir  string        // 录像保存目录
+
+	LeaderboardCacheTTL time.Duration // 排行榜缓存有效期，0表示不缓存
+	LeaderboardTZ       string        // 日榜、周榜划分日期的时区，如 Asia/Shanghai
+	ShutdownGrace       time.Duration // 收到SIGINT/SIGTERM后优雅关闭的总时长
+
+	DBWriteTimeout time.Duration // 单次写库超时
+	DBReadTimeout  time.Duration // 单次查询超时
+	LogLevel       slog.Level    // 日志级别
+
+	TLSCert      string // TLS证书文件，与TLSKey同时设置时以HTTPS/WSS提供服务
+	TLSKey       string // TLS私钥文件
+	RedirectHTTP string // 启用TLS时，在该地址上把HTTP请求重定向到HTTPS，如 :80
+
+	RedisAddr            string // 多实例部署时协调房间归属的Redis地址，空表示单实例
+	ClusterLocalFallback bool   // 多实例模式下Redis不可用时仍在本地运行房间，默认拒绝加入
+
+	AuthSecret     string // JWT签名密钥，设置后WebSocket连接必须携带 ?token=<jwt>
+	AllowedOrigins string // 允许的WebSocket来源，逗号分隔，空表示不限制
+	AdminToken     string // 管理接口的令牌，请求头 X-Admin-Token 须与之一致
+}
+
+// 默认数据库DSN
+const defaultDSN = "root:123456@tcp(127.0.0.1:3306)/snake_game?parseTime=true"
+
+// 参数名对应的环境变量名
+func envName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// 解析命令行参数和环境变量并校验
+func loadConfig(args []string) (Config, error) {
+	var cfg Config
+	fs := flag.NewFlagSet("snakegame", flag.ContinueOnError)
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "listen address")
+	fs.StringVar(&cfg.DSN, "db-dsn", defaultDSN, "score store DSN (mysql DSN, memory:// or sqlite://<path>)")
+	fs.StringVar(&cfg.Static, "static", "./client.html", "client page served at /")
+	fs.DurationVar(&cfg.Tick, "tick", tickInterval, "default tick interval for new rooms")
+	fs.IntVar(&cfg.Board, "board", defaultBoardSize, "default board size for new rooms")
+	fs.IntVar(&cfg.MaxPlayers, "max-players", defaultMaxPlayers, "default player limit for new rooms")
+	fs.DurationVar(&cfg.RoomTTL, "room-ttl", defaultRoomTTL, "close rooms with no food eaten, joins or turns for this long (0 disables)")
+	fs.StringVar(&cfg.ReplayDir, "replay-dir", defaultReplayDir, "directory for recorded replays")
+	fs.DurationVar(&cfg.LeaderboardCacheTTL, "leaderboard-cache-ttl", defaultRankCacheTTL, "how long leaderboard pages are cached (0 disables)")
+	fs.StringVar(&cfg.LeaderboardTZ, "leaderboard-tz", "UTC", "time zone for daily and weekly leaderboards, e.g. Asia/Shanghai")
+	fs.DurationVar(&cfg.ShutdownGrace, "shutdown-grace", defaultShutdownGrace, "time allowed for a graceful shutdown")
+	fs.DurationVar(&cfg.DBWriteTimeout, "db-write-timeout", defaultDBWriteTimeout, "timeout for each database write")
+	fs.DurationVar(&cfg.DBReadTimeout, "db-read-timeout", defaultDBReadTimeout, "timeout for each database query")
+	fs.TextVar(&cfg.LogLevel, "log-level", slog.LevelInfo, "log level: debug, info, warn or error")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "TLS certificate file (serve HTTPS/WSS together with -tls-key)")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "TLS private key file")
+	fs.StringVar(&cfg.RedirectHTTP, "redirect-http", "", "with TLS enabled, redirect plain HTTP on this address to HTTPS, e.g. :80")
+	fs.StringVar(&cfg.RedisAddr, "redis-addr", "", "redis host:port for running several instances (empty runs a single instance)")
+	fs.BoolVar(&cfg.ClusterLocalFallback, "cluster-local-fallback", false, "in cluster mode, run rooms locally when redis is unreachable instead of refusing joins (risks two instances running the same room)")
+	fs.StringVar(&cfg.AuthSecret, "auth-secret", "", "JWT signing secret; when set, websocket connections need ?token=<jwt>")
+	fs.StringVar(&cfg.AllowedOrigins, "allowed-origins", "", "comma separated websocket origins, e.g. https://snake.example.com,*.example.com (empty allows any)")
+	fs.StringVar(&cfg.AdminToken, "admin-token", "", "token for the admin API (X-Admin-Token header)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// 命令行未指定的参数读取环境变量
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if set[f.Name] || !ok || err != nil {
+			return
+		}
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("invalid %s %q: %v", envName(f.Name), v, e)
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.validate()
+}
+
+// 校验配置，返回所有问题
+func (cfg Config) validate() error {
+	var errs []error
+	if cfg.Addr == "" {
+		errs = append(errs, errors.New("addr must not be empty"))
+	}
+	if cfg.DSN == "" {
+		errs = append(errs, errors.New("db-dsn must not be empty"))
+	}
+	if st, err := os.Stat(cfg.Static); err != nil {
+		errs = append(errs, fmt.Errorf("static file: %v", err))
+	} else if st.IsDir() {
+		errs = append(errs, fmt.Errorf("static file %s is a directory", cfg.Static))
+	}
+	if cfg.Tick < minTickInterval || cfg.Tick > maxTickInterval {
+		errs = append(errs, fmt.Errorf("tick %s out of range [%s, %s]", cfg.Tick, minTickInterval, maxTickInterval))
+	}
+	if cfg.Board < minBoardSize || cfg.Board > maxBoardSize {
+		errs = append(errs, fmt.Errorf("board %d out of range [%d, %d]", cfg.Board, minBoardSize, maxBoardSize))
+	}
+	if cfg.MaxPlayers < 1 || cfg.MaxPlayers > maxMaxPlayers {
+		errs = append(errs, fmt.Errorf("max-players %d out of range [1, %d]", cfg.MaxPlayers, maxMaxPlayers))
+	}
+	if cfg.RoomTTL < 0 {
+		errs = append(errs, errors.New("room-ttl must not be negative"))
+	}
+	if cfg.ReplayDir == "" {
+		errs = append(errs, errors.New("replay-dir must not be empty"))
+	}
+	if cfg.LeaderboardCacheTTL < 0 {
+		errs = append(errs, errors.New("leaderboard-cache-ttl must not be negative"))
+	}
+	if _, err := time.LoadLocation(cfg.LeaderboardTZ); err != nil {
+		errs = append(errs, fmt.Errorf("leaderboard-tz: %v", err))
+	}
+	if cfg.ShutdownGrace <= 0 {
+		errs = append(errs, errors.New("shutdown-grace must be positive"))
+	}
+	if cfg.DBWriteTimeout <= 0 {
+		errs = append(errs, errors.New("db-write-timeout must be positive"))
+	}
+	if cfg.DBReadTimeout <= 0 {
+		errs = append(errs, errors.New("db-read-timeout must be positive"))
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		errs = append(errs, errors.New("tls-cert and tls-key must be set together"))
+	}
+	if cfg.TLS() {
+		if _, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.RedirectHTTP != "" && !cfg.TLS() {
+		errs = append(errs, errors.New("redirect-http requires tls-cert and tls-key"))
+	}
+	if cfg.RedirectHTTP != "" && cfg.RedirectHTTP == cfg.Addr {
+		errs = append(errs, errors.New("redirect-http must differ from addr"))
+	}
+	if cfg.RedisAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.RedisAddr); err != nil {
+			errs = append(errs, fmt.Errorf("redis-addr: %v", err))
+		}
+	}
+	if cfg.ClusterLocalFallback && cfg.RedisAddr == "" {
+		errs = append(errs, errors.New("cluster-local-fallback requires redis-addr"))
+	}
+	if cfg.AllowedOrigins != "" && len(parseOrigins(cfg.AllowedOrigins)) == 0 {
+		errs = append(errs, fmt.Errorf("allowed-origins %q has no origins", cfg.AllowedOrigins))
+	}
+	return errors.Join(errs...)
+}
+
+// 是否以HTTPS/WSS提供服务
+func (cfg Config) TLS() bool {
+	return cfg.TLSCert != "" && cfg.TLSKey != ""
+}
+
+// 日榜、周榜的时区，名称已在validate中校验，未设置时为UTC
+func (cfg Config) rankLocation() *time.Location {
+	loc, err := time.LoadLocation(cfg.LeaderboardTZ)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// 新房间的默认参数
+func (cfg Config) roomDefaults() RoomOptions {
+	opts := defaultRoomOptions()
+	opts.Width = cfg.Board
+	opts.Height = cfg.Board
+	opts.Interval = cfg.Tick
+	opts.MaxPlayers = cfg.MaxPlayers
+	opts.IdleTTL = cfg.RoomTTL
+	return opts
+}
+
+// 隐去DSN中的密码，用于日志
+func redactDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	userinfo := dsn[:at]
+	scheme := ""
+	if i := strings.Index(userinfo, "://"); i >= 0 {
+		scheme, userinfo = userinfo[:i+3], userinfo[i+3:]
+	}
+	colon := strings.Index(userinfo, ":")
+	if colon < 0 {
+		return dsn
+	}
+	return scheme + userinfo[:colon] + ":****" + dsn[at:]
+}
+
+// 隐去密钥，用于日志；未设置时为空
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "****"
+}
+
+// 配置摘要，DSN中的密码和各种密钥已隐去
+func (cfg Config) String() string {
+	s := fmt.Sprintf("addr=%s db-dsn=%s static=%s tick=%s board=%d max-players=%d room-ttl=%s db-write-timeout=%s db-read-timeout=%s log-level=%s",
+		cfg.Addr, redactDSN(cfg.DSN), cfg.Static, cfg.Tick, cfg.Board, cfg.MaxPlayers, cfg.RoomTTL, cfg.DBWriteTimeout, cfg.DBReadTimeout, cfg.LogLevel)
+	s += fmt.Sprintf(" replay-dir=%s leaderboard-cache-ttl=%s leaderboard-tz=%s shutdown-grace=%s",
+		cfg.ReplayDir, cfg.LeaderboardCacheTTL, cfg.LeaderboardTZ, cfg.ShutdownGrace)
+	if cfg.TLS() {
+		s += fmt.Sprintf(" tls-cert=%s tls-key=%s", cfg.TLSCert, cfg.TLSKey)
+	}
+	if cfg.RedirectHTTP != "" {
+		s += " redirect-http=" + cfg.RedirectHTTP
+	}
+	if cfg.RedisAddr != "" {
+		s += " redis-addr=" + cfg.RedisAddr
+	}
+	if cfg.ClusterLocalFallback {
+		s += " cluster-local-fallback=true"
+	}
+	if cfg.AuthSecret != "" {
+		s += " auth-secret=" + redactSecret(cfg.AuthSecret)
+	}
+	if cfg.AllowedOrigins != "" {
+		s += " allowed-origins=" + cfg.AllowedOrigins
+	}
+	if cfg.AdminToken != "" {
+		s += " admin-token=" + redactSecret(cfg.AdminToken)
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 未在命令行指定的参数读取同名环境变量，命令行优先
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("REDIS_ADDR", "127.0.0.1:6379")
+	t.Setenv("AUTH_SECRET", "jwt-secret")
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	t.Setenv("ALLOWED_ORIGINS", "https://snake.example.com")
+	t.Setenv("LEADERBOARD_TZ", "Asia/Shanghai")
+	t.Setenv("LEADERBOARD_CACHE_TTL", "0s")
+	t.Setenv("SHUTDOWN_GRACE", "3s")
+	t.Setenv("REPLAY_DIR", "/tmp/replays")
+
+	cfg, err := loadConfig([]string{"-shutdown-grace", "5s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RedisAddr != "127.0.0.1:6379" || cfg.AuthSecret != "jwt-secret" || cfg.AdminToken != "admin-secret" ||
+		cfg.AllowedOrigins != "https://snake.example.com" || cfg.ReplayDir != "/tmp/replays" || cfg.LeaderboardCacheTTL != 0 {
+		t.Errorf("env not applied: %+v", cfg)
+	}
+	if cfg.ShutdownGrace != 5*time.Second {
+		t.Errorf("shutdown-grace = %s, want the flag's 5s over the env's 3s", cfg.ShutdownGrace)
+	}
+	if loc := cfg.rankLocation(); loc.String() != "Asia/Shanghai" {
+		t.Errorf("rank location = %s, want Asia/Shanghai", loc)
+	}
+}
+
+// 非法的环境变量在启动时报错，而不是运行中才发现
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := []struct {
+		env, value, want string
+	}{
+		{"LEADERBOARD_CACHE_TTL", "-1s", "leaderboard-cache-ttl"},
+		{"LEADERBOARD_CACHE_TTL", "soon", "LEADERBOARD_CACHE_TTL"},
+		{"LEADERBOARD_TZ", "Mars/Olympus", "leaderboard-tz"},
+		{"SHUTDOWN_GRACE", "0s", "shutdown-grace"},
+		{"REPLAY_DIR", "", "replay-dir"},
+		{"REDIS_ADDR", "localhost", "redis-addr"},
+		{"CLUSTER_LOCAL_FALLBACK", "true", "cluster-local-fallback"},
+		{"ALLOWED_ORIGINS", " , ", "allowed-origins"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			_, err := loadConfig(nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one mentioning %s", err, tt.want)
+			}
+		})
+	}
+}
+
+// 启动日志中的配置摘要不含密码和密钥
+func TestConfigStringRedacts(t *testing.T) {
+	cfg := Config{
+		DSN:        "root:dbpass@tcp(127.0.0.1:3306)/snake_game",
+		AuthSecret: "jwt-secret",
+		AdminToken: "admin-secret",
+	}
+	s := cfg.String()
+	for _, secret := range []string{"dbpass", "jwt-secret", "admin-secret"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("String() leaks %q: %s", secret, s)
+		}
+	}
+	if !strings.Contains(s, "auth-secret=****") || !strings.Contains(s, "admin-token=****") {
+		t.Errorf("String() = %s, want redacted auth-secret and admin-token", s)
+	}
+}
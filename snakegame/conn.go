@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	sendQueueSize = 64              // 每个连接的发送队列长度
+	writeWait     = 5 * time.Second // 单次写超时
+)
+
+// 入站消息限流参数
+const (
+	inboundRate      = 20               // 每秒允许的消息数
+	inboundBurst     = 40               // 允许的突发消息数
+	inboundWarnEvery = time.Second      // 超限警告的最小间隔
+	inboundKickAfter = 10 * time.Second // 持续超限超过该时长则断开
+)
+
+// 入站消息的限流结果
+const (
+	inboundOK    = iota // 正常处理
+	inboundDrop         // 丢弃
+	inboundWarn         // 丢弃并发送警告
+	inboundClose        // 持续超限，关闭连接
+)
+
+// 心跳参数，测试中可以调小
+var (
+	pingPeriod = 15 * time.Second // 服务器发送ping控制帧的间隔
+	pongWait   = 40 * time.Second // 超过该时长未收到pong则断开
+)
+
+// 待发送的一条消息
+type outMsg struct {
+	mt   int
+	data []byte
+}
+
+// 连接封装：gorilla/websocket不支持并发写，
+// 所有写操作都先进入发送队列，再由writePump串行写出
+type Conn struct {
+	ws    *websocket.Conn                 // 远程连接为nil
+	sink  func(mt int, data []byte) error // 远程连接的发送函数，把消息转给连接所在的实例
+	send  chan outMsg
+	done  chan struct{} // 关闭信号
+	flush chan struct{} // 写协程退出后关闭
+	stall time.Duration // 队列持续满超过该时长则断开
+	ping  time.Duration // ping控制帧间隔，创建时取pingPeriod
+
+	delta        bool // 是否使用增量协议
+	binary       bool // 状态帧使用二进制编码（见encoding.go）
+	needKeyframe bool // 下一帧需要发送完整状态（由房间锁保护）
+	view         int  // 视野半径，0表示接收完整状态
+
+	mu        sync.Mutex
+	fullSince time.Time // 队列开始持续满的时间
+
+	probeID       int64         // 最近一次延迟探测的编号，见latency.go
+	probeAt       time.Time     // 最近一次延迟探测的发送时间
+	probeAnswered bool          // 最近一次探测已收到回复
+	rtt           time.Duration // 平滑后的往返时间
+	closeOnce     sync.Once
+	failed        atomic.Bool                 // 写出失败或发送队列持续满，连接已不可用
+	log           atomic.Pointer[slog.Logger] // 带房间、玩家和远端地址字段的日志，见logger
+
+	limit     *rateLimiter // 入站消息限流，只在读协程中使用
+	chatLimit *rateLimiter // 聊天限流
+	stats     *wireStats   // 发送统计，可以为nil
+	overSince time.Time    // 开始持续超限的时间
+	lastDrop  time.Time    // 最近一次丢弃消息的时间
+	lastWarn  time.Time    // 最近一次发送超限警告的时间
+}
+
+// 创建连接结构，不启动写协程
+func allocConn(stall time.Duration) *Conn {
+	return &Conn{
+		send:  make(chan outMsg, sendQueueSize),
+		done:  make(chan struct{}),
+		flush: make(chan struct{}),
+		stall: stall,
+		limit: newRateLimiter(inboundRate, inboundBurst),
+
+		chatLimit: newRateLimiter(chatRate, chatBurst),
+	}
+}
+
+// 创建连接并启动写协程。心跳间隔在这里取一次，测试缩短后恢复不影响已有连接
+func newConn(ws *websocket.Conn, stall time.Duration) *Conn {
+	c := allocConn(stall)
+	c.ws = ws
+	c.ping = pingPeriod
+	// 读超时由pong续期，超时后ReadMessage返回错误，由读循环负责清理玩家
+	wait := pongWait
+	_ = ws.SetReadDeadline(time.Now().Add(wait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(wait))
+	})
+	go c.writePump()
+	return c
+}
+
+// 创建远程连接：WebSocket在其他实例上，发送的消息经sink转发过去，
+// 入站消息由调用方交给房间处理
+func newRemoteConn(sink func(mt int, data []byte) error, stall time.Duration) *Conn {
+	c := allocConn(stall)
+	c.sink = sink
+	go c.remotePump()
+	return c
+}
+
+// 连接的日志，未设置时为默认日志
+func (c *Conn) logger() *slog.Logger {
+	if l := c.log.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// 设置连接的日志；加入房间后会追加player字段，此时写协程可能正在使用，因此原子替换
+func (c *Conn) setLogger(l *slog.Logger) {
+	c.log.Store(l)
+}
+
+// 非阻塞入队，队列满时丢弃消息；持续满超过stall则关闭连接
+func (c *Conn) enqueue(mt int, data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- outMsg{mt: mt, data: data}:
+		c.mu.Lock()
+		c.fullSince = time.Time{}
+		c.mu.Unlock()
+		return true
+	default:
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	if c.fullSince.IsZero() {
+		c.fullSince = now
+	}
+	stalled := now.Sub(c.fullSince) > c.stall
+	c.mu.Unlock()
+	if stalled {
+		c.logger().Warn("send queue full, dropping connection")
+		c.failed.Store(true)
+		c.Close()
+	}
+	return false
+}
+
+// 发送文本消息
+func (c *Conn) sendText(data []byte) bool {
+	return c.enqueue(websocket.TextMessage, data)
+}
+
+// 发送二进制消息
+func (c *Conn) sendBinary(data []byte) bool {
+	return c.enqueue(websocket.BinaryMessage, data)
+}
+
+// 序列化为JSON后发送
+func (c *Conn) sendJSON(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.logger().Error("marshal error", "err", err)
+		return false
+	}
+	return c.sendText(data)
+}
+
+// 发送关闭帧后断开，写协程写完关闭帧即退出
+func (c *Conn) closeWith(code int, text string) {
+	if !c.enqueue(websocket.CloseMessage, websocket.FormatCloseMessage(code, text)) {
+		c.Close()
+	}
+}
+
+// 关闭连接，可重复调用
+func (c *Conn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.ws != nil {
+			_ = c.ws.Close()
+		}
+	})
+}
+
+// 写协程，唯一允许调用ws写方法的地方，同时定时发送ping控制帧
+func (c *Conn) writePump() {
+	ticker := time.NewTicker(c.ping)
+	defer func() {
+		ticker.Stop()
+		c.Close()
+		close(c.flush)
+	}()
+	for {
+		select {
+		case m := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(m.mt, m.data); err != nil {
+				c.logger().Warn("websocket write failed", "err", err)
+				c.failed.Store(true)
+				return
+			}
+			if c.stats != nil {
+				c.stats.payload.Add(int64(len(m.data)))
+			}
+			if m.mt == websocket.CloseMessage {
+				return
+			}
+		case <-ticker.C:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.logger().Warn("websocket ping failed", "err", err)
+				c.failed.Store(true)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// 远程连接的写协程：按顺序交给sink，连接关闭时通知对方实例断开
+func (c *Conn) remotePump() {
+	defer func() {
+		c.Close()
+		close(c.flush)
+	}()
+	for {
+		select {
+		case m := <-c.send:
+			if err := c.sink(m.mt, m.data); err != nil {
+				c.logger().Warn("remote send failed", "err", err)
+				c.failed.Store(true)
+				return
+			}
+			if m.mt == websocket.CloseMessage {
+				return
+			}
+		case <-c.done:
+			_ = c.sink(websocket.CloseMessage, nil)
+			return
+		}
+	}
+}
+
+// 对一条入站消息限流：超限的消息被丢弃，每秒最多警告一次；
+// 丢弃间隔不超过1秒视为持续超限，持续inboundKickAfter后要求关闭连接。只在读协程中调用
+func (c *Conn) checkInbound(now time.Time) int {
+	if c.limit.Allow() {
+		if now.Sub(c.lastDrop) > time.Second {
+			c.overSince = time.Time{}
+		}
+		return inboundOK
+	}
+	if c.overSince.IsZero() || now.Sub(c.lastDrop) > time.Second {
+		c.overSince = now
+	}
+	c.lastDrop = now
+	if now.Sub(c.overSince) >= inboundKickAfter {
+		return inboundClose
+	}
+	if now.Sub(c.lastWarn) >= inboundWarnEvery {
+		c.lastWarn = now
+		return inboundWarn
+	}
+	return inboundDrop
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 不回应ping控制帧的客户端在读超时后断开并进入保留期，持续读取（自动回pong）的客户端保持在线
+func TestHeartbeatEvictsSilentClient(t *testing.T) {
+	oldPing, oldPong := pingPeriod, pongWait
+	const wait = 100 * time.Millisecond
+	pingPeriod, pongWait = 20*time.Millisecond, wait
+	// 连接在newConn中取走间隔，之后即可恢复，其他测试的连接仍用默认值；
+	// 中途失败时由Cleanup恢复，不影响同一包中后面的测试
+	restore := func() { pingPeriod, pongWait = oldPing, oldPong }
+	t.Cleanup(restore)
+
+	s, r := newTestServer(t, newMemoryStore())
+	r.GET("/ws/:room", s.handleWS)
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	dial := func(name string) *websocket.Conn {
+		url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/heartbeat?name=" + name
+		c, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("dial %s: %v", name, err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	dial("silent")
+	alive := dial("alive")
+	// gorilla/websocket在读取时自动回复pong，silent从不读取
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	room := s.findRoom("heartbeat")
+	detached := func() map[string]bool {
+		room.lock.Lock()
+		defer room.lock.Unlock()
+		out := make(map[string]bool, len(room.players))
+		for _, sn := range room.players {
+			out[sn.Name] = sn.detached
+		}
+		return out
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !detached()["silent"] {
+		if time.Now().After(deadline) {
+			t.Fatalf("silent client not detached: %v", detached())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	restore()
+	// 再等几个超时周期，确认持续回应的客户端没有被误判
+	time.Sleep(3 * wait)
+	if got := detached(); len(got) != 2 || !got["silent"] || got["alive"] {
+		t.Errorf("detached = %v, want only silent", got)
+	}
+}
+
+// 服务器一侧写端关闭的连接：广播写出失败后一个tick内移出房间，其他玩家收到leave
+func TestEvictFailedWrite(t *testing.T) {
+	s, r := newTestServer(t, newMemoryStore())
+	r.GET("/ws/:room", s.handleWS)
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	dial := func(name string) *websocket.Conn {
+		url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/zombie?name=" + name
+		c, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("dial %s: %v", name, err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	watcher := dial("watcher")
+	dial("zombie")
+
+	room := s.findRoom("zombie")
+	room.lock.Lock()
+	var zombie *Snake
+	for _, sn := range room.players {
+		if sn.Name == "zombie" {
+			zombie = sn
+		}
+	}
+	// 读端不受影响，读循环仍阻塞，只有写出会失败
+	tcp := zombie.conn.ws.UnderlyingConn().(countingConn).Conn.(*net.TCPConn)
+	if err := tcp.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	room.lock.Unlock()
+
+	// 下一次广播写出失败，读循环随之退出或下一个tick清理时移除
+	watcher.SetReadDeadline(time.Now().Add(2*room.interval + time.Second))
+	for {
+		_, data, err := watcher.ReadMessage()
+		if err != nil {
+			t.Fatalf("no leave event for %s: %v", zombie.ID, err)
+		}
+		if strings.Contains(string(data), `"type":"leave"`) && strings.Contains(string(data), `"`+zombie.ID+`"`) {
+			break
+		}
+	}
+	room.lock.Lock()
+	_, still := room.players[zombie.ID]
+	room.lock.Unlock()
+	if still {
+		t.Errorf("%s still in the room after the leave event", zombie.ID)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import "golearn/snakegame/protocol"
+
+// 人数变化事件，加入、离开和观战者进出时立即广播，不必等下一个状态帧
+type CountsEvent = protocol.CountsEvent
+
+// 重新统计人数，与上次通知不同时广播counts事件。
+// 在玩家和观战者增减后调用，状态帧中的人数也取自这里，调用方需持有房间锁
+func (r *Room) syncCounts() {
+	players, spectators := r.humanCount(), len(r.watchers)
+	if players == r.playerCount && spectators == r.spectatorCount {
+		return
+	}
+	r.playerCount, r.spectatorCount = players, spectators
+	r.emit(CountsEvent{Type: "counts", Players: players, Spectators: spectators})
+}
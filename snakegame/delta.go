@@ -0,0 +1,226 @@
+package main
+
+import "encoding/json"
+
+// 增量协议下每隔多少tick发送一次完整关键帧
+const keyframeInterval = 25
+
+// 上一次广播时蛇的状态
+type sentSnake struct {
+	body  []Point
+	dir   string
+	score int
+	max   int
+	alive bool
+	spawn int
+	speed int
+	ghost int
+	rtt   int
+}
+
+// 单条蛇的增量：客户端按 body = add + body[:len(body)-trim] 还原
+type snakeDelta struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name,omitempty"`  // 仅新出现的蛇携带
+	Bot   bool    `json:"bot,omitempty"`   // 仅新出现的蛇携带
+	Color *int    `json:"color,omitempty"` // 仅新出现的蛇携带
+	Add   []Point `json:"add,omitempty"`   // 新增的头部坐标（从头到尾）
+	Trim  int     `json:"trim,omitempty"`  // 从尾部移除的段数
+	Dir   string  `json:"dir"`
+	Score int     `json:"score"`
+	Alive bool    `json:"alive"`
+
+	MaxLen int `json:"max_length"`
+
+	Spawning   bool `json:"spawning,omitempty"`
+	SpawnTicks int  `json:"spawn_ticks,omitempty"`
+	Speed      int  `json:"speed,omitempty"`
+	Ghost      int  `json:"ghost,omitempty"`
+	LatencyMS  int  `json:"latency_ms,omitempty"`
+}
+
+// 增量消息
+type deltaMsg struct {
+	Type    string       `json:"type"`
+	Tick    int64        `json:"tick"`
+	Snakes  []snakeDelta `json:"snakes,omitempty"`  // 有变化的蛇
+	Removed []string     `json:"removed,omitempty"` // 已离开的玩家
+	Foods   []Food       `json:"foods,omitempty"`   // 食物有变化时才发送
+	// 道具有增减时才发送，全部消失时为空数组
+	PowerUps *[]PowerUp `json:"powerups,omitempty"`
+	// 危险格有增减时才发送，全部消失时为空数组
+	Hazards *[]Hazard `json:"hazards,omitempty"`
+	// 场地收缩时才发送
+	Bounds *Bounds `json:"bounds,omitempty"`
+
+	Spectators int `json:"spectators"` // 观战人数
+}
+
+// 上一次发送的状态，作为增量协议的基准
+type sentFrame struct {
+	snakes  map[string]sentSnake // 为nil表示还没有发送过
+	foods   []Food
+	powers  []PowerUp
+	hazards []Hazard
+	bounds  Bounds
+}
+
+// 计算蛇身体相对上一帧的变化
+func bodyDelta(prev, cur []Point) (add []Point, trim int) {
+	for k := 0; k <= len(cur); k++ {
+		keep := len(cur) - k
+		if keep > len(prev) {
+			continue
+		}
+		match := true
+		for i := 0; i < keep; i++ {
+			if cur[k+i] != prev[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return cur[:k], len(prev) - keep
+		}
+	}
+	return cur, len(prev)
+}
+
+// 基于上一次发送的状态构建增量消息，调用方需持有房间锁
+func (r *Room) buildDelta(last sentFrame) deltaMsg {
+	msg := deltaMsg{Type: "delta", Tick: r.tick, Spectators: len(r.watchers)}
+	for id, s := range r.players {
+		speed := r.speedTier(s.Score)
+		rtt := s.conn.latencyMS()
+		prev, ok := last.snakes[id]
+		if !ok {
+			msg.Snakes = append(msg.Snakes, snakeDelta{
+				ID: id, Name: s.Name, Bot: s.Bot, Color: &s.Color, Add: s.Body, Dir: s.Dir, Score: s.Score, Alive: s.Alive, MaxLen: s.MaxLen,
+				Spawning: s.Spawning, SpawnTicks: s.SpawnTicks, Speed: speed, Ghost: s.Ghost, LatencyMS: rtt,
+			})
+			continue
+		}
+		add, trim := bodyDelta(prev.body, s.Body)
+		if len(add) == 0 && trim == 0 && prev.dir == s.Dir &&
+			prev.score == s.Score && prev.max == s.MaxLen && prev.alive == s.Alive && prev.spawn == s.SpawnTicks && prev.speed == speed &&
+			prev.ghost == s.Ghost && prev.rtt == rtt {
+			continue
+		}
+		msg.Snakes = append(msg.Snakes, snakeDelta{
+			ID: id, Add: add, Trim: trim, Dir: s.Dir, Score: s.Score, Alive: s.Alive, MaxLen: s.MaxLen,
+			Spawning: s.Spawning, SpawnTicks: s.SpawnTicks, Speed: speed, Ghost: s.Ghost, LatencyMS: rtt,
+		})
+	}
+	for id := range last.snakes {
+		if _, ok := r.players[id]; !ok {
+			msg.Removed = append(msg.Removed, id)
+		}
+	}
+	if !sameFoods(r.foods, last.foods) {
+		msg.Foods = r.foods
+	}
+	if !samePowerUps(r.powerups, last.powers) {
+		list := r.powerUpList()
+		msg.PowerUps = &list
+	}
+	if !sameHazards(r.hazards, last.hazards) {
+		list := r.hazardList()
+		msg.Hazards = &list
+	}
+	if b := r.bounds(); b != last.bounds {
+		msg.Bounds = &b
+	}
+	return msg
+}
+
+// 复制当前状态，作为下一次增量的基准
+func (r *Room) captureSent() sentFrame {
+	sent := make(map[string]sentSnake, len(r.players))
+	for id, s := range r.players {
+		sent[id] = sentSnake{
+			body:  append([]Point(nil), s.Body...),
+			dir:   s.Dir,
+			score: s.Score,
+			max:   s.MaxLen,
+			alive: s.Alive,
+			spawn: s.SpawnTicks,
+			speed: r.speedTier(s.Score),
+			ghost: s.Ghost,
+			rtt:   s.conn.latencyMS(),
+		}
+	}
+	return sentFrame{
+		snakes:  sent,
+		foods:   append([]Food(nil), r.foods...),
+		powers:  r.powerUpList(),
+		hazards: r.hazardList(),
+		bounds:  r.bounds(),
+	}
+}
+
+// 判断两组食物是否完全相同
+func sameFoods(a, b []Food) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 按各连接的协议广播本tick状态：默认完整快照，增量客户端发送增量，
+// 每keyframeInterval个tick或新加入时补发一次完整关键帧；二进制客户端每tick收到完整的二进制帧；
+// 开启视野的玩家逐个收到视野内的完整状态（见viewport.go）
+func (r *Room) broadcastState() {
+	viewed := r.sendViewports()
+	conns := r.conns()
+	if len(viewed) > 0 {
+		rest := conns[:0]
+		for _, c := range conns {
+			if !viewed[c] {
+				rest = append(rest, c)
+			}
+		}
+		conns = rest
+	}
+	hasDelta := false
+	for _, c := range conns {
+		if c.delta {
+			hasDelta = true
+			break
+		}
+	}
+	keyframe := r.sent.snakes == nil || r.tick%keyframeInterval == 0
+
+	var full, delta, bin []byte
+	if hasDelta && !keyframe {
+		delta, _ = json.Marshal(r.buildDelta(r.sent))
+	}
+	for _, c := range conns {
+		if c.binary {
+			if bin == nil {
+				bin = r.binaryState()
+			}
+			c.sendBinary(bin)
+			continue
+		}
+		if c.delta && !keyframe && !c.needKeyframe {
+			c.sendText(delta)
+			continue
+		}
+		if full == nil {
+			full, _ = json.Marshal(r.stateMessage())
+		}
+		c.needKeyframe = false
+		c.sendText(full)
+	}
+
+	if hasDelta {
+		r.sent = r.captureSent()
+	} else {
+		r.sent = sentFrame{}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// 身体变化拆成头部新增和尾部移除
+func TestBodyDelta(t *testing.T) {
+	p := func(x int) Point { return Point{X: x, Y: 0} }
+	tests := []struct {
+		name      string
+		prev, cur []Point
+		wantAdd   []Point
+		wantTrim  int
+	}{
+		{"unchanged", []Point{p(3), p(2), p(1)}, []Point{p(3), p(2), p(1)}, nil, 0},
+		{"moved", []Point{p(3), p(2), p(1)}, []Point{p(4), p(3), p(2)}, []Point{p(4)}, 1},
+		{"grew", []Point{p(3), p(2), p(1)}, []Point{p(4), p(3), p(2), p(1)}, []Point{p(4)}, 0},
+		{"shrank", []Point{p(3), p(2), p(1)}, []Point{p(3), p(2)}, nil, 1},
+		{"respawned", []Point{p(3), p(2), p(1)}, []Point{p(8), p(9)}, []Point{p(8), p(9)}, 3},
+		{"new snake", nil, []Point{p(1), p(0)}, []Point{p(1), p(0)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			add, trim := bodyDelta(tt.prev, tt.cur)
+			if !slices.Equal(add, tt.wantAdd) || trim != tt.wantTrim {
+				t.Errorf("bodyDelta = %v, %d, want %v, %d", add, trim, tt.wantAdd, tt.wantTrim)
+			}
+			// 客户端还原：body = add + prev[:len(prev)-trim]
+			got := append(slices.Clone(add), tt.prev[:len(tt.prev)-trim]...)
+			if !slices.Equal(got, tt.cur) {
+				t.Errorf("rebuilt body = %v, want %v", got, tt.cur)
+			}
+		})
+	}
+}
+
+// 增量只包含有变化的蛇和已离开的玩家
+func TestBuildDelta(t *testing.T) {
+	a := snakeAt("P1", "right", Point{X: 3, Y: 1}, Point{X: 2, Y: 1})
+	b := snakeAt("P2", "right", Point{X: 3, Y: 5}, Point{X: 2, Y: 5})
+	c := snakeAt("P3", "right", Point{X: 3, Y: 8}, Point{X: 2, Y: 8})
+	r := newMoveRoom(20, 20, a, b, c)
+	last := r.captureSent()
+
+	a.Body = []Point{{X: 4, Y: 1}, {X: 3, Y: 1}}
+	b.Score = 10
+	delete(r.players, "P3")
+	msg := r.buildDelta(last)
+
+	got := map[string]snakeDelta{}
+	for _, d := range msg.Snakes {
+		got[d.ID] = d
+	}
+	if len(got) != 2 {
+		t.Fatalf("delta snakes = %+v, want P1 and P2", msg.Snakes)
+	}
+	if d := got["P1"]; !slices.Equal(d.Add, []Point{{X: 4, Y: 1}}) || d.Trim != 1 {
+		t.Errorf("P1 add=%v trim=%d, want one new head and trim 1", d.Add, d.Trim)
+	}
+	if d := got["P2"]; len(d.Add) != 0 || d.Trim != 0 || d.Score != 10 {
+		t.Errorf("P2 = %+v, want score 10 without body changes", d)
+	}
+	if !slices.Equal(msg.Removed, []string{"P3"}) {
+		t.Errorf("removed = %v, want [P3]", msg.Removed)
+	}
+	if msg.Foods != nil || msg.PowerUps != nil || msg.Hazards != nil || msg.Bounds != nil {
+		t.Errorf("unchanged foods/powerups/hazards/bounds sent: %+v", msg)
+	}
+}
+
+// 10名玩家、每条蛇50节的房间：每tick完整快照和增量的字节数
+func BenchmarkStateBandwidth(b *testing.B) {
+	const players, length = 10, 50
+	var snakes []*Snake
+	for i := 0; i < players; i++ {
+		body := make([]Point, length)
+		for j := range body {
+			body[j] = Point{X: length - j, Y: i * 4}
+		}
+		snakes = append(snakes, snakeAt(string(rune('A'+i)), "right", body...))
+	}
+	r := newMoveRoom(100, 40, snakes...)
+	r.foods = []Food{{Point: Point{X: 80, Y: 3}}, {Point: Point{X: 90, Y: 17}}}
+
+	// 每条蛇向右（穿墙）前进一格
+	advance := func() {
+		for _, s := range snakes {
+			head := Point{X: (s.Body[0].X + 1) % r.width, Y: s.Body[0].Y}
+			s.Body = append([]Point{head}, s.Body[:len(s.Body)-1]...)
+		}
+		r.tick++
+	}
+
+	b.Run("full", func(b *testing.B) {
+		total := 0
+		for i := 0; i < b.N; i++ {
+			advance()
+			data, _ := json.Marshal(r.stateMessage())
+			total += len(data)
+		}
+		b.ReportMetric(float64(total)/float64(b.N), "bytes/tick")
+	})
+	b.Run("delta", func(b *testing.B) {
+		r.sent = r.captureSent()
+		total := 0
+		for i := 0; i < b.N; i++ {
+			advance()
+			data, _ := json.Marshal(r.buildDelta(r.sent))
+			r.sent = r.captureSent()
+			total += len(data)
+		}
+		b.ReportMetric(float64(total)/float64(b.N), "bytes/tick")
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import "time"
+
+// 休眠房间的tick间隔
+const dormantInterval = 2 * time.Second
+
+// 房间能否休眠：没有存活或出生保护中的蛇、没有等待重生的机器人，
+// 回合制房间也不在等待开局，此时每tick的状态都不会变化，调用方需持有房间锁
+func (r *Room) idleLocked() bool {
+	for _, s := range r.players {
+		if s.Alive || s.Spawning || (s.Bot && r.match == nil) {
+			return false
+		}
+	}
+	if r.match != nil && !r.match.active && len(r.players) >= 2 {
+		return false
+	}
+	return true
+}
+
+// 唤醒休眠的房间，让循环立即恢复正常间隔；不阻塞，重复唤醒会合并
+func (r *Room) wakeUp() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 每tick后检查是否需要切换休眠状态，返回新的tick间隔，不需要切换时为0
+func (r *Room) nextInterval() time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	idle := r.idleLocked()
+	if idle == r.dormant {
+		return 0
+	}
+	r.dormant = idle
+	if idle {
+		return dormantInterval
+	}
+	return r.interval
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 二进制状态帧（?enc=bin），以 websocket.BinaryMessage 发送，替代JSON的state/delta帧；
+// welcome、事件、聊天等其他消息仍为JSON文本。多字节整数均为无符号varint（小端的LEB128），
+// 坐标因棋盘不超过200x200而各占1字节。布局：
+//
+//	u8      版本号，当前为4
+//	varint  tick
+//	varint  宽度
+//	varint  高度
+//	u8 left, u8 top, u8 right, u8 bottom  可活动范围（含边界），见storm.go
+//	varint  观战人数
+//	varint  食物数量N，之后N个食物：
+//	  u8 x, u8 y, u8 种类（0普通 1金色 2缩短）
+//	varint  道具数量P，之后P个道具：
+//	  u8 x, u8 y, u8 种类（0幽灵 1减半）
+//	varint  危险格数量Z，之后Z个危险格：
+//	  u8 x, u8 y, u8 种类（0毒格）
+//	varint  蛇的数量M，之后M条蛇：
+//	  varint ID长度，ID字节（UTF-8）
+//	  varint 得分
+//	  u8     标志位：bit0存活 bit1出生保护 bit2机器人 bit3等待下一回合 bit4幽灵
+//	  u8     方向（0上 1下 2左 3右）
+//	  u8     颜色下标
+//	  varint 身体长度L，之后L对 u8 x, u8 y（从头到尾）
+const binVersion = 4
+
+// 二进制帧中的一条蛇
+type BinSnake struct {
+	ID       string
+	Score    int
+	Alive    bool
+	Spawning bool
+	Bot      bool
+	Waiting  bool
+	Ghost    bool
+	Dir      string
+	Color    int
+	Body     []Point
+}
+
+// 二进制帧携带的完整状态
+type BinState struct {
+	Tick       int64
+	Width      int
+	Height     int
+	Bounds     Bounds
+	Spectators int
+	Foods      []Food
+	PowerUps   []PowerUp
+	Hazards    []Hazard
+	Snakes     []BinSnake
+}
+
+// 食物种类、道具种类与方向的编码表
+var (
+	binFoodKinds  = []string{FoodNormal, FoodGolden, FoodShrink}
+	binPowerKinds = []string{PowerGhost, PowerShrink}
+	binHazardKind = []string{HazardPoison}
+	binDirs       = []string{"up", "down", "left", "right"}
+)
+
+// 标志位
+const (
+	binAlive = 1 << iota
+	binSpawning
+	binBot
+	binWaiting
+	binGhost
+)
+
+// 在表中查找下标，找不到返回0
+func binIndex(table []string, v string) byte {
+	for i, s := range table {
+		if s == v {
+			return byte(i)
+		}
+	}
+	return 0
+}
+
+// 编码状态帧
+func encodeState(st BinState) []byte {
+	buf := make([]byte, 0, 64+(len(st.Foods)+len(st.PowerUps)+len(st.Hazards))*3+len(st.Snakes)*32)
+	buf = append(buf, binVersion)
+	buf = binary.AppendUvarint(buf, uint64(st.Tick))
+	buf = binary.AppendUvarint(buf, uint64(st.Width))
+	buf = binary.AppendUvarint(buf, uint64(st.Height))
+	buf = append(buf, byte(st.Bounds.Left), byte(st.Bounds.Top), byte(st.Bounds.Right), byte(st.Bounds.Bottom))
+	buf = binary.AppendUvarint(buf, uint64(st.Spectators))
+
+	buf = binary.AppendUvarint(buf, uint64(len(st.Foods)))
+	for _, f := range st.Foods {
+		buf = append(buf, byte(f.X), byte(f.Y), binIndex(binFoodKinds, f.Kind))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(st.PowerUps)))
+	for _, pu := range st.PowerUps {
+		buf = append(buf, byte(pu.X), byte(pu.Y), binIndex(binPowerKinds, pu.Kind))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(st.Hazards)))
+	for _, h := range st.Hazards {
+		buf = append(buf, byte(h.X), byte(h.Y), binIndex(binHazardKind, h.Kind))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(st.Snakes)))
+	for _, s := range st.Snakes {
+		buf = binary.AppendUvarint(buf, uint64(len(s.ID)))
+		buf = append(buf, s.ID...)
+		buf = binary.AppendUvarint(buf, uint64(s.Score))
+		var flags byte
+		if s.Alive {
+			flags |= binAlive
+		}
+		if s.Spawning {
+			flags |= binSpawning
+		}
+		if s.Bot {
+			flags |= binBot
+		}
+		if s.Waiting {
+			flags |= binWaiting
+		}
+		if s.Ghost {
+			flags |= binGhost
+		}
+		buf = append(buf, flags, binIndex(binDirs, s.Dir), byte(s.Color))
+		buf = binary.AppendUvarint(buf, uint64(len(s.Body)))
+		for _, p := range s.Body {
+			buf = append(buf, byte(p.X), byte(p.Y))
+		}
+	}
+	return buf
+}
+
+// 解码时的读取游标，出错后的读取都返回零值
+type binReader struct {
+	b   []byte
+	err error
+}
+
+func (r *binReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errors.New("bad varint")
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *binReader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.b)) < n {
+		r.err = errors.New("unexpected end of frame")
+		return nil
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *binReader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// 从表中取值，越界时记录错误
+func (r *binReader) lookup(table []string, what string) string {
+	i := r.byte()
+	if r.err != nil {
+		return ""
+	}
+	if int(i) >= len(table) {
+		r.err = fmt.Errorf("bad %s %d", what, i)
+		return ""
+	}
+	return table[i]
+}
+
+// 解码状态帧
+func decodeState(data []byte) (BinState, error) {
+	r := &binReader{b: data}
+	var st BinState
+	if v := r.byte(); r.err == nil && v != binVersion {
+		return st, fmt.Errorf("unsupported version %d", v)
+	}
+	st.Tick = int64(r.uvarint())
+	st.Width = int(r.uvarint())
+	st.Height = int(r.uvarint())
+	st.Bounds = Bounds{Left: int(r.byte()), Top: int(r.byte()), Right: int(r.byte()), Bottom: int(r.byte())}
+	st.Spectators = int(r.uvarint())
+
+	nFoods := r.uvarint()
+	if r.err == nil && nFoods > uint64(len(r.b)/3) {
+		return st, errors.New("food count exceeds frame size")
+	}
+	for i := uint64(0); i < nFoods && r.err == nil; i++ {
+		f := Food{Point: Point{X: int(r.byte()), Y: int(r.byte())}}
+		f.Kind = r.lookup(binFoodKinds, "food kind")
+		st.Foods = append(st.Foods, f)
+	}
+
+	nPowers := r.uvarint()
+	if r.err == nil && nPowers > uint64(len(r.b)/3) {
+		return st, errors.New("power-up count exceeds frame size")
+	}
+	for i := uint64(0); i < nPowers && r.err == nil; i++ {
+		pu := PowerUp{Point: Point{X: int(r.byte()), Y: int(r.byte())}}
+		pu.Kind = r.lookup(binPowerKinds, "power-up kind")
+		st.PowerUps = append(st.PowerUps, pu)
+	}
+
+	nHazards := r.uvarint()
+	if r.err == nil && nHazards > uint64(len(r.b)/3) {
+		return st, errors.New("hazard count exceeds frame size")
+	}
+	for i := uint64(0); i < nHazards && r.err == nil; i++ {
+		h := Hazard{Point: Point{X: int(r.byte()), Y: int(r.byte())}}
+		h.Kind = r.lookup(binHazardKind, "hazard kind")
+		st.Hazards = append(st.Hazards, h)
+	}
+
+	nSnakes := r.uvarint()
+	if r.err == nil && nSnakes > uint64(len(r.b)) {
+		return st, errors.New("snake count exceeds frame size")
+	}
+	for i := uint64(0); i < nSnakes && r.err == nil; i++ {
+		var s BinSnake
+		s.ID = string(r.bytes(r.uvarint()))
+		s.Score = int(r.uvarint())
+		flags := r.byte()
+		s.Alive = flags&binAlive != 0
+		s.Spawning = flags&binSpawning != 0
+		s.Bot = flags&binBot != 0
+		s.Waiting = flags&binWaiting != 0
+		s.Ghost = flags&binGhost != 0
+		s.Dir = r.lookup(binDirs, "dir")
+		s.Color = int(r.byte())
+		n := r.uvarint()
+		raw := r.bytes(n * 2)
+		for j := 0; j+1 < len(raw); j += 2 {
+			s.Body = append(s.Body, Point{X: int(raw[j]), Y: int(raw[j+1])})
+		}
+		st.Snakes = append(st.Snakes, s)
+	}
+	if r.err != nil {
+		return st, r.err
+	}
+	if len(r.b) != 0 {
+		return st, errors.New("trailing bytes after frame")
+	}
+	return st, nil
+}
+
+// 当前状态的二进制帧，按ID排序，调用方需持有房间锁
+func (r *Room) binaryState() []byte {
+	st := BinState{
+		Tick:       r.tick,
+		Width:      r.width,
+		Height:     r.height,
+		Bounds:     r.bounds(),
+		Spectators: len(r.watchers),
+		Foods:      r.foods,
+		PowerUps:   r.powerups,
+		Hazards:    r.hazards,
+	}
+	for _, s := range r.sortedPlayers() {
+		st.Snakes = append(st.Snakes, BinSnake{
+			ID:       s.ID,
+			Score:    s.Score,
+			Alive:    s.Alive,
+			Spawning: s.Spawning,
+			Bot:      s.Bot,
+			Waiting:  s.Waiting,
+			Ghost:    s.Ghost > 0,
+			Dir:      s.Dir,
+			Color:    s.Color,
+			Body:     s.Body,
+		})
+	}
+	return encodeState(st)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 编码再解码得到相同的状态。ExpiresIn不在二进制帧中，样例里都为0
+var binSamples = []struct {
+	name string
+	st   BinState
+}{
+	{"empty board", BinState{Width: 20, Height: 20, Bounds: Bounds{Right: 19, Bottom: 19}}},
+	{"full frame", BinState{
+		Tick:       123456,
+		Width:      200,
+		Height:     150,
+		Bounds:     Bounds{Left: 2, Top: 2, Right: 197, Bottom: 147},
+		Spectators: 300,
+		Foods:      []Food{{Point: Point{X: 0, Y: 0}, Kind: FoodNormal}, {Point: Point{X: 199, Y: 149}, Kind: FoodGolden}, {Point: Point{X: 5, Y: 7}, Kind: FoodShrink}},
+		PowerUps:   []PowerUp{{Point: Point{X: 3, Y: 4}, Kind: PowerGhost}, {Point: Point{X: 9, Y: 9}, Kind: PowerShrink}},
+		Hazards:    []Hazard{{Point: Point{X: 10, Y: 11}, Kind: HazardPoison}},
+		Snakes: []BinSnake{
+			{ID: "P1", Score: 1000, Alive: true, Dir: "right", Color: 3, Body: []Point{{X: 10, Y: 10}, {X: 9, Y: 10}, {X: 8, Y: 10}}},
+			{ID: "玩家2", Spawning: true, Bot: true, Waiting: true, Ghost: true, Dir: "up", Color: 255},
+			{ID: "B3", Dir: "left", Body: []Point{{X: 0, Y: 149}}},
+		},
+	}},
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, tt := range binSamples {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeState(tt.st)
+			got, err := decodeState(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.st) {
+				t.Errorf("round trip:\n got %+v\nwant %+v", got, tt.st)
+			}
+		})
+	}
+}
+
+// 截断、版本不符和多余字节的帧返回错误
+func TestDecodeStateErrors(t *testing.T) {
+	data := encodeState(binSamples[1].st)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated", data[:len(data)-1]},
+		{"trailing bytes", append(append([]byte(nil), data...), 0)},
+		{"other version", append([]byte{binVersion + 1}, data[1:]...)},
+	}
+	for _, tt := range tests {
+		if _, err := decodeState(tt.data); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
+
+// 任意输入都不能让解码崩溃；能解码的帧重新编码后解码结果不变
+func FuzzDecode(f *testing.F) {
+	for _, s := range binSamples {
+		f.Add(encodeState(s.st))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		st, err := decodeState(data)
+		if err != nil {
+			return
+		}
+		again, err := decodeState(encodeState(st))
+		if err != nil {
+			t.Fatalf("re-encoded frame does not decode: %v", err)
+		}
+		if !reflect.DeepEqual(again, st) {
+			t.Fatalf("re-encode changed the state:\n got %+v\nwant %+v", again, st)
+		}
+	})
+}
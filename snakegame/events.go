@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"golearn/snakegame/protocol"
+)
+
+// 广播给客户端的事件，定义见 protocol 包
+type (
+	DeathEvent      = protocol.DeathEvent
+	PersonalBestMsg = protocol.PersonalBestMsg
+	LeaveEvent      = protocol.LeaveEvent
+	JoinEvent       = protocol.JoinEvent
+)
+
+// 序列化后广播给房间内所有连接，所有JSON广播都经过这里，调用方需持有房间锁
+func (r *Room) emit(ev interface{}) {
+	data, _ := json.Marshal(ev)
+	r.broadcastLocked(data)
+}
+
+// 蛇死亡：保存分数并广播死亡事件，killer为撞上的对手，其他原因为nil。
+// 真人玩家的事件在写协程查到此前最高分后再发，破纪录时先单独通知本人；
+// 入队失败时立即广播不带pb的事件，调用方需持有房间锁
+func (r *Room) die(snake *Snake, cause string, killer *Snake) {
+	ev := DeathEvent{Type: protocol.TypeDeath, Player: snake.ID, Cause: cause, Score: snake.Score}
+	attrs := []interface{}{"cause", cause, "score", snake.Score}
+	if killer != nil {
+		ev.Killer = killer.ID
+		attrs = append(attrs, "killer", killer.ID)
+	}
+	level := slog.LevelInfo
+	if snake.Bot {
+		level = slog.LevelDebug
+	}
+	r.playerLog(snake).Log(context.Background(), level, "player died", attrs...)
+	queued := r.queueScore(snake, cause, killer, func(prev int, found bool, err error) {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if r.closed {
+			return
+		}
+		if err == nil && ev.Score > prev {
+			ev.PB = true
+			msg := PersonalBestMsg{Type: "personal_best", Score: ev.Score}
+			if found {
+				msg.Previous = &prev
+			}
+			if snake.conn != nil {
+				snake.conn.sendJSON(msg)
+			}
+		}
+		r.emit(ev)
+	})
+	if !queued {
+		r.emit(ev)
+	}
+}
+
+// 广播玩家加入，调用方需持有房间锁
+func (r *Room) emitJoin(s *Snake) {
+	ev := JoinEvent{Type: "join", Player: s.ID, Name: s.Name, Bot: s.Bot}
+	if len(s.Body) > 0 {
+		head := s.Body[0]
+		ev.Spawn = &head
+	}
+	r.emit(ev)
+}
@@ -0,0 +1,125 @@
+// 示例机器人：用 snakegame/client 连接服务器，每帧朝最近的食物前进，
+// 避开墙、障碍物、收缩后的场地边缘和所有蛇身。不处理环形地图。
+//
+//	go run ./snakegame/examples/chasebot -url 'ws://localhost:8080/ws/bots'
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golearn/snakegame/client"
+	"golearn/snakegame/protocol"
+)
+
+var dirs = []protocol.Dir{protocol.Up, protocol.Down, protocol.Left, protocol.Right}
+
+func main() {
+	addr := flag.String("url", "ws://localhost:8080/ws/bots", "WebSocket地址，房间参数可写在查询串中")
+	name := flag.String("name", "chasebot", "昵称")
+	pass := flag.String("pass", "", "私人房间口令")
+	flag.Parse()
+
+	obstacles := make(map[protocol.Point]bool)
+	c, err := client.Dial(*addr, client.Options{
+		Name:      *name,
+		Pass:      *pass,
+		Reconnect: true,
+		OnWelcome: func(c *client.Client, w *protocol.Welcome) {
+			slog.Info("joined", "room", w.Room, "player", w.Player, "resumed", w.Resumed)
+			clear(obstacles)
+			for _, p := range w.Obstacles {
+				obstacles[p] = true
+			}
+		},
+		OnState: func(c *client.Client, st *protocol.State) {
+			if d, ok := chase(st, c.Player(), obstacles); ok {
+				_ = c.SendDir(d)
+			}
+		},
+		OnDeath: func(c *client.Client, ev *protocol.DeathEvent) {
+			if ev.Player == c.Player() {
+				slog.Info("died", "cause", ev.Cause, "score", ev.Score)
+			}
+		},
+	})
+	if err != nil {
+		slog.Error("dial failed", "err", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case <-ctx.Done():
+		c.Close()
+	case <-c.Done():
+	}
+	if err := c.Err(); err != nil {
+		slog.Error("disconnected", "err", err)
+		os.Exit(1)
+	}
+}
+
+// 选择下一步的方向：在安全且不掉头的方向中取离最近食物最近的一个
+func chase(st *protocol.State, me string, obstacles map[protocol.Point]bool) (protocol.Dir, bool) {
+	self := st.Players[me]
+	if self == nil || !self.Alive || self.Spawning || len(self.Body) == 0 {
+		return "", false
+	}
+	head := self.Body[0]
+	occupied := make(map[protocol.Point]bool)
+	for _, p := range st.Players {
+		for _, b := range p.Body {
+			occupied[b] = true
+		}
+	}
+
+	target, found := nearestFood(st.Foods, head)
+	var best protocol.Dir
+	bestDist := -1
+	for _, d := range dirs {
+		if d == protocol.Dir(self.Dir).Opposite() {
+			continue
+		}
+		next := head.Step(d)
+		if !st.Bounds.Contains(next) || obstacles[next] || occupied[next] {
+			continue
+		}
+		dist := 0
+		if found {
+			dist = manhattan(next, target)
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = d, dist
+		}
+	}
+	return best, bestDist >= 0
+}
+
+// 离p最近的食物
+func nearestFood(foods []protocol.Food, p protocol.Point) (protocol.Point, bool) {
+	var best protocol.Point
+	bestDist := -1
+	for _, f := range foods {
+		if d := manhattan(f.Point, p); bestDist < 0 || d < bestDist {
+			best, bestDist = f.Point, d
+		}
+	}
+	return best, bestDist >= 0
+}
+
+func manhattan(a, b protocol.Point) int {
+	return abs(a.X-b.X) + abs(a.Y-b.Y)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 房间空闲过期：超过idleTTL没有真人玩家吃到食物、加入或转向时关闭房间，
+// 只有观战者或只剩一个挂机玩家的房间因此不会一直存在
+const (
+	defaultRoomTTL    = 30 * time.Minute
+	roomExpiryWarning = 5 * time.Minute  // 过期前多久广播room_expiring
+	idleCheckInterval = 15 * time.Second // runLoop检查空闲的间隔
+)
+
+// 记录一次游戏活动，重新开始计时，调用方需持有房间锁
+func (r *Room) touch() {
+	r.lastActive = time.Now()
+	r.expiryWarned = false
+}
+
+// 检查空闲时间：临近过期时广播一次room_expiring，已过期返回true，调用方不能持有房间锁
+func (r *Room) checkExpiry(now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.idleTTL <= 0 || r.closed {
+		return false
+	}
+	idle := now.Sub(r.lastActive)
+	if idle >= r.idleTTL {
+		return true
+	}
+	if left := r.idleTTL - idle; left <= roomExpiryWarning && !r.expiryWarned {
+		r.expiryWarned = true
+		r.emit(map[string]interface{}{"type": "room_expiring", "expires_in_sec": int(left.Seconds())})
+		r.log.Info("room expiring", "idle", idle.Round(time.Second).String())
+	}
+	return false
+}
+
+// 空闲过期的房间：从服务器移除，保存分数并通知所有连接后关闭
+func (s *GameServer) expireRoom(room *Room) {
+	s.lock.Lock()
+	owned := s.rooms[room.name] == room
+	if owned {
+		delete(s.rooms, room.name)
+	}
+	s.lock.Unlock()
+	if owned && s.cluster != nil {
+		s.cluster.release(room.name)
+	}
+	room.terminate(CauseExpired, "room_expired", websocket.CloseNormalClosure, "room expired after being idle")
+}
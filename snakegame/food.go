@@ -0,0 +1,167 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"golearn/snakegame/protocol"
+)
+
+// 食物种类
+const (
+	FoodNormal = "normal" // +1分，+1长度
+	FoodGolden = "golden" // +5分，+1长度，限时消失
+	FoodShrink = "shrink" // 尾部减少2节，最短为1
+)
+
+// 金色食物存在的tick数
+const goldenFoodTTL = 25
+
+// 食物，坐标字段平铺在JSON中以兼容旧客户端
+type Food = protocol.Food
+
+// 各种食物的生成权重
+type FoodWeights map[string]int
+
+// 默认权重：金色食物较少见
+func defaultFoodWeights() FoodWeights {
+	return FoodWeights{FoodNormal: 85, FoodGolden: 5, FoodShrink: 10}
+}
+
+// 解析 ?food=normal:80,golden:10,shrink:10，未知种类和非法权重被忽略
+func parseFoodWeights(s string) FoodWeights {
+	w := defaultFoodWeights()
+	if s == "" {
+		return w
+	}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if _, ok := w[kv[0]]; !ok {
+			continue
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 0 {
+			continue
+		}
+		w[kv[0]] = n
+	}
+	return w
+}
+
+// 按权重随机选择食物种类，权重全为0时退化为普通食物
+func (r *Room) pickFoodKind() string {
+	total := 0
+	for _, n := range r.foodWeights {
+		total += n
+	}
+	if total == 0 {
+		return FoodNormal
+	}
+	n := r.rng.Intn(total)
+	for _, kind := range []string{FoodNormal, FoodGolden, FoodShrink} {
+		if n < r.foodWeights[kind] {
+			return kind
+		}
+		n -= r.foodWeights[kind]
+	}
+	return FoodNormal
+}
+
+// 在空位生成一个随机种类的食物，没有空位时ok为false
+func (r *Room) newFood() (f Food, ok bool) {
+	p, ok := r.randomEmptyCell()
+	if !ok {
+		return Food{}, false
+	}
+	f = Food{Point: p, Kind: r.pickFoodKind()}
+	if f.Kind == FoodGolden {
+		f.ExpiresIn = goldenFoodTTL
+	}
+	return f, true
+}
+
+// 当前人数下应保持的食物数量：max(1, 玩家数/2)
+func (r *Room) foodTarget() int {
+	n := len(r.players) / 2
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// 返回p处食物的下标，没有则返回-1
+func (r *Room) foodAt(p Point) int {
+	for i, f := range r.foods {
+		if f.Point == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// 移除下标为i的食物
+func (r *Room) removeFood(i int) {
+	r.foods = append(r.foods[:i], r.foods[i+1:]...)
+	r.dirty = true
+}
+
+// 限时食物倒计时，到期的在本tick内移除
+func (r *Room) expireFood() {
+	kept := r.foods[:0]
+	for _, f := range r.foods {
+		if f.ExpiresIn > 0 {
+			f.ExpiresIn--
+			if f.ExpiresIn == 0 {
+				r.dirty = true
+				continue
+			}
+		}
+		kept = append(kept, f)
+	}
+	r.foods = kept
+}
+
+// 补充食物到目标数量；人数减少时多出的食物不回收，吃掉后不再补充。
+// 棋盘已满时本tick不再生成，下个tick重试，并在刚满时广播board_full
+func (r *Room) refillFood() {
+	for len(r.foods) < r.foodTarget() {
+		f, ok := r.newFood()
+		if !ok {
+			if !r.boardFull {
+				r.boardFull = true
+				r.emit(map[string]interface{}{"type": "board_full", "tick": r.tick})
+			}
+			return
+		}
+		r.foods = append(r.foods, f)
+		r.dirty = true
+	}
+	r.boardFull = false
+}
+
+// 蛇吃到食物后的效果；会变长的食物已在前进时保留了尾巴，这里只加分
+func eatFood(snake *Snake, f Food) {
+	switch f.Kind {
+	case FoodShrink:
+		n := len(snake.Body) - 2
+		if n < 1 {
+			n = 1
+		}
+		snake.Body = snake.Body[:n]
+	case FoodGolden:
+		snake.Score += 5
+	default:
+		snake.Score++
+	}
+}
+
+// 第一个食物，兼容旧客户端的food字段
+func (r *Room) firstFood() Food {
+	if len(r.foods) == 0 {
+		return Food{}
+	}
+	return r.foods[0]
+}
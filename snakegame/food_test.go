@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 4x4棋盘，除free外的格子都被一条蛇占满
+func crowdedRoom(free ...Point) *Room {
+	return crowdedBoard(4, 4, free...)
+}
+
+// width x height的棋盘，除free外的格子都被一条蛇头在(0,0)的蛇占满
+func crowdedBoard(width, height int, free ...Point) *Room {
+	var body []Point
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if p := (Point{X: x, Y: y}); !slices.Contains(free, p) {
+				body = append(body, p)
+			}
+		}
+	}
+	r := newMoveRoom(width, height, snakeAt("P1", "right", body...))
+	r.rng = rand.New(rand.NewSource(1))
+	r.watchers = make(map[*Conn]bool)
+	return r
+}
+
+// 只剩一格时总是选中它，没有空格时返回false
+func TestRandomEmptyCell(t *testing.T) {
+	tests := []struct {
+		name string
+		free []Point
+	}{
+		{"one free cell", []Point{{X: 2, Y: 3}}},
+		{"full board", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := crowdedRoom(tt.free...)
+			for i := 0; i < 50; i++ {
+				p, ok := r.randomEmptyCell()
+				if len(tt.free) == 0 {
+					if ok {
+						t.Fatalf("full board: got %v, want no cell", p)
+					}
+					continue
+				}
+				if !ok || p != tt.free[0] {
+					t.Fatalf("got %v, %v, want %v", p, ok, tt.free[0])
+				}
+			}
+		})
+	}
+}
+
+// 棋盘满时不生成食物，只广播一次board_full；腾出空格后下一次补充恢复生成
+func TestRefillFoodBoardFull(t *testing.T) {
+	r := crowdedRoom()
+	w := allocConn(time.Second)
+	r.watchers[w] = true
+
+	r.refillFood()
+	r.refillFood()
+	if len(r.foods) != 0 || !r.boardFull {
+		t.Fatalf("foods=%v boardFull=%v, want none and true", r.foods, r.boardFull)
+	}
+	if n := len(w.send); n != 1 {
+		t.Fatalf("watcher got %d messages, want one board_full", n)
+	}
+	if msg := <-w.send; !strings.Contains(string(msg.data), `"board_full"`) {
+		t.Errorf("message = %s, want board_full", msg.data)
+	}
+
+	s := r.players["P1"]
+	freed := s.Body[len(s.Body)-1]
+	s.Body = s.Body[:len(s.Body)-1]
+	r.refillFood()
+	if len(r.foods) != 1 || r.foods[0].Point != freed || r.boardFull {
+		t.Errorf("after freeing %v: foods=%v boardFull=%v", freed, r.foods, r.boardFull)
+	}
+}
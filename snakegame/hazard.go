@@ -0,0 +1,96 @@
+package main
+
+import "golearn/snakegame/protocol"
+
+// 危险格种类
+const (
+	HazardPoison = "poison" // 毒格：尾部减少3节、扣2分，不致死
+)
+
+// 毒格参数，生成间隔可按房间用 ?poison=N 调整，0表示不生成
+const (
+	defaultPoisonEvery = 100 // 平均每隔多少tick生成一个毒格
+	minPoisonEvery     = 10
+	maxPoisonEvery     = 1000
+	hazardTTL          = 100 // 未被触发的危险格保留的tick数
+	maxHazards         = 3   // 棋盘上同时存在的危险格上限
+	poisonTrim         = 3   // 触发毒格后减少的节数，最短保留1节
+	poisonPenalty      = 2   // 触发毒格后扣除的分数，最低为0
+)
+
+// 棋盘上的危险格，与食物、道具分开下发
+type Hazard = protocol.Hazard
+
+// 返回p处危险格的下标，没有则返回-1
+func (r *Room) hazardAt(p Point) int {
+	for i, h := range r.hazards {
+		if h.Point == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// 危险格倒计时，到期的移除，并按生成间隔随机生成新的毒格，调用方需持有房间锁
+func (r *Room) tickHazards() {
+	kept := r.hazards[:0]
+	for _, h := range r.hazards {
+		h.ExpiresIn--
+		if h.ExpiresIn > 0 {
+			kept = append(kept, h)
+		} else {
+			r.dirty = true
+		}
+	}
+	r.hazards = kept
+
+	if r.poisonEvery <= 0 || len(r.hazards) >= maxHazards || r.rng.Intn(r.poisonEvery) != 0 {
+		return
+	}
+	p, ok := r.randomEmptyCell()
+	if !ok {
+		return
+	}
+	r.hazards = append(r.hazards, Hazard{Point: p, Kind: HazardPoison, ExpiresIn: hazardTTL})
+	r.dirty = true
+}
+
+// 蛇头进入p处的危险格：毒格减少尾部并扣分，随后消失。
+// 在食物判定之前调用，调用方需持有房间锁
+func (r *Room) triggerHazard(snake *Snake, p Point) {
+	i := r.hazardAt(p)
+	if i < 0 {
+		return
+	}
+	if r.hazards[i].Kind == HazardPoison {
+		n := len(snake.Body) - poisonTrim
+		if n < 1 {
+			n = 1
+		}
+		snake.Body = snake.Body[:n]
+		snake.Score -= poisonPenalty
+		if snake.Score < 0 {
+			snake.Score = 0
+		}
+	}
+	r.hazards = append(r.hazards[:i], r.hazards[i+1:]...)
+	r.dirty = true
+}
+
+// 危险格列表的副本，没有时为空数组而不是null，调用方需持有房间锁
+func (r *Room) hazardList() []Hazard {
+	return append([]Hazard{}, r.hazards...)
+}
+
+// 判断两组危险格的位置和种类是否相同，剩余时间不计入比较
+func sameHazards(a, b []Hazard) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Point != b[i].Point || a[i].Kind != b[i].Kind {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTP方向接口的请求体
+type dirRequest struct {
+	Dir   string `json:"dir"`
+	Token string `json:"token"` // 欢迎消息中的会话令牌
+}
+
+// 通过HTTP提交方向，供不方便使用WebSocket的脚本机器人调用；
+// 校验（不能掉头、限流）与WebSocket相同，状态可轮询 /api/rooms/:room 获取。
+// POST /api/rooms/:room/players/:id/dir {"dir":"left","token":"..."}
+func (s *GameServer) postDir(c *gin.Context) {
+	room := s.findRoom(c.Param("room"))
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+	var req dirRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: " + err.Error()})
+		return
+	}
+	if !validDir(req.Dir) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid direction: " + req.Dir})
+		return
+	}
+
+	room.lock.Lock()
+	snake := room.players[c.Param("id")]
+	if snake == nil {
+		room.lock.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "player not found"})
+		return
+	}
+	if req.Token == "" || subtle.ConstantTimeCompare([]byte(req.Token), []byte(snake.token)) != 1 {
+		room.lock.Unlock()
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid token"})
+		return
+	}
+	if snake.httpLimit == nil {
+		snake.httpLimit = newRateLimiter(inboundRate, inboundBurst)
+	}
+	if !snake.httpLimit.Allow() {
+		room.lock.Unlock()
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+		return
+	}
+	// 断线保留期内的蛇同样不会移动
+	if !snake.Alive || snake.detached {
+		room.lock.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "snake is not alive"})
+		return
+	}
+	queued := room.changeDir(snake, req.Dir)
+	pending := len(snake.pending)
+	room.lock.Unlock()
+	room.wakeUp()
+
+	// 掉头、与当前方向相同或队列已满时 queued 为false
+	c.JSON(http.StatusOK, gin.H{"ok": true, "queued": queued, "pending": pending})
+}
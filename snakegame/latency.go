@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+
+	"golearn/snakegame/protocol"
+)
+
+// 延迟测量：服务器定期向玩家发送 {"type":"ping","id":n,"t":<Unix毫秒>}，
+// 客户端原样回复 {"type":"pong","id":n}。走JSON消息而不是控制帧，测得的是包含
+// 发送队列和客户端处理在内的完整应用层往返时间。每个连接只记住最近一次探测，
+// 回复迟到（已发出更新的探测）的pong直接忽略，不会积累状态
+const latencyProbeInterval = 2 * time.Second
+
+// 服务器发出的延迟探测
+type PingMsg = protocol.PingMsg
+
+// 向conn发送一次探测，距上次不足latencyProbeInterval时跳过
+func (c *Conn) probeLatency(now time.Time) {
+	c.mu.Lock()
+	if now.Sub(c.probeAt) < latencyProbeInterval {
+		c.mu.Unlock()
+		return
+	}
+	c.probeID++
+	c.probeAt = now
+	c.probeAnswered = false
+	msg := PingMsg{Type: protocol.TypePing, ID: c.probeID, T: now.UnixMilli()}
+	c.mu.Unlock()
+	c.sendJSON(msg)
+}
+
+// 处理客户端的pong，只接受最近一次探测的回复，RTT按 1/8 的权重平滑
+func (c *Conn) recordPong(id int64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id != c.probeID || c.probeAt.IsZero() || c.probeAnswered {
+		return
+	}
+	c.probeAnswered = true
+	sample := now.Sub(c.probeAt)
+	if c.rtt == 0 {
+		c.rtt = sample
+	} else {
+		c.rtt += (sample - c.rtt) / 8
+	}
+}
+
+// 平滑后的往返时间（毫秒），还没有测量结果时为0
+func (c *Conn) latencyMS() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.rtt.Milliseconds())
+}
+
+// 向所有在线玩家发送延迟探测，每tick调用，调用方需持有房间锁
+func (r *Room) probeLatency(now time.Time) {
+	for _, s := range r.players {
+		if s.conn != nil {
+			s.conn.probeLatency(now)
+		}
+	}
+}
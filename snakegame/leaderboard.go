@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 排行榜结构体
+type RankRow struct {
+	PlayerID   string `json:"player_id"`
+	Name       string `json:"name"` // 显示昵称，即snake_score.player_id
+	Room       string `json:"room"`
+	Best       int    `json:"best_score"`
+	BestLength int    `json:"best_length"` // 单局最大长度，早于长度统计的记录为0
+	Games      int    `json:"games"`
+	Last       string `json:"last_play"`
+}
+
+// 排行榜查询参数
+type rankQuery struct {
+	room   string // 房间名，精确匹配；为空表示汇总所有房间
+	limit  int
+	offset int
+	since  time.Time // 零值表示不限时间
+	window string    // 原始since参数或周期起点，作为缓存key的一部分
+	period string    // 排行周期：daily、weekly 或 alltime
+	until  time.Time // 周期结束时间（不含），仅用于响应
+	sort   string    // 排序依据：score 或 length
+}
+
+// 排行周期
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodAllTime = "alltime"
+)
+
+// 排行榜排序依据，?sort=length 按单局最大长度排序，同长度再比分数
+const (
+	SortScore  = "score"
+	SortLength = "length"
+)
+
+// ORDER BY 的排序列，只会返回固定的列名
+func (q rankQuery) orderBy() string {
+	if q.sort == SortLength {
+		return "best_length DESC, best_score DESC"
+	}
+	return "best_score DESC"
+}
+
+// 计算now所在周期的起止时间，按loc的自然日划分，每周从周一开始
+func periodWindow(period string, now time.Time, loc *time.Location) (start, end time.Time) {
+	now = now.In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if period == PeriodDaily {
+		return day, day.AddDate(0, 0, 1)
+	}
+	offset := (int(day.Weekday()) + 6) % 7 // 距周一的天数
+	start = day.AddDate(0, 0, -offset)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// 解析排行榜参数，非法参数返回错误；周期按loc时区划分
+func parseRankQuery(c *gin.Context, now time.Time, loc *time.Location) (rankQuery, error) {
+	q := rankQuery{period: PeriodAllTime, sort: SortScore}
+	if v, ok := c.GetQuery("room"); ok {
+		if v == "" {
+			return q, errors.New("room must not be empty")
+		}
+		q.room = v
+	}
+
+	var err error
+	if q.limit, q.offset, err = parsePage(c); err != nil {
+		return q, err
+	}
+	if v := c.Query("since"); v != "" {
+		t, err := parseSince(v, now)
+		if err != nil {
+			return q, err
+		}
+		q.since = t
+		q.window = v
+	}
+	switch v := c.Query("sort"); v {
+	case "", SortScore:
+	case SortLength:
+		q.sort = v
+	default:
+		return q, errors.New("sort must be score or length")
+	}
+	switch p := c.Query("period"); p {
+	case "", PeriodAllTime:
+	case PeriodDaily, PeriodWeekly:
+		if q.window != "" {
+			return q, errors.New("use either period or since, not both")
+		}
+		q.period = p
+		q.since, q.until = periodWindow(p, now, loc)
+		q.window = p + "@" + q.since.Format(time.RFC3339)
+	default:
+		return q, errors.New("period must be daily, weekly or alltime")
+	}
+	return q, nil
+}
+
+// 响应中的周期信息，alltime没有起止时间
+func (q rankQuery) periodInfo() gin.H {
+	info := gin.H{"name": q.period}
+	if q.period != PeriodAllTime {
+		info["start"] = q.since.Format(time.RFC3339)
+		info["end"] = q.until.Format(time.RFC3339)
+	}
+	return info
+}
+
+// 解析分页参数 limit、offset、page，limit默认10
+func parsePage(c *gin.Context) (limit, offset int, err error) {
+	limit = 10
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			return limit, offset, errors.New("limit must be between 1 and 100")
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return limit, offset, errors.New("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return limit, offset, errors.New("page must be a positive integer")
+		}
+		if c.Query("offset") != "" {
+			return limit, offset, errors.New("use either page or offset, not both")
+		}
+		offset = (n - 1) * limit
+	}
+	return limit, offset, nil
+}
+
+// 解析时间窗口：24h、7d、30d 或 RFC3339 时间戳
+func parseSince(v string, now time.Time) (time.Time, error) {
+	switch v {
+	case "24h":
+		return now.Add(-24 * time.Hour), nil
+	case "7d":
+		return now.AddDate(0, 0, -7), nil
+	case "30d":
+		return now.AddDate(0, 0, -30), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New("since must be 24h, 7d, 30d or an RFC3339 timestamp")
+	}
+	return t, nil
+}
+
+// 缓存key：相对时间窗口按原始参数缓存，避免每次请求的key都不同
+func (q rankQuery) cacheKey() string {
+	return fmt.Sprintf("%q|%d|%d|%s|%s", q.room, q.limit, q.offset, q.window, q.sort)
+}
+
+// 查询排行榜接口
+func (s *GameServer) leaderboard(c *gin.Context) {
+	q, err := parseRankQuery(c, time.Now(), s.rankTZ)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 查询跟随发起请求的上下文，该请求断开时同key的等待者也会拿到错误，下次请求重新查询
+	page, cachedAt, err := s.rankCache.get(q.cacheKey(), func() (rankPage, error) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), s.cfg.DBReadTimeout)
+		defer cancel()
+		return s.store.Leaderboard(ctx, q)
+	})
+	if err != nil {
+		s.dbQueryFailed(c, "leaderboard query", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":      page.Rows,
+		"total":     page.Total,
+		"limit":     q.limit,
+		"offset":    q.offset,
+		"room":      q.room,
+		"filter":    q.filter(),
+		"sort":      q.sort,
+		"period":    q.periodInfo(),
+		"cached_at": cachedAt.Format(time.RFC3339Nano),
+	})
+}
+
+// 响应中注明实际使用的房间过滤方式：exact 单个房间，all 所有房间
+func (q rankQuery) filter() string {
+	if q.room == "" {
+		return "all"
+	}
+	return "exact"
+}
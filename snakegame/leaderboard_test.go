@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// 内存存储的房间过滤
+func TestLeaderboardRoomFilter(t *testing.T) {
+	checkRoomFilter(t, newMemoryStore())
+}
+
+// room参数按原样精确匹配，% 和 _ 不是通配符；不带room时汇总所有房间
+func checkRoomFilter(t *testing.T, store ScoreStore) {
+	t.Helper()
+	s, r := newTestServer(t, store)
+	for _, room := range []string{"a", "ab", "abc", "a%", "a_c", "b"} {
+		s.scores.enqueue(scoreRow{playerID: "p-" + room, room: room, score: 10, startedAt: time.Now()})
+	}
+	s.scores.Close()
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantRooms  []string
+		wantFilter string
+	}{
+		{"room=a", http.StatusOK, []string{"a"}, "exact"},
+		{"room=" + url.QueryEscape("a%"), http.StatusOK, []string{"a%"}, "exact"},
+		{"room=" + url.QueryEscape("%"), http.StatusOK, nil, "exact"},
+		{"room=" + url.QueryEscape("a_"), http.StatusOK, nil, "exact"},
+		{"room=" + url.QueryEscape("a_c"), http.StatusOK, []string{"a_c"}, "exact"},
+		{"room=" + url.QueryEscape("a' OR '1'='1"), http.StatusOK, nil, "exact"},
+		{"", http.StatusOK, []string{"a", "ab", "abc", "a%", "a_c", "b"}, "all"},
+		{"room=", http.StatusBadRequest, nil, ""},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Data   []RankRow `json:"data"`
+			Total  int       `json:"total"`
+			Room   string    `json:"room"`
+			Filter string    `json:"filter"`
+		}
+		getJSON(t, r, "/api/leaderboard?limit=100&"+tt.query, tt.wantStatus, &resp)
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		got := map[string]bool{}
+		for _, row := range resp.Data {
+			got[row.Room] = true
+		}
+		if len(got) != len(tt.wantRooms) || resp.Total != len(tt.wantRooms) {
+			t.Errorf("%q: rooms %v (total %d), want %v", tt.query, got, resp.Total, tt.wantRooms)
+		}
+		for _, room := range tt.wantRooms {
+			if !got[room] {
+				t.Errorf("%q: missing room %q in %v", tt.query, room, got)
+			}
+		}
+		if resp.Filter != tt.wantFilter {
+			t.Errorf("%q: filter = %q, want %q", tt.query, resp.Filter, tt.wantFilter)
+		}
+	}
+}
+
+// 周期按时区的自然日划分，每周从周一开始
+func TestPeriodWindow(t *testing.T) {
+	utc8 := time.FixedZone("UTC+8", 8*3600)
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		period     string
+		now        time.Time
+		loc        *time.Location
+		start, end string
+	}{
+		{PeriodDaily, at("2024-03-06T12:00:00Z"), time.UTC, "2024-03-06T00:00:00Z", "2024-03-07T00:00:00Z"},
+		// UTC 16:00 在UTC+8已是第二天
+		{PeriodDaily, at("2024-03-06T16:00:00Z"), utc8, "2024-03-07T00:00:00+08:00", "2024-03-08T00:00:00+08:00"},
+		{PeriodDaily, at("2024-03-06T15:59:59Z"), utc8, "2024-03-06T00:00:00+08:00", "2024-03-07T00:00:00+08:00"},
+		// 2024-03-06是周三
+		{PeriodWeekly, at("2024-03-06T12:00:00Z"), time.UTC, "2024-03-04T00:00:00Z", "2024-03-11T00:00:00Z"},
+		// 周日属于前一个周一开始的一周
+		{PeriodWeekly, at("2024-03-10T23:59:59Z"), time.UTC, "2024-03-04T00:00:00Z", "2024-03-11T00:00:00Z"},
+		{PeriodWeekly, at("2024-03-10T16:00:00Z"), utc8, "2024-03-11T00:00:00+08:00", "2024-03-18T00:00:00+08:00"},
+	}
+	for _, tt := range tests {
+		start, end := periodWindow(tt.period, tt.now, tt.loc)
+		if start.Format(time.RFC3339) != tt.start || end.Format(time.RFC3339) != tt.end {
+			t.Errorf("periodWindow(%s, %v, %s) = %v, %v, want %s, %s",
+				tt.period, tt.now, tt.loc, start.Format(time.RFC3339), end.Format(time.RFC3339), tt.start, tt.end)
+		}
+	}
+}
+
+// 内存存储的日榜和周榜边界
+func TestLeaderboardPeriods(t *testing.T) {
+	store := newMemoryStore()
+	checkPeriods(t, store, func(room string, at time.Time) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		store.rows = append(store.rows, memScore{playerID: "p-" + room, room: room, score: 10, createdAt: at})
+	})
+}
+
+// 恰好在周期起点的记录计入，早一秒的不计入；响应注明周期的起止时间
+func checkPeriods(t *testing.T, store ScoreStore, insert func(room string, at time.Time)) {
+	t.Helper()
+	s, r := newTestServer(t, store)
+	s.rankTZ = time.FixedZone("UTC+8", 8*3600)
+	now := time.Now()
+	day, _ := periodWindow(PeriodDaily, now, s.rankTZ)
+	week, _ := periodWindow(PeriodWeekly, now, s.rankTZ)
+	// 周一时今天和本周的起点相同
+	rows := map[string]time.Time{
+		"today":     day,
+		"yesterday": day.Add(-time.Second),
+		"this-week": week,
+		"last-week": week.Add(-time.Second),
+	}
+	for room, at := range rows {
+		insert(room, at)
+	}
+
+	tests := []struct {
+		period    string
+		wantStart time.Time // 零值表示不限
+	}{
+		{PeriodDaily, day},
+		{PeriodWeekly, week},
+		{PeriodAllTime, time.Time{}},
+	}
+	for _, tt := range tests {
+		var resp struct {
+			Data   []RankRow `json:"data"`
+			Period struct {
+				Name  string `json:"name"`
+				Start string `json:"start"`
+				End   string `json:"end"`
+			} `json:"period"`
+		}
+		getJSON(t, r, "/api/leaderboard?limit=100&period="+tt.period, http.StatusOK, &resp)
+		got := map[string]bool{}
+		for _, row := range resp.Data {
+			got[row.Room] = true
+		}
+		want := map[string]bool{}
+		for room, at := range rows {
+			if !at.Before(tt.wantStart) {
+				want[room] = true
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("period %s: rooms %v, want %v", tt.period, got, want)
+		}
+		for room := range want {
+			if !got[room] {
+				t.Errorf("period %s: missing %s in %v", tt.period, room, got)
+			}
+		}
+		if resp.Period.Name != tt.period {
+			t.Errorf("period name = %q, want %q", resp.Period.Name, tt.period)
+		}
+		if !tt.wantStart.IsZero() && resp.Period.Start != tt.wantStart.Format(time.RFC3339) {
+			t.Errorf("period %s: start = %s, want %s", tt.period, resp.Period.Start, tt.wantStart.Format(time.RFC3339))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 日志：所有输出都是写到标准错误的JSON行，房间、玩家、远端地址等作为字段，
+// 便于按room或player过滤。连接相关的日志使用Conn.log，已带上这些字段
+
+// 按级别创建JSON日志并设为默认；标准库log和gin的调试输出也经由它写出
+func setupLogging(level slog.Level) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	gin.DebugPrintFunc = func(format string, values ...interface{}) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)), "component", "gin")
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, _ int) {
+		slog.Debug("route", "component", "gin", "method", method, "path", path, "handler", handler)
+	}
+}
+
+// 记录错误后退出，只在启动和监听失败时使用
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// gin访问日志，与其余日志写到同一个流，口令和令牌参数已隐去。
+// WebSocket请求在升级完成、处理函数返回时记录
+func accessLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		if raw := c.Request.URL.RawQuery; raw != "" {
+			path += "?" + raw
+		}
+		c.Next()
+		attrs := []interface{}{
+			"method", c.Request.Method,
+			"path", redactQuery(path),
+			"status", c.Writer.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"remote", c.ClientIP(),
+		}
+		if msg := c.Errors.String(); msg != "" {
+			attrs = append(attrs, "error", msg)
+		}
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		slog.Log(c.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+// 玩家的日志：在线时使用连接的日志，断线或机器人按房间日志加player字段
+func (r *Room) playerLog(s *Snake) *slog.Logger {
+	if s.conn != nil {
+		return s.conn.logger()
+	}
+	return r.log.With("player", s.ID)
+}
@@ -1,106 +1,287 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/websocket"
+
+	"golearn/snakegame/protocol"
 )
 
-// WebSocket升级器，允许所有来源连接
+// WebSocket升级器，来源检查见origin.go，默认允许所有来源；客户端支持时协商permessage-deflate，
+// 是否真正压缩写出由每个连接决定
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin:       originChecker(nil),
+	EnableCompression: true,
 }
 
-// 点结构体，表示坐标
-type Point struct {
-	X int `json:"x"`
-	Y int `json:"y"`
-}
+// 点结构体，表示坐标，与客户端共用的类型见 protocol 包
+type Point = protocol.Point
 
-// Snake结构体，表示一条蛇
+// Snake结构体，表示一条蛇；序列化的字段在 protocol.Player 中
 type Snake struct {
-	ID    string  `json:"id"`    // 玩家ID
-	Body  []Point `json:"body"`  // 蛇身体坐标
-	Dir   string  `json:"dir"`   // 当前方向
-	Score int     `json:"score"` // 得分
-	Alive bool    `json:"alive"` // 是否存活
-
-	conn *websocket.Conn `json:"-"` // WebSocket连接（不序列化）
+	protocol.Player
+
+	conn    *Conn    `json:"-"` // WebSocket连接（不序列化）
+	pending []string `json:"-"` // 待应用的方向变更，每tick消费一个
+
+	httpLimit *rateLimiter // HTTP方向接口的限流，首次调用时创建
+
+	moveAcc    int       // 距上次移动经过的tick数，按速度档位决定何时移动
+	startTick  int64     // 本局出生时的房间tick
+	startedAt  time.Time // 本局出生时间
+	scoreSaved bool      // 本局分数已保存，每条命只写一行
+	respawnIn  int       // 机器人距重生的剩余tick数
+	token      string    // 会话令牌，断线后凭此恢复
+	account    string    // 令牌中的玩家ID，未启用鉴权时为空
+	detached   bool      // 连接已断开，处于保留期
+	detachGen  int       // 每次断开/恢复递增，用于作废旧的保留期定时器
 }
 
 // 房间结构体，管理一局游戏
 type Room struct {
-	name    string
-	width   int
-	height  int
-	players map[string]*Snake // 所有玩家
-	food    Point             // 食物坐标
-	lock    sync.Mutex        // 并发锁
-	db      *sql.DB           // 数据库连接
+	name     string
+	width    int
+	height   int
+	interval time.Duration     // tick间隔
+	players  map[string]*Snake // 所有玩家
+	watchers map[*Conn]bool    // 观战连接
+	foods    []Food            // 食物
+	lock     sync.Mutex        // 并发锁
+	colors   colorAllocator    // 蛇颜色分配
+	scores   *scoreWriter      // 异步分数写入器
+	replays  *replayStore      // 录像存储
+	rec      *recorder         // 录像，未开启录制时为nil
+
+	foodWeights FoodWeights    // 各种食物的生成权重
+	boardFull   bool           // 上次补充食物时棋盘已满
+	mapName     string         // 地图名称
+	wrap        bool           // 环形地图，出界从对边进入
+	obstacles   []Point        // 障碍物坐标
+	obstacleSet map[Point]bool // 障碍物查找表
+	maxPlay     int            // 玩家人数上限（不含观战者）
+	mode        string         // 房间模式：endless 或 match
+	match       *matchState    // 回合制状态，无尽模式为nil
+	speedTiers  []int          // 加速分数线，为空表示所有蛇每tick移动
+	seed        int64          // 随机数种子，欢迎消息中下发，用于复现对局
+	rng         *rand.Rand     // 房间内所有随机选择都使用它，只在持有房间锁时使用
+	powerups    []PowerUp      // 棋盘上的道具
+	powerCfg    PowerUpConfig  // 道具生成间隔和效果时长
+	hazards     []Hazard       // 棋盘上的危险格
+	poisonEvery int            // 毒格平均生成间隔（tick），0表示不生成
+	suddenDeath int            // 回合制房间进入决胜阶段的tick数，0表示不收缩
+	passHash    []byte         // 口令的SHA-256，公开房间为nil，创建后不变
+	log         *slog.Logger   // 带room字段的日志
 
 	onceLoop sync.Once     // 保证runLoop只启动一次
 	stopCh   chan struct{} // 停止信号
+	wake     chan struct{} // 唤醒休眠的循环
+	dormant  bool          // 没有存活的蛇，循环以 dormantInterval 运行
+	closed   bool          // 房间已关闭，不再接受加入
+	nextID   int           // 玩家ID计数器，ID不复用
+
+	playerCount    int           // 上次通知的真人玩家数，见 syncCounts
+	spectatorCount int           // 上次通知的观战人数
+	createdAt      time.Time     // 创建时间
+	idleTTL        time.Duration // 空闲多久后关闭，0表示不过期，见expiry.go
+	lastActive     time.Time     // 最近一次游戏活动的时间
+	expiryWarned   bool          // 本轮空闲已广播过room_expiring
+	onExpire       func(*Room)   // 空闲过期时由runLoop调用
+
+	tick        int64        // 已执行的tick数
+	scoresSaved int          // 本房间已提交写入的分数条数
+	sent        sentFrame    // 上次广播的状态（增量协议基准）
+	dirty       bool         // 上次广播后状态有变化，见 shouldBroadcast
+	lastFrame   time.Time    // 上次广播状态帧的时间
+	frames      *frameStats  // 状态帧统计，所有房间共用
+	counters    *serverStats // 进程级统计，所有房间共用
 }
 
+// 游戏循环间隔
+const tickInterval = 200 * time.Millisecond
+
+// 房间已满时的关闭码
+const closeRoomFull = 4001
+
 // 游戏服务器结构体，管理所有房间
 type GameServer struct {
 	rooms map[string]*Room
 	lock  sync.Mutex
-	db    *sql.DB
+	store ScoreStore
+	cfg   Config
+
+	scores    *scoreWriter   // 异步分数写入器
+	rankCache *rankCache     // 排行榜缓存
+	rankTZ    *time.Location // 日榜、周榜按该时区划分
+	replays   *replayStore   // 录像存储
+
+	compression compressionMetrics // 压缩统计
+	frames      frameStats         // 状态帧广播和跳过的次数
+	db          dbStats            // 数据库操作失败和超时的次数
+	counters    *serverStats       // tick数和启动时间，供 /api/stats 使用
+	cluster     *cluster           // 多实例协调，未配置 REDIS_ADDR 时为nil
+	authSecret  []byte             // JWT签名密钥，未配置 AUTH_SECRET 时为nil，不校验身份
 }
 
 // 创建新游戏服务器
-func NewGameServer(db *sql.DB) *GameServer {
-	return &GameServer{
-		rooms: make(map[string]*Room),
-		db:    db,
+func NewGameServer(store ScoreStore, cfg Config) *GameServer {
+	s := &GameServer{
+		rooms:     make(map[string]*Room),
+		store:     store,
+		cfg:       cfg,
+		rankCache: newRankCache(cfg.LeaderboardCacheTTL),
+		rankTZ:    cfg.rankLocation(),
+		replays:   newReplayStore(cfg.ReplayDir),
+		counters:  newServerStats(),
 	}
+	s.scores = newScoreWriter(store, cfg.DBWriteTimeout, &s.db)
+	return s
 }
 
-// 获取房间，不存在则新建并启动循环
-func (s *GameServer) getRoom(name string) *Room {
+// 获取房间，不存在则按opts新建并启动循环；已存在的房间忽略opts
+func (s *GameServer) getRoom(name string, opts RoomOptions) *Room {
 	s.lock.Lock()
-	defer s.lock.Unlock()
-
 	room, exists := s.rooms[name]
 	if !exists {
-		room = &Room{
-			name:    name,
-			width:   20,
-			height:  20,
-			players: make(map[string]*Snake),
-			food:    Point{X: rand.Intn(20), Y: rand.Intn(20)},
-			db:      s.db,
-			stopCh:  make(chan struct{}),
-		}
+		room = s.newRoom(name, opts)
 		s.rooms[name] = room
 		// 只启动一次循环
 		room.onceLoop.Do(func() {
 			go room.runLoop()
 		})
 	}
+	s.lock.Unlock()
+	// 订阅要访问Redis，放到锁外
+	if !exists && s.cluster != nil {
+		s.cluster.own(name)
+	}
+	return room
+}
+
+// 按opts创建房间，不启动循环也不加入服务器
+func (s *GameServer) newRoom(name string, opts RoomOptions) *Room {
+	room := &Room{
+		name:     name,
+		width:    opts.Width,
+		height:   opts.Height,
+		interval: opts.Interval,
+		players:  make(map[string]*Snake),
+		watchers: make(map[*Conn]bool),
+		scores:   s.scores,
+		replays:  s.replays,
+		frames:   &s.frames,
+		counters: s.counters,
+		stopCh:   make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+
+		foodWeights: opts.FoodWeights,
+		mapName:     opts.Map,
+		wrap:        opts.Wrap,
+		maxPlay:     opts.MaxPlayers,
+		mode:        opts.Mode,
+		speedTiers:  opts.SpeedTiers,
+		seed:        opts.Seed,
+		rng:         rand.New(rand.NewSource(opts.Seed)),
+		powerCfg:    opts.PowerUps,
+		poisonEvery: opts.PoisonEvery,
+		suddenDeath: opts.SuddenDeath,
+		passHash:    opts.PassHash,
+		log:         slog.With("room", name),
+		createdAt:   time.Now(),
+		lastActive:  time.Now(),
+		idleTTL:     opts.IdleTTL,
+		obstacles:   buildObstacles(opts.Map, opts.Width, opts.Height),
+		obstacleSet: make(map[Point]bool),
+	}
+	for _, p := range room.obstacles {
+		room.obstacleSet[p] = true
+	}
+	if opts.Mode == ModeMatch {
+		room.match = &matchState{}
+	}
+	for i := 0; i < opts.Bots; i++ {
+		room.addBot()
+	}
+	if opts.Record {
+		room.startRecording()
+	}
+	room.onExpire = s.expireRoom
+	room.refillFood()
 	return room
 }
 
-// 房间主循环，定时更新游戏状态
+// 房间没有玩家和观战者时停止循环并从服务器移除；之后同名加入会创建新房间
+func (s *GameServer) closeRoomIfEmpty(room *Room) {
+	if s.removeIfEmpty(room) && s.cluster != nil {
+		// 释放归属要访问Redis，放到锁外
+		s.cluster.release(room.name)
+	}
+}
+
+// 在锁内关闭空房间，从服务器移除时返回true
+func (s *GameServer) removeIfEmpty(room *Room) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	room.lock.Lock()
+	defer room.lock.Unlock()
+
+	// 只剩机器人的房间同样关闭
+	if room.closed || room.humanCount() > 0 || len(room.watchers) > 0 {
+		return false
+	}
+	room.closed = true
+	close(room.stopCh)
+	room.replays.save(room.finishRecording())
+	room.scores.flush()
+	if s.rooms[room.name] != room {
+		return false
+	}
+	delete(s.rooms, room.name)
+	return true
+}
+
+// 房间主循环，定时更新游戏状态；没有存活的蛇时降低频率休眠，
+// 有玩家加入或发送指令时唤醒。每idleCheckInterval检查一次空闲过期
 func (r *Room) runLoop() {
-	ticker := time.NewTicker(200 * time.Millisecond)
+	ticker := time.NewTicker(r.interval)
 	defer ticker.Stop()
+	idle := time.NewTicker(idleCheckInterval)
+	defer idle.Stop()
 	for {
 		select {
 		case <-ticker.C:
 			r.update()
+			if d := r.nextInterval(); d > 0 {
+				ticker.Reset(d)
+			}
+		case <-r.wake:
+			r.lock.Lock()
+			wasDormant := r.dormant
+			r.dormant = false
+			r.lock.Unlock()
+			if wasDormant {
+				ticker.Reset(r.interval)
+			}
+		case now := <-idle.C:
+			if r.checkExpiry(now) {
+				r.onExpire(r)
+				return
+			}
 		case <-r.stopCh:
 			return
 		}
@@ -112,279 +293,459 @@ func (r *Room) update() {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
-	for _, snake := range r.players {
-		if !snake.Alive || len(snake.Body) == 0 {
-			continue
-		}
-
-		head := snake.Body[0]
-		next := head
-		// 根据方向计算下一个点
-		switch snake.Dir {
-		case "up":
-			next.Y--
-		case "down":
-			next.Y++
-		case "left":
-			next.X--
-		case "right":
-			next.X++
-		}
-
-		// 撞墙判定
-		if next.X < 0 || next.X >= r.width || next.Y < 0 || next.Y >= r.height {
-			if snake.Alive {
-				snake.Alive = false
-				r.saveScore(snake.ID, snake.Score)
-			}
-			continue
-		}
-
-		var newBody []Point
-
-		// 撞自己判定
-		selfHit := false
-		for _, b := range snake.Body {
-			if next == b {
-				selfHit = true
-				break
-			}
-		}
-		if selfHit {
-			if snake.Alive {
-				snake.Alive = false
-				r.saveScore(snake.ID, snake.Score)
-			}
-			continue
-		}
-
-		// 撞其他玩家判定
-		otherHit := false
-		for _, other := range r.players {
-			if other.ID == snake.ID {
-				continue
-			}
-			for _, b := range other.Body {
-				if next == b {
-					otherHit = true
-					break
-				}
-			}
-			if otherHit {
-				break
-			}
+	r.expireFood()
+	r.tickPowerUps()
+	r.tickHazards()
+	r.tickSpawns()
+	r.driveBots()
+	// 回合制房间在开局前和倒计时期间蛇不移动
+	if r.match == nil || r.matchTick() {
+		if r.match != nil {
+			r.tickStorm()
 		}
-		if otherHit {
-			if snake.Alive {
-				snake.Alive = false
-				r.saveScore(snake.ID, snake.Score)
-			}
-			continue
+		moves := r.planMoves()
+		r.resolveCollisions(moves)
+		r.tickEffects()
+		r.applyMoves(moves)
+		if r.match != nil {
+			r.checkRoundOver()
 		}
+	}
+	r.refillFood()
 
-		// 正常前进
-		newBody = append([]Point{next}, snake.Body[:len(snake.Body)-1]...)
-		snake.Body = newBody
+	// 广播当前状态给所有玩家
+	r.tick++
+	r.counters.ticks.Add(1)
+	now := time.Now()
+	r.probeLatency(now)
+	if r.shouldBroadcast(now) {
+		r.broadcastState()
+	}
+	r.recordTick()
+	r.evictFailed()
+}
 
-		// 吃食物判定
-		if next == r.food {
-			snake.Score++
-			tail := snake.Body[len(snake.Body)-1]
-			snake.Body = append(snake.Body, tail)
-			r.food = r.randomEmptyCell()
-		}
+// 构建完整状态消息，调用方需持有房间锁
+func (r *Room) stateMessage() *protocol.State {
+	msg := &protocol.State{
+		Type:       protocol.TypeState,
+		Tick:       r.tick,
+		Players:    r.snapshotPlayers(),
+		Foods:      append([]Food(nil), r.foods...),
+		Food:       r.firstFood(),
+		PowerUps:   r.powerUpList(),
+		Hazards:    r.hazardList(),
+		Bounds:     r.bounds(),
+		Room:       r.name,
+		W:          r.width,
+		H:          r.height,
+		TickMS:     r.interval.Milliseconds(),
+		Spectators: len(r.watchers),
+		Collision:  collisionRule,
+
+		PlayerCount:    r.playerCount,
+		SpectatorCount: r.spectatorCount,
+		Mode:           r.mode,
 	}
+	if r.match != nil {
+		msg.Match = r.matchInfo()
+	}
+	return msg
+}
 
-	// 广播当前状态给所有玩家
-	state := map[string]interface{}{
-		"type":    "state",
-		"players": r.snapshotPlayers(),
-		"food":    r.food,
-		"room":    r.name,
-		"w":       r.width,
-		"h":       r.height,
-	}
-	data, _ := json.Marshal(state)
+// 房间内所有连接（玩家和观战者），调用方需持有房间锁
+func (r *Room) conns() []*Conn {
+	out := make([]*Conn, 0, len(r.players)+len(r.watchers))
 	for _, s := range r.players {
 		if s.conn != nil {
-			_ = s.conn.WriteMessage(websocket.TextMessage, data)
+			out = append(out, s.conn)
 		}
 	}
+	for c := range r.watchers {
+		out = append(out, c)
+	}
+	return out
+}
+
+// 向房间内所有连接广播，只入队不阻塞，调用方需持有房间锁
+func (r *Room) broadcastLocked(data []byte) {
+	for _, c := range r.conns() {
+		c.sendText(data)
+	}
 }
 
-// 复制所有玩家状态（用于广播）
-func (r *Room) snapshotPlayers() map[string]*Snake {
-	out := make(map[string]*Snake, len(r.players))
+// 复制所有玩家状态（用于广播），调用方需持有房间锁
+func (r *Room) snapshotPlayers() map[string]*protocol.Player {
+	out := make(map[string]*protocol.Player, len(r.players))
 	for id, s := range r.players {
-		cp := &Snake{
-			ID:    s.ID,
-			Body:  append([]Point(nil), s.Body...),
-			Dir:   s.Dir,
-			Score: s.Score,
-			Alive: s.Alive,
-		}
-		out[id] = cp
+		cp := s.Player
+		cp.Body = append([]Point(nil), s.Body...)
+		cp.Speed = r.speedTier(s.Score)
+		cp.LatencyMS = s.conn.latencyMS()
+		out[id] = &cp
 	}
 	return out
 }
 
-// 随机生成一个未被占用的点作为食物
-func (r *Room) randomEmptyCell() Point {
+// 在当前可活动范围内随机选一个未被蛇、障碍物和食物占用的格子。先随机尝试，失败后扫描全部格子
+// 从空格中均匀选取；棋盘已满时ok为false，调用方需持有房间锁
+func (r *Room) randomEmptyCell() (p Point, ok bool) {
+	b := r.bounds()
 	for i := 0; i < 200; i++ {
-		p := Point{X: rand.Intn(r.width), Y: rand.Intn(r.height)}
-		occupied := false
-		for _, s := range r.players {
-			for _, b := range s.Body {
-				if p == b {
-					occupied = true
-					break
-				}
-			}
-			if occupied {
-				break
-			}
+		p = Point{X: b.Left + r.rng.Intn(b.Right-b.Left+1), Y: b.Top + r.rng.Intn(b.Bottom-b.Top+1)}
+		if !r.occupied(p) {
+			return p, true
 		}
-		if !occupied {
-			return p
+	}
+	var free []Point
+	for y := b.Top; y <= b.Bottom; y++ {
+		for x := b.Left; x <= b.Right; x++ {
+			if c := (Point{X: x, Y: y}); !r.occupied(c) {
+				free = append(free, c)
+			}
 		}
 	}
-	return r.food
+	if len(free) == 0 {
+		return Point{}, false
+	}
+	return free[r.rng.Intn(len(free))], true
 }
 
-// 保存玩家得分到数据库
-func (r *Room) saveScore(playerID string, score int) {
-	_, err := r.db.Exec("INSERT INTO snake_score (player_id, room, score) VALUES (?, ?, ?)",
-		playerID, r.name, score)
-	if err != nil {
-		log.Println("DB insert error:", err)
+// 一局结束时保存分数和本局记录：只入队，由异步写入器落库，player_id列保存玩家昵称；
+// 机器人不入榜。同一条命只保存一次，死亡、断线和关闭房间谁先到算谁，
+// 重生时startSpawn清除标记，调用方需持有房间锁
+func (r *Room) saveScore(snake *Snake, cause string) {
+	r.queueScore(snake, cause, nil, nil)
+}
+
+// 写入排行榜的玩家ID：启用鉴权时为令牌的sub，否则为昵称
+func (s *Snake) scoreID() string {
+	if s.account != "" {
+		return s.account
+	}
+	return s.Name
+}
+
+// 与saveScore相同，killer为撞死该蛇的对手，onBest见scoreRow；返回是否已入队（入队后onBest一定会被调用）
+func (r *Room) queueScore(snake *Snake, cause string, killer *Snake, onBest func(prev int, found bool, err error)) bool {
+	if snake.scoreSaved {
+		return false
+	}
+	snake.scoreSaved = true
+	if snake.Bot {
+		return false
+	}
+	row := scoreRow{
+		playerID:  snake.scoreID(),
+		room:      r.name,
+		score:     snake.Score,
+		maxLen:    snake.MaxLen,
+		ticks:     r.tick - snake.startTick,
+		cause:     cause,
+		startedAt: snake.startedAt,
+		onBest:    onBest,
 	}
+	if killer != nil {
+		row.killer = killer.scoreID()
+	}
+	if r.scores.enqueue(row) {
+		r.scoresSaved++
+		return true
+	}
+	return false
 }
 
 // 处理WebSocket连接，玩家加入房间
 func (s *GameServer) handleWS(c *gin.Context) {
 	roomName := c.Param("room")
-	room := s.getRoom(roomName)
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		log.Println("Upgrade error:", err)
+	// 启用鉴权时必须携带有效的 ?token=，在升级前拒绝
+	if _, err := s.authenticate(c.Request.URL.Query()); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	room.lock.Lock()
-	playerID := fmt.Sprintf("P%d", len(room.players)+1)
-	snake := &Snake{
-		ID:    playerID,
-		Body:  []Point{{X: rand.Intn(room.width), Y: rand.Intn(room.height)}},
-		Dir:   "right",
-		Score: 0,
-		Alive: true,
-		conn:  conn,
-	}
-	room.players[playerID] = snake
-	room.lock.Unlock()
+	// ?compress=1 且客户端支持扩展时压缩发送
+	compress := c.Query("compress") == "1" && offersDeflate(c.Request)
+	stats := &s.compression.plain
+	if compress {
+		stats = &s.compression.compressed
+	}
+	logger := slog.With("room", roomName, "remote", c.ClientIP())
+	ws, err := upgrader.Upgrade(countingWriter{ResponseWriter: c.Writer, n: &stats.wire}, c.Request, nil)
+	if err != nil {
+		logger.Warn("websocket upgrade failed", "err", err)
+		return
+	}
+	// 每个连接只设置一次，之后所有写入沿用
+	ws.EnableWriteCompression(compress)
+	stats.conns.Add(1)
+	q := c.Request.URL.Query()
+
+	// 多实例模式下房间由其他实例运行时，只转发消息；无法确认归属时让客户端稍后重连
+	if s.cluster != nil {
+		local, err := s.cluster.claim(roomName)
+		if err != nil {
+			logger.Warn("claim room failed", "err", err)
+			conn := newConn(ws, s.cfg.Tick)
+			conn.stats = stats
+			conn.closeWith(websocket.CloseTryAgainLater, "cluster unavailable")
+			return
+		}
+		if !local {
+			conn := newConn(ws, s.cfg.Tick)
+			conn.stats = stats
+			conn.setLogger(logger.With("relayed", true))
+			go s.cluster.relay(roomName, q, conn)
+			return
+		}
+	}
 
-	// 发送欢迎信息
-	welcome := map[string]interface{}{
-		"type":    "welcome",
-		"player":  playerID,
-		"room":    room.name,
-		"w":       room.width,
-		"h":       room.height,
-		"food":    room.food,
-		"players": room.snapshotPlayers(),
+	room, snake, conn := s.join(roomName, q, func(stall time.Duration) *Conn {
+		conn := newConn(ws, stall)
+		conn.stats = stats
+		conn.setLogger(logger)
+		return conn
+	})
+	if room == nil {
+		return
+	}
+	if snake == nil {
+		go s.spectate(room, conn)
+		return
 	}
-	_ = conn.WriteJSON(welcome)
 
 	// 监听玩家消息
-	go func() {
-		defer func() {
-			room.lock.Lock()
-			if snake.Alive {
-				room.saveScore(snake.ID, snake.Score)
-			}
-			delete(room.players, playerID)
-			room.lock.Unlock()
-			_ = conn.Close()
-
-			// 广播玩家离开
-			msg := map[string]string{"type": "leave", "player": playerID}
-			data, _ := json.Marshal(msg)
-			room.lock.Lock()
-			for _, s := range room.players {
-				if s.conn != nil {
-					_ = s.conn.WriteMessage(websocket.TextMessage, data)
-				}
-			}
-			room.lock.Unlock()
-		}()
+	go s.play(room, snake, conn)
+}
 
-		for {
-			mt, msg, err := conn.ReadMessage()
-			if err != nil {
-				return
-			}
-			if mt != websocket.TextMessage {
-				continue
-			}
-			cmd := string(msg)
-			switch cmd {
-			case "up", "down", "left", "right":
-				// 方向变更，不能反向
-				room.lock.Lock()
-				if (snake.Dir == "up" && cmd != "down") ||
-					(snake.Dir == "down" && cmd != "up") ||
-					(snake.Dir == "left" && cmd != "right") ||
-					(snake.Dir == "right" && cmd != "left") {
-					snake.Dir = cmd
-				}
-				room.lock.Unlock()
-			case "ping":
-				_ = conn.WriteMessage(websocket.TextMessage, []byte("pong"))
+// 按URL参数加入房间并发送欢迎信息，open按房间tick间隔创建连接。
+// 返回的snake为nil表示观战；房间已满或口令错误时返回的room为nil，连接已被关闭
+func (s *GameServer) join(roomName string, q url.Values, open func(stall time.Duration) *Conn) (*Room, *Snake, *Conn) {
+	opts := parseRoomOptions(q, s.cfg.roomDefaults())
+
+	// 取到的房间若恰好在关闭，重新获取一个新房间
+	var room *Room
+	for {
+		room = s.getRoom(roomName, opts)
+		room.lock.Lock()
+		if !room.closed {
+			break
+		}
+		room.lock.Unlock()
+	}
+
+	conn := open(room.interval)
+	// 私人房间：玩家和观战者都要提供创建者设置的口令
+	if !room.checkPass(q.Get("pass")) {
+		room.lock.Unlock()
+		conn.logger().Info("join rejected", "reason", "wrong_pass")
+		conn.sendJSON(wrongPassMsg())
+		conn.closeWith(closeWrongPass, "wrong passcode")
+		return nil, nil, conn
+	}
+	// 客户端可通过 ?enc=bin 选择二进制状态帧，或 ?proto=delta 选择JSON增量协议
+	if q.Get("enc") == "bin" {
+		conn.binary = true
+	} else if q.Get("proto") == "delta" {
+		conn.delta = true
+		conn.needKeyframe = true
+	}
+
+	// ?view=N 只接收蛇头周围N格内的状态，适合大棋盘；优先于 ?proto 和 ?enc，观战者忽略
+	if v, err := strconv.Atoi(q.Get("view")); err == nil && v > 0 {
+		conn.view = clampInt(v, minViewRadius, maxViewRadius)
+	}
+
+	// ?mode=spectator 只观战，不创建蛇
+	spectator := q.Get("mode") == "spectator"
+	var playerID string
+	var snake *Snake
+	resumed := false
+	if spectator {
+		room.watchers[conn] = true
+	} else if snake = room.resumeSnake(q.Get("resume"), conn); snake != nil {
+		// ?resume=<token> 在保留期内接管原来的蛇
+		playerID = snake.ID
+		resumed = true
+	} else if room.humanCount() >= room.maxPlay {
+		// 房间已满：完成升级后返回结构化错误并关闭
+		full := map[string]interface{}{
+			"type":        "error",
+			"code":        "room_full",
+			"players":     room.humanCount(),
+			"max_players": room.maxPlay,
+		}
+		room.lock.Unlock()
+		conn.logger().Info("join rejected", "reason", "room_full")
+		conn.sendJSON(full)
+		conn.closeWith(closeRoomFull, "room full")
+		return nil, nil, conn
+	} else {
+		room.nextID++
+		playerID = fmt.Sprintf("P%d", room.nextID)
+		name := sanitizeName(q.Get("name"))
+		// 启用鉴权时昵称取自令牌，不能自选；令牌已在升级前校验
+		claims, _ := s.authenticate(q)
+		if claims.Sub != "" {
+			name = sanitizeName(claims.Name)
+			if name == "" {
+				name = sanitizeName(claims.Sub)
 			}
 		}
-	}()
+		if name == "" {
+			name = playerID
+		}
+		body, dir := room.spawnPlacement()
+		snake = &Snake{
+			Player: protocol.Player{
+				ID:    playerID,
+				Name:  room.uniqueName(name),
+				Body:  body,
+				Dir:   dir,
+				Score: 0,
+				Alive: len(body) > 0, // 棋盘已满时无处出生
+				Color: room.colors.alloc(),
+			},
+			conn:  conn,
+			token: newToken(),
+
+			account: claims.Sub,
+		}
+		if room.match != nil && room.match.active {
+			// 回合进行中加入的玩家先观战，下一回合开始时出生
+			snake.Body = nil
+			snake.Alive = false
+			snake.Waiting = true
+		} else {
+			room.startSpawn(snake)
+		}
+		// 先广播再加入，新玩家自己不会收到
+		room.emitJoin(snake)
+		room.players[playerID] = snake
+	}
+	// 新连接需要尽快收到状态帧，观战人数也变了
+	room.dirty = true
+	// 玩家加入（含断线恢复）算作游戏活动，观战者不算
+	if snake != nil {
+		room.touch()
+	}
+	// 欢迎信息在锁内生成并入队，保证先于之后的状态帧到达
+	conn.sendJSON(room.welcomeMessage(conn, snake, playerID, resumed))
+	room.syncCounts()
+	room.lock.Unlock()
+	if snake != nil {
+		conn.setLogger(conn.logger().With("player", playerID))
+		conn.logger().Info("player joined", "name", snake.Name, "account", snake.account, "resumed", resumed)
+	} else {
+		conn.logger().Info("spectator joined")
+	}
+	room.wakeUp()
+	return room, snake, conn
 }
 
-// 排行榜结构体
-type RankRow struct {
-	PlayerID string `json:"player_id"`
-	Room     string `json:"room"`
-	Best     int    `json:"best_score"`
-	Games    int    `json:"games"`
-	Last     string `json:"last_play"`
+// 欢迎信息，snake为nil表示观战，调用方需持有房间锁
+func (r *Room) welcomeMessage(conn *Conn, snake *Snake, playerID string, resumed bool) *protocol.Welcome {
+	return &protocol.Welcome{
+		Type:       protocol.TypeWelcome,
+		Player:     playerID,
+		Name:       snakeName(snake),
+		Token:      snakeToken(snake),
+		Resumed:    resumed,
+		Room:       r.name,
+		W:          r.width,
+		H:          r.height,
+		TickMS:     r.interval.Milliseconds(),
+		Foods:      append([]Food(nil), r.foods...),
+		Food:       r.firstFood(),
+		PowerUps:   r.powerUpList(),
+		Hazards:    r.hazardList(),
+		Bounds:     r.bounds(),
+		Players:    r.snapshotPlayers(),
+		Spectator:  snake == nil,
+		MaxPlayers: r.maxPlay,
+		Map:        r.mapName,
+		Wrap:       r.wrap,
+		Obstacles:  r.obstacles,
+		Mode:       r.mode,
+		SpeedTiers: r.speedTiers,
+		Replay:     r.replayID(),
+		Seed:       r.seed,
+		Binary:     conn.binary,
+	}
 }
 
-// 查询排行榜接口
-func (s *GameServer) leaderboard(c *gin.Context) {
-	limitStr := c.DefaultQuery("limit", "10")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 || limit > 100 {
-		limit = 10
-	}
-	room := c.DefaultQuery("room", "%")
-
-	rows, err := s.db.Query(`
-		SELECT player_id, room, MAX(score) AS best_score, COUNT(*) AS games, MAX(created_at) AS last_play
-		FROM snake_score
-		WHERE room LIKE ?
-		GROUP BY player_id, room
-		ORDER BY best_score DESC, last_play DESC
-		LIMIT ?`, room, limit)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query error"})
-		return
-	}
-	defer rows.Close()
+// 玩家连接的读循环，断开后进入保留期
+func (s *GameServer) play(room *Room, snake *Snake, conn *Conn) {
+	defer s.detach(room, snake, conn)
+	s.readMessages(room, snake, conn)
+}
 
-	var out []RankRow
-	for rows.Next() {
-		var r RankRow
-		if err := rows.Scan(&r.PlayerID, &r.Room, &r.Best, &r.Games, &r.Last); err == nil {
-			out = append(out, r)
+// 读取并处理客户端消息直到连接断开；超出速率限制的消息被丢弃，
+// 持续超限的连接被关闭。snake为nil表示观战连接
+func (s *GameServer) readMessages(room *Room, snake *Snake, conn *Conn) {
+	readLimited(conn, func(msg []byte) {
+		room.handleMessage(conn, snake, msg)
+	})
+}
+
+// 读取文本消息并限流后交给handle，直到连接断开
+func readLimited(conn *Conn, handle func(msg []byte)) {
+	for {
+		mt, msg, err := conn.ws.ReadMessage()
+		if err != nil {
+			return
 		}
+		if mt != websocket.TextMessage {
+			continue
+		}
+		switch conn.checkInbound(time.Now()) {
+		case inboundDrop:
+			continue
+		case inboundWarn:
+			conn.sendJSON(errorReply("rate_limited", "too many messages, limit is %d/s", inboundRate))
+			continue
+		case inboundClose:
+			conn.logger().Warn("closing connection for exceeding the message rate limit")
+			conn.closeWith(websocket.ClosePolicyViolation, "rate limit exceeded")
+			return
+		}
+		handle(msg)
+	}
+}
+
+// 欢迎消息中的有效昵称，观战者为空
+func snakeName(s *Snake) string {
+	if s == nil {
+		return ""
+	}
+	return s.Name
+}
+
+// 欢迎消息中的会话令牌，观战者为空
+func snakeToken(s *Snake) string {
+	if s == nil {
+		return ""
 	}
-	c.JSON(http.StatusOK, gin.H{"data": out})
+	return s.token
+}
+
+// 观战连接的读循环：忽略方向指令，断开时不保存分数也不广播离开
+func (s *GameServer) spectate(room *Room, conn *Conn) {
+	defer s.unwatch(room, conn)
+	s.readMessages(room, nil, conn)
+}
+
+// 移除观战连接，房间空了则销毁
+func (s *GameServer) unwatch(room *Room, conn *Conn) {
+	room.lock.Lock()
+	delete(room.watchers, conn)
+	room.dirty = true
+	room.syncCounts()
+	room.lock.Unlock()
+	conn.Close()
+	conn.logger().Info("spectator left")
+	s.closeRoomIfEmpty(room)
 }
 
 // 健康检查接口
@@ -394,38 +755,130 @@ func (s *GameServer) health(c *gin.Context) {
 
 // 程序入口
 func main() {
-	rand.Seed(time.Now().UnixNano())
-
-	dsn := os.Getenv("DB_DSN")
-	if dsn == "" {
-		dsn = "root:123456@tcp(127.0.0.1:3306)/snake_game?parseTime=true"
+	cfg, err := loadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fatal("config error", "err", err)
 	}
+	setupLogging(cfg.LogLevel)
+	slog.Info("config", "config", cfg.String())
 
-	db, err := sql.Open("mysql", dsn)
+	store, err := openStore(cfg.DSN)
 	if err != nil {
-		log.Fatalf("open db error: %v", err)
+		fatal("open store error", "err", err)
 	}
-	defer db.Close()
+	defer store.Close()
 
-	if err := db.Ping(); err != nil {
-		log.Fatalf("db ping error: %v", err)
+	server := NewGameServer(store, cfg)
+
+	// 多实例部署时通过Redis协调房间归属
+	if cfg.RedisAddr != "" {
+		cl, err := newCluster(server, cfg.RedisAddr)
+		if err != nil {
+			fatal("redis error", "err", err)
+		}
+		server.cluster = cl
+		slog.Info("cluster mode", "instance", cl.id, "redis", cfg.RedisAddr)
 	}
 
-	server := NewGameServer(db)
+	// JWT鉴权：设置后WebSocket连接必须携带 ?token=<jwt>
+	if cfg.AuthSecret != "" {
+		server.authSecret = []byte(cfg.AuthSecret)
+		slog.Info("auth enabled: websocket connections require a signed token")
+	}
 
-	r := gin.Default()
-	r.GET("/ws/:room", server.handleWS)           // WebSocket游戏接口
-	r.GET("/api/leaderboard", server.leaderboard) // 排行榜接口
-	r.GET("/health", server.health)               // 健康检查
-	r.StaticFile("/", "./client.html")            // 前端页面
+	// 限制WebSocket来源，不符合的返回403
+	if cfg.AllowedOrigins != "" {
+		rules := parseOrigins(cfg.AllowedOrigins)
+		upgrader.CheckOrigin = originChecker(rules)
+		slog.Info("websocket origins restricted", "rules", len(rules))
+	} else {
+		slog.Warn("allowed-origins not set, accepting websocket connections from any origin")
+	}
+
+	// 不用gin.Default：访问日志写到slog，并隐去私人房间的口令
+	r := gin.New()
+	r.Use(accessLogger(), gin.Recovery())
+	r.Use(jwtAuth(server.authSecret))
+	r.GET("/ws/:room", server.handleWS)                        // WebSocket游戏接口
+	r.GET("/api/leaderboard", server.leaderboard)              // 排行榜接口
+	r.GET("/api/rooms", server.listRooms)                      // 房间列表
+	r.GET("/api/rooms/:room", server.roomStats)                // 单个房间实时状态
+	r.GET("/api/rooms/:room/stream", server.roomStream)        // SSE观战流
+	r.POST("/api/rooms/:room/players/:id/dir", server.postDir) // 不用WebSocket提交方向
+	r.GET("/api/player/:id", server.playerStats)               // 玩家统计
+	r.GET("/api/sessions", server.sessions)                    // 历史对局
+	r.GET("/api/replay/:id", server.replayFile)                // 下载录像
+	r.GET("/ws/replay/:id", server.replayWS)                   // 回放录像
+	r.GET("/api/metrics", server.metrics)                      // 压缩和房间统计
+	r.GET("/api/stats", server.stats)                          // 进程级汇总统计
+	r.GET("/api/stats/deaths", server.deathStats)              // 按死亡原因统计
+	r.GET("/health", server.health)                            // 健康检查
+	r.StaticFile("/", cfg.Static)                              // 前端页面
+
+	// 管理接口，请求头 X-Admin-Token 必须与 -admin-token 一致，或携带admin声明的令牌
+	if cfg.AdminToken == "" && server.authSecret == nil {
+		slog.Warn("admin-token not set, admin API disabled")
+	}
+	admin := r.Group("/admin", adminAuth(cfg.AdminToken))
+	admin.DELETE("/rooms/:room/players/:id", server.kickPlayer) // 踢出玩家
+	admin.DELETE("/rooms/:room", server.closeRoom)              // 关闭房间
 
 	r.NoRoute(func(c *gin.Context) {
-		c.File("./client.html")
+		c.File(cfg.Static)
 	})
 
-	addr := ":8080"
-	log.Printf("Snake game server running at %s", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatal(err)
+	// 请求的context在开始关闭时取消，SSE流等长连接请求随之结束，不会拖住srv.Shutdown
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	srv := &http.Server{
+		Addr:        cfg.Addr,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	srv.RegisterOnShutdown(cancelBase)
+	go func() {
+		var err error
+		if cfg.TLS() {
+			slog.Info("snake game server running", "addr", cfg.Addr, "scheme", "https")
+			err = srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			slog.Info("snake game server running", "addr", cfg.Addr, "scheme", "http")
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("listen error", "addr", cfg.Addr, "err", err)
+		}
+	}()
+	// -redirect-http 把明文HTTP请求重定向到HTTPS端口
+	var redirect *http.Server
+	if cfg.RedirectHTTP != "" {
+		redirect = &http.Server{Addr: cfg.RedirectHTTP, Handler: httpsRedirect(cfg.Addr)}
+		go func() {
+			slog.Info("redirecting http to https", "addr", cfg.RedirectHTTP)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("listen error", "addr", cfg.RedirectHTTP, "err", err)
+			}
+		}()
+	}
+
+	// 收到SIGINT/SIGTERM后优雅关闭，-shutdown-grace 限定总时长
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-sigCtx.Done()
+
+	slog.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownGrace)
+	defer cancel()
+	if redirect != nil {
+		_ = redirect.Shutdown(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("http shutdown error", "err", err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("game shutdown error", "err", err)
 	}
 }
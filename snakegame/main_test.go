@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golearn/snakegame/protocol"
+)
+
+// 反复创建和关闭几百个房间，循环goroutine全部退出，房间从服务器移除
+func TestRoomChurnStopsLoops(t *testing.T) {
+	s, _ := newTestServer(t, newMemoryStore())
+	opts := s.cfg.roomDefaults()
+	base := runtime.NumGoroutine()
+
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("churn-%d", i)
+		room := s.getRoom(name, opts)
+		s.closeRoomIfEmpty(room)
+		// 同名再次加入得到新房间和新循环
+		again := s.getRoom(name, opts)
+		if again == room {
+			t.Fatalf("%s: reused a closed room", name)
+		}
+		s.closeRoomIfEmpty(again)
+	}
+
+	s.lock.Lock()
+	left := len(s.rooms)
+	s.lock.Unlock()
+	if left != 0 {
+		t.Errorf("rooms left = %d, want 0", left)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines = %d, want %d", runtime.NumGoroutine(), base)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 死亡、重生和断线以任意顺序发生时，每条命只写一行分数
+func TestScoreSavedOncePerLife(t *testing.T) {
+	kill := func(r *Room, s *Snake) {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		s.Alive = false
+		r.die(s, CauseWall, nil)
+	}
+	respawn := func(r *Room, s *Snake) {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		s.Body, s.Dir = r.spawnPlacement()
+		s.Alive = true
+		r.startSpawn(s)
+	}
+	tests := []struct {
+		name  string
+		steps func(s *GameServer, r *Room, sn *Snake)
+		want  map[string]int
+	}{
+		{
+			name: "death then disconnect",
+			steps: func(s *GameServer, r *Room, sn *Snake) {
+				kill(r, sn)
+				s.removePlayer(r, sn)
+			},
+			want: map[string]int{CauseWall: 1},
+		},
+		{
+			name: "disconnect while alive",
+			steps: func(s *GameServer, r *Room, sn *Snake) {
+				s.removePlayer(r, sn)
+				s.removePlayer(r, sn)
+			},
+			want: map[string]int{CauseDisconnect: 1},
+		},
+		{
+			name: "respawn then disconnect",
+			steps: func(s *GameServer, r *Room, sn *Snake) {
+				kill(r, sn)
+				respawn(r, sn)
+				s.removePlayer(r, sn)
+			},
+			want: map[string]int{CauseWall: 1, CauseDisconnect: 1},
+		},
+		{
+			name: "death repeated",
+			steps: func(s *GameServer, r *Room, sn *Snake) {
+				kill(r, sn)
+				kill(r, sn)
+				s.removePlayer(r, sn)
+			},
+			want: map[string]int{CauseWall: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			s, _ := newTestServer(t, store)
+			room, sn, _ := s.join("lives", url.Values{"name": {"alice"}}, func(time.Duration) *Conn {
+				return allocConn(time.Second)
+			})
+			tt.steps(s, room, sn)
+			s.scores.Close()
+
+			deaths, err := store.DeathStats(context.Background(), "lives")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int{}
+			for _, d := range deaths {
+				got[d.Cause] = d.Count
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("rows by cause = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 房间循环运行时并发加入和离开：欢迎信息是每个连接收到的第一条消息并包含自己，
+// 留下的玩家收到每个离开者的leave事件。配合 -race 检查欢迎信息和离开广播的加锁
+func TestConcurrentJoinLeave(t *testing.T) {
+	s, _ := newTestServer(t, newMemoryStore())
+	open := func(time.Duration) *Conn { return allocConn(time.Second) }
+	room, host, hostConn := s.join("busy", url.Values{"name": {"host"}}, open)
+	<-hostConn.send // 欢迎信息
+
+	// 加入和离开事件共2n条，不超过host的发送队列
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, sn, c := s.join("busy", url.Values{"name": {fmt.Sprintf("p%d", i)}}, open)
+			var welcome protocol.Welcome
+			if err := json.Unmarshal((<-c.send).data, &welcome); err != nil || welcome.Type != protocol.TypeWelcome {
+				errs <- fmt.Errorf("p%d: first message is not a welcome: %v", i, err)
+			} else if welcome.Players[sn.ID] == nil {
+				errs <- fmt.Errorf("p%d: welcome has no entry for %s", i, sn.ID)
+			}
+			s.removePlayer(room, sn)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	left := 0
+	for len(hostConn.send) > 0 {
+		if strings.Contains(string((<-hostConn.send).data), `"type":"leave"`) {
+			left++
+		}
+	}
+	if left != n {
+		t.Errorf("host got %d leave events, want %d", left, n)
+	}
+	s.removePlayer(room, host)
+}
+
+// 相同种子、相同输入的两个房间每个tick的状态完全相同；换一个种子则不同
+func TestSeedDeterministic(t *testing.T) {
+	s, _ := newTestServer(t, newMemoryStore())
+	run := func(seed int64) []string {
+		opts := s.cfg.roomDefaults()
+		opts.Seed = seed
+		opts.Bots = maxBots
+		room := s.newRoom("seeded", opts)
+		var frames []string
+		for i := 0; i < 300; i++ {
+			room.update()
+			room.lock.Lock()
+			data, _ := json.Marshal(room.stateMessage())
+			room.lock.Unlock()
+			frames = append(frames, string(data))
+		}
+		return frames
+	}
+
+	a, b := run(42), run(42)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("tick %d differs with the same seed:\n%s\n%s", i+1, a[i], b[i])
+		}
+	}
+	if c := run(43); slices.Equal(a, c) {
+		t.Error("seeds 42 and 43 produced the same game")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+// 内置地图名称
+const (
+	MapNone  = ""
+	MapCross = "cross"
+	MapBox   = "box"
+	MapMaze  = "maze"
+)
+
+// 按地图名称生成障碍物，未知名称返回空地图
+func buildObstacles(name string, w, h int) []Point {
+	switch name {
+	case MapCross:
+		return crossMap(w, h)
+	case MapBox:
+		return boxMap(w, h)
+	case MapMaze:
+		return mazeMap(w, h)
+	}
+	return nil
+}
+
+// 十字：中心的横竖两条墙，两端留出通道
+func crossMap(w, h int) []Point {
+	var out []Point
+	cx, cy := w/2, h/2
+	for x := w / 4; x < w-w/4; x++ {
+		out = append(out, Point{X: x, Y: cy})
+	}
+	for y := h / 4; y < h-h/4; y++ {
+		if y != cy {
+			out = append(out, Point{X: cx, Y: y})
+		}
+	}
+	return out
+}
+
+// 方框：距边界3格的一圈墙，每条边中间开口
+func boxMap(w, h int) []Point {
+	var out []Point
+	const inset = 3
+	left, right := inset, w-1-inset
+	top, bottom := inset, h-1-inset
+	cx, cy := w/2, h/2
+	for x := left; x <= right; x++ {
+		if x < cx-1 || x > cx+1 {
+			out = append(out, Point{X: x, Y: top}, Point{X: x, Y: bottom})
+		}
+	}
+	for y := top + 1; y < bottom; y++ {
+		if y < cy-1 || y > cy+1 {
+			out = append(out, Point{X: left, Y: y}, Point{X: right, Y: y})
+		}
+	}
+	return out
+}
+
+// 迷宫：每隔4列一道竖墙，缺口交替出现在上下两端
+func mazeMap(w, h int) []Point {
+	var out []Point
+	const gap = 3
+	for i, x := 0, 3; x < w-2; i, x = i+1, x+4 {
+		for y := 0; y < h; y++ {
+			if i%2 == 0 && y >= h-gap {
+				continue
+			}
+			if i%2 == 1 && y < gap {
+				continue
+			}
+			out = append(out, Point{X: x, Y: y})
+		}
+	}
+	return out
+}
+
+// 判断p是否为障碍物，调用方需持有房间锁
+func (r *Room) isObstacle(p Point) bool {
+	return r.obstacleSet[p]
+}
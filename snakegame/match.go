@@ -0,0 +1,157 @@
+package main
+
+import (
+	"time"
+
+	"golearn/snakegame/protocol"
+)
+
+// 房间模式
+const (
+	ModeEndless = "endless" // 默认：无尽模式，死亡后不复活
+	ModeMatch   = "match"   // 回合制：最后存活的蛇获胜
+)
+
+// 回合结束到下一回合开始的倒计时
+const roundCountdown = 5 * time.Second
+
+// 回合制状态，仅 ModeMatch 房间使用
+type matchState struct {
+	round     int  // 当前（或即将开始的）回合编号，从1开始
+	active    bool // 回合进行中
+	countdown int  // 距下一回合开始的剩余tick数，0表示未在倒计时
+	ticks     int  // 本回合已进行的tick数
+	ring      int  // 决胜阶段已收缩的圈数，见storm.go
+}
+
+// 一条待写入的对局结果
+type matchRow struct {
+	room    string
+	round   int
+	winner  string // 获胜者昵称，同归于尽时为空
+	players int    // 参赛人数
+}
+
+// 倒计时对应的tick数
+func (r *Room) countdownTicks() int {
+	n := int(roundCountdown / r.interval)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// 推进回合状态，返回本tick蛇是否可以移动，调用方需持有房间锁。
+// 未开局时等待至少2名玩家，随后倒计时开始新回合
+func (r *Room) matchTick() bool {
+	m := r.match
+	if m.active {
+		return true
+	}
+	if len(r.players) < 2 {
+		if m.countdown != 0 {
+			m.countdown = 0
+			r.dirty = true
+		}
+		return false
+	}
+	// 倒计时随状态帧下发
+	r.dirty = true
+	if m.countdown == 0 {
+		m.countdown = r.countdownTicks()
+		r.resetSnakes()
+		return false
+	}
+	m.countdown--
+	if m.countdown > 0 {
+		return false
+	}
+	m.round++
+	m.active = true
+	r.emit(map[string]interface{}{"type": "round_start", "round": m.round})
+	return true
+}
+
+// 回合中只剩一条（或没有）存活的蛇时结束本回合：广播胜者、记录对局、
+// 重置所有玩家并开始下一回合的倒计时，调用方需持有房间锁
+func (r *Room) checkRoundOver() {
+	m := r.match
+	if !m.active {
+		return
+	}
+	var winner *Snake
+	alive, entrants := 0, 0
+	for _, s := range r.players {
+		if s.Waiting {
+			continue
+		}
+		entrants++
+		if s.Alive {
+			alive++
+			winner = s
+		}
+	}
+	if alive > 1 {
+		return
+	}
+
+	msg := map[string]interface{}{
+		"type":         "round_over",
+		"round":        m.round,
+		"winner":       "",
+		"name":         "",
+		"next_in_ms":   roundCountdown.Milliseconds(),
+		"participants": entrants,
+	}
+	// 录像在回合结束时保存，下一回合重新录制
+	if r.rec != nil {
+		r.recordTick()
+		rep := r.finishRecording()
+		r.replays.save(rep)
+		if rep != nil {
+			msg["replay"] = rep.ID
+		}
+		r.startRecording()
+	}
+	row := matchRow{room: r.name, round: m.round, players: entrants}
+	if winner != nil {
+		// 胜者的这条命到此结束，分数照常入榜
+		r.saveScore(winner, CauseRoundEnd)
+		msg["winner"] = winner.ID
+		msg["name"] = winner.Name
+		row.winner = winner.Name
+	}
+	r.scores.saveMatch(row)
+	r.emit(msg)
+
+	m.active = false
+	m.countdown = r.countdownTicks()
+	r.resetStorm()
+	r.resetSnakes()
+}
+
+// 把所有玩家重置为新出生的蛇，观战等待中的玩家一并加入，调用方需持有房间锁
+func (r *Room) resetSnakes() {
+	for _, s := range r.players {
+		s.Body = nil
+	}
+	for _, s := range r.sortedPlayers() {
+		s.Body, s.Dir = r.spawnPlacement()
+		s.Score = 0
+		s.Alive = len(s.Body) > 0
+		s.pending = nil
+		s.moveAcc = 0
+		s.Waiting = false
+		r.startSpawn(s)
+	}
+}
+
+// 状态消息中的回合信息
+func (r *Room) matchInfo() *protocol.MatchInfo {
+	m := r.match
+	return &protocol.MatchInfo{
+		Round:       m.round,
+		Active:      m.active,
+		CountdownMS: (time.Duration(m.countdown) * r.interval).Milliseconds(),
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// 一个版本的迁移，分别给出MySQL和SQLite的语句
+type migration struct {
+	version int
+	mysql   []string
+	sqlite  []string
+}
+
+// 数据库迁移，按版本号顺序执行，已执行的版本记录在schema_migrations表中。
+// 只能追加新版本，不能修改已发布的版本
+var migrations = []migration{
+	{
+		version: 1,
+		mysql: []string{`
+			CREATE TABLE IF NOT EXISTS snake_score (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				player_id VARCHAR(50) NOT NULL,
+				room VARCHAR(50) NOT NULL,
+				score INT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+		sqlite: []string{`
+			CREATE TABLE IF NOT EXISTS snake_score (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				player_id TEXT NOT NULL,
+				room TEXT NOT NULL,
+				score INTEGER NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		},
+	},
+	{
+		version: 2,
+		mysql: []string{
+			`CREATE INDEX idx_snake_score_room ON snake_score (room)`,
+			`CREATE INDEX idx_snake_score_player ON snake_score (player_id)`,
+			`CREATE INDEX idx_snake_score_created ON snake_score (created_at)`,
+		},
+		sqlite: []string{
+			`CREATE INDEX IF NOT EXISTS idx_snake_score_room ON snake_score (room)`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_score_player ON snake_score (player_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_score_created ON snake_score (created_at)`,
+		},
+	},
+	{
+		version: 3,
+		mysql: []string{`
+			CREATE TABLE IF NOT EXISTS snake_match (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				room VARCHAR(50) NOT NULL,
+				round INT NOT NULL,
+				winner VARCHAR(50) NOT NULL DEFAULT '',
+				players INT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_snake_match_room (room)
+			)`,
+		},
+		sqlite: []string{`
+			CREATE TABLE IF NOT EXISTS snake_match (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				room TEXT NOT NULL,
+				round INTEGER NOT NULL,
+				winner TEXT NOT NULL DEFAULT '',
+				players INTEGER NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_match_room ON snake_match (room)`,
+		},
+	},
+	{
+		version: 4,
+		mysql: []string{`
+			CREATE TABLE IF NOT EXISTS snake_session (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				player_id VARCHAR(50) NOT NULL,
+				room VARCHAR(50) NOT NULL,
+				score INT NOT NULL,
+				max_len INT NOT NULL,
+				ticks BIGINT NOT NULL,
+				death_cause ENUM('wall', 'self', 'other', 'disconnect', 'shutdown', 'round_end') NOT NULL,
+				started_at TIMESTAMP NOT NULL,
+				ended_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_snake_session_player (player_id),
+				INDEX idx_snake_session_room (room)
+			)`,
+		},
+		sqlite: []string{`
+			CREATE TABLE IF NOT EXISTS snake_session (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				player_id TEXT NOT NULL,
+				room TEXT NOT NULL,
+				score INTEGER NOT NULL,
+				max_len INTEGER NOT NULL,
+				ticks INTEGER NOT NULL,
+				death_cause TEXT NOT NULL CHECK (death_cause IN ('wall', 'self', 'other', 'disconnect', 'shutdown', 'round_end')),
+				started_at TIMESTAMP NOT NULL,
+				ended_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_session_player ON snake_session (player_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_session_room ON snake_session (room)`,
+		},
+	},
+	{
+		// 旧记录没有长度数据，记为0
+		version: 5,
+		mysql: []string{
+			`ALTER TABLE snake_score ADD COLUMN max_len INT NOT NULL DEFAULT 0`,
+		},
+		sqlite: []string{
+			`ALTER TABLE snake_score ADD COLUMN max_len INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		// 旧记录的死亡原因为空字符串，统计时归为unknown
+		version: 6,
+		mysql: []string{
+			`ALTER TABLE snake_score ADD COLUMN death_cause VARCHAR(20) NOT NULL DEFAULT ''`,
+			`ALTER TABLE snake_score ADD COLUMN killer VARCHAR(50) NOT NULL DEFAULT ''`,
+		},
+		sqlite: []string{
+			`ALTER TABLE snake_score ADD COLUMN death_cause TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE snake_score ADD COLUMN killer TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		// 新增结束原因storm；SQLite不能修改CHECK约束，只能重建表
+		version: 7,
+		mysql: []string{
+			`ALTER TABLE snake_session MODIFY death_cause ENUM('wall', 'self', 'other', 'disconnect', 'shutdown', 'round_end', 'storm') NOT NULL`,
+		},
+		sqlite: []string{`
+			CREATE TABLE snake_session_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				player_id TEXT NOT NULL,
+				room TEXT NOT NULL,
+				score INTEGER NOT NULL,
+				max_len INTEGER NOT NULL,
+				ticks INTEGER NOT NULL,
+				death_cause TEXT NOT NULL CHECK (death_cause IN ('wall', 'self', 'other', 'disconnect', 'shutdown', 'round_end', 'storm')),
+				started_at TIMESTAMP NOT NULL,
+				ended_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`INSERT INTO snake_session_new SELECT id, player_id, room, score, max_len, ticks, death_cause, started_at, ended_at FROM snake_session`,
+			`DROP TABLE snake_session`,
+			`ALTER TABLE snake_session_new RENAME TO snake_session`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_session_player ON snake_session (player_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_session_room ON snake_session (room)`,
+		},
+	},
+	{
+		// 房间被管理员关闭或空闲过期时，存活玩家分别记为admin_close和expired
+		version: 8,
+		mysql: []string{
+			`ALTER TABLE snake_session MODIFY death_cause ENUM('wall', 'self', 'other', 'disconnect', 'shutdown', 'round_end', 'storm', 'admin_close', 'expired') NOT NULL`,
+		},
+		sqlite: []string{`
+			CREATE TABLE snake_session_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				player_id TEXT NOT NULL,
+				room TEXT NOT NULL,
+				score INTEGER NOT NULL,
+				max_len INTEGER NOT NULL,
+				ticks INTEGER NOT NULL,
+				death_cause TEXT NOT NULL CHECK (death_cause IN ('wall', 'self', 'other', 'disconnect', 'shutdown', 'round_end', 'storm', 'admin_close', 'expired')),
+				started_at TIMESTAMP NOT NULL,
+				ended_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`INSERT INTO snake_session_new SELECT id, player_id, room, score, max_len, ticks, death_cause, started_at, ended_at FROM snake_session`,
+			`DROP TABLE snake_session`,
+			`ALTER TABLE snake_session_new RENAME TO snake_session`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_session_player ON snake_session (player_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_snake_session_room ON snake_session (room)`,
+		},
+	},
+}
+
+// 执行尚未应用的迁移，重复启动时为空操作
+func (st *sqlStore) migrate() error {
+	_, err := st.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := st.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		stmts := m.mysql
+		if st.dialect == "sqlite" {
+			stmts = m.sqlite
+		}
+		for _, stmt := range stmts {
+			if _, err := st.db.Exec(stmt); err != nil {
+				return fmt.Errorf("migration %d: %w", m.version, err)
+			}
+		}
+		if _, err := st.db.Exec("INSERT INTO schema_migrations (version) VALUES (?)", m.version); err != nil {
+			return fmt.Errorf("record migration %d: %w", m.version, err)
+		}
+		slog.Info("applied migration", "version", m.version)
+	}
+	return nil
+}
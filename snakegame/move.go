@@ -0,0 +1,205 @@
+package main
+
+import "sort"
+
+// 碰撞规则，随状态消息下发：
+// 两个蛇头同一tick进入同一格（或互相穿过对方蛇头）时双方都死亡；
+// 幽灵状态的蛇与其他蛇互相穿过，只有墙、障碍物和自己的身体致死；
+// 本tick正常前进且不变长的蛇，尾巴所在格视为已腾出，可以被蛇头进入
+const collisionRule = "head_on_both_die,tail_vacates"
+
+// 一局结束的原因，前三种为碰撞死亡
+const (
+	CauseWall       = "wall"
+	CauseSelf       = "self"
+	CauseOther      = "other"
+	CauseDisconnect = "disconnect"  // 断线后保留期内未恢复
+	CauseShutdown   = "shutdown"    // 服务器关闭
+	CauseRoundEnd   = "round_end"   // 回合制房间中存活到回合结束
+	CauseStorm      = "storm"       // 决胜阶段被收缩的场地吞没
+	CauseAdminClose = "admin_close" // 管理员关闭房间
+	CauseExpired    = "expired"     // 房间空闲过期
+)
+
+// 一条蛇在本tick的移动计划
+type move struct {
+	snake  *Snake
+	next   Point  // 下一个蛇头位置
+	grows  bool   // 吃到会变长的食物，尾巴不腾出
+	cause  string // 死亡原因，空表示存活
+	killer *Snake // 原因为other时撞上的对手
+}
+
+// 按ID排序的玩家列表，保证每tick处理顺序一致，调用方需持有房间锁
+func (r *Room) sortedPlayers() []*Snake {
+	out := make([]*Snake, 0, len(r.players))
+	for _, s := range r.players {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// 第一阶段：应用排队的方向并计算每条存活蛇的下一个蛇头
+func (r *Room) planMoves() []*move {
+	var moves []*move
+	for _, snake := range r.sortedPlayers() {
+		// 断线保留期内的蛇原地冻结，但仍参与碰撞
+		if !snake.Alive || snake.detached || len(snake.Body) == 0 {
+			continue
+		}
+		// 出生保护期内不移动
+		if snake.Spawning {
+			continue
+		}
+		// 按速度档位，没轮到的蛇本tick不动，但仍参与碰撞
+		if !r.dueToMove(snake) {
+			continue
+		}
+
+		// 每tick只应用一个排队的方向变更
+		if len(snake.pending) > 0 {
+			snake.Dir = snake.pending[0]
+			snake.pending = snake.pending[1:]
+		}
+
+		next := r.nextHead(snake.Body[0], snake.Dir)
+		m := &move{snake: snake, next: next}
+		if i := r.foodAt(next); i >= 0 && r.foods[i].Kind != FoodShrink {
+			m.grows = true
+		}
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+// 蛇头沿dir前进一格后的位置；环形地图出界后从对边进入，之后的碰撞判定使用绕回后的坐标
+func (r *Room) nextHead(p Point, dir string) Point {
+	switch dir {
+	case "up":
+		p.Y--
+	case "down":
+		p.Y++
+	case "left":
+		p.X--
+	case "right":
+		p.X++
+	}
+	if r.wrap {
+		p.X = (p.X + r.width) % r.width
+		p.Y = (p.Y + r.height) % r.height
+	}
+	return p
+}
+
+// 第二阶段：在所有蛇头确定后统一判定碰撞，结果与处理顺序无关
+func (r *Room) resolveCollisions(moves []*move) {
+	moving := make(map[*Snake]*move, len(moves))
+	heads := make(map[Point][]*move, len(moves))
+	for _, m := range moves {
+		moving[m.snake] = m
+		heads[m.next] = append(heads[m.next], m)
+	}
+
+	for _, m := range moves {
+		// 撞墙判定，障碍物等同于墙
+		if m.next.X < 0 || m.next.X >= r.width || m.next.Y < 0 || m.next.Y >= r.height || r.isObstacle(m.next) {
+			m.cause = CauseWall
+			continue
+		}
+		// 决胜阶段收缩后的圈外格子
+		if !r.inBounds(m.next) {
+			m.cause = CauseStorm
+			continue
+		}
+		// 头对头：多个蛇头进入同一格，全部死亡
+		for _, o := range heads[m.next] {
+			if o != m && solid(m.snake, o.snake) {
+				m.cause, m.killer = CauseOther, o.snake
+			}
+		}
+		// 互相穿过：两个蛇头交换位置，全部死亡
+		for _, o := range moves {
+			if o != m && solid(m.snake, o.snake) && m.next == o.snake.Body[0] && o.next == m.snake.Body[0] {
+				m.cause, m.killer = CauseOther, o.snake
+			}
+		}
+	}
+
+	// 撞身体判定：死亡的蛇尾巴不再腾出，可能导致其他蛇也撞上，迭代到稳定为止
+	for changed := true; changed; {
+		changed = false
+		for _, m := range moves {
+			if m.cause != "" {
+				continue
+			}
+			if cause, killer := r.bodyHit(m, moving); cause != "" {
+				m.cause, m.killer = cause, killer
+				changed = true
+			}
+		}
+	}
+}
+
+// 判断蛇头是否撞上任何蛇身（含已死亡的蛇），返回死亡原因和撞上的对手；
+// 出生保护中的蛇可以被穿过，幽灵状态下与其他蛇互相穿过
+func (r *Room) bodyHit(m *move, moving map[*Snake]*move) (string, *Snake) {
+	for _, other := range r.players {
+		if other.Spawning || (other != m.snake && !solid(m.snake, other)) {
+			continue
+		}
+		body := other.Body
+		if om, ok := moving[other]; ok && om.cause == "" && !om.grows && len(body) > 0 {
+			body = body[:len(body)-1]
+		}
+		for _, b := range body {
+			if b != m.next {
+				continue
+			}
+			if other == m.snake {
+				return CauseSelf, nil
+			}
+			return CauseOther, other
+		}
+	}
+	return "", nil
+}
+
+// 第三阶段：存活的蛇前进，触发危险格并吃食物，死亡的蛇保存分数
+func (r *Room) applyMoves(moves []*move) {
+	if len(moves) > 0 {
+		r.dirty = true
+	}
+	for _, m := range moves {
+		snake := m.snake
+		if m.cause != "" {
+			snake.Alive = false
+			snake.Ghost = 0
+			r.die(snake, m.cause, m.killer)
+			continue
+		}
+
+		// 正常前进；变长时尾巴留在原处，与碰撞判定中尾巴不腾出一致
+		if m.grows {
+			snake.Body = append([]Point{m.next}, snake.Body...)
+		} else {
+			snake.Body = append([]Point{m.next}, snake.Body[:len(snake.Body)-1]...)
+		}
+
+		// 毒格不致死，先于食物判定生效
+		r.triggerHazard(snake, m.next)
+
+		// 吃食物判定，被吃掉的食物在所有蛇移动后再补充
+		if i := r.foodAt(m.next); i >= 0 {
+			eatFood(snake, r.foods[i])
+			r.removeFood(i)
+			if !snake.Bot {
+				r.touch()
+			}
+			if len(snake.Body) > snake.MaxLen {
+				snake.MaxLen = len(snake.Body)
+			}
+		}
+		r.pickUpPowerUp(snake, m.next)
+	}
+}
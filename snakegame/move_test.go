@@ -0,0 +1,174 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"golearn/snakegame/protocol"
+)
+
+// 只有棋盘和蛇的房间，用于直接测试移动的各个阶段
+func newMoveRoom(width, height int, snakes ...*Snake) *Room {
+	r := &Room{width: width, height: height, players: make(map[string]*Snake)}
+	for _, s := range snakes {
+		r.players[s.ID] = s
+	}
+	return r
+}
+
+// 存活的蛇，body[0]为蛇头
+func snakeAt(id, dir string, body ...Point) *Snake {
+	return &Snake{Player: protocol.Player{ID: id, Dir: dir, Body: body, Alive: true}}
+}
+
+// 执行一个tick的移动，不含食物补充和广播
+func step(r *Room) []*move {
+	moves := r.planMoves()
+	r.resolveCollisions(moves)
+	r.applyMoves(moves)
+	return moves
+}
+
+// 方向变更排队：不能与上一个方向相反，最多排两个
+func TestChangeDir(t *testing.T) {
+	tests := []struct {
+		name        string
+		inputs      []string
+		wantQueued  []bool
+		wantPending []string
+	}{
+		{"tight turn", []string{"up", "left"}, []bool{true, true}, []string{"up", "left"}},
+		{"reverse", []string{"left"}, []bool{false}, nil},
+		{"same direction", []string{"right"}, []bool{false}, nil},
+		{"reverse the queued turn", []string{"up", "down"}, []bool{true, false}, []string{"up"}},
+		{"queue full", []string{"up", "left", "down"}, []bool{true, true, false}, []string{"up", "left"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := snakeAt("P1", "right", Point{X: 5, Y: 5}, Point{X: 4, Y: 5}, Point{X: 3, Y: 5})
+			r := newMoveRoom(10, 10, s)
+			for i, dir := range tt.inputs {
+				if got := r.changeDir(s, dir); got != tt.wantQueued[i] {
+					t.Errorf("changeDir(%s) = %v, want %v", dir, got, tt.wantQueued[i])
+				}
+			}
+			if !slices.Equal(s.pending, tt.wantPending) {
+				t.Errorf("pending = %v, want %v", s.pending, tt.wantPending)
+			}
+		})
+	}
+}
+
+// 向右时在两个tick之间先按上再按左：依次转弯，而不是掉头撞上自己
+func TestTightTurnBetweenTicks(t *testing.T) {
+	s := snakeAt("P1", "right", Point{X: 5, Y: 5}, Point{X: 4, Y: 5}, Point{X: 3, Y: 5})
+	r := newMoveRoom(10, 10, s)
+	r.changeDir(s, "up")
+	r.changeDir(s, "left")
+
+	for i, want := range []Point{{X: 5, Y: 4}, {X: 4, Y: 4}} {
+		step(r)
+		if !s.Alive || s.Body[0] != want {
+			t.Fatalf("tick %d: alive=%v head=%v, want alive at %v", i+1, s.Alive, s.Body[0], want)
+		}
+	}
+	if s.Dir != "left" || len(s.pending) != 0 {
+		t.Errorf("dir=%s pending=%v, want left with an empty queue", s.Dir, s.pending)
+	}
+}
+
+// 两阶段碰撞判定：结果只取决于棋盘，与玩家ID和map遍历顺序无关
+func TestResolveCollisions(t *testing.T) {
+	p := func(x, y int) Point { return Point{X: x, Y: y} }
+	tests := []struct {
+		name string
+		// 两条蛇，第一条记为a，第二条记为b
+		a, b      func(id string) *Snake
+		foods     []Point
+		wantA     string // a的死亡原因，空表示存活
+		wantB     string
+		wantKillA string // 撞死a的是b时为"b"
+		wantKillB string
+		wantBodyB []Point // 非空时再执行移动，检查b移动后的身体
+	}{
+		{
+			name:  "head to head into the same cell",
+			a:     func(id string) *Snake { return snakeAt(id, "right", p(2, 5), p(1, 5), p(0, 5)) },
+			b:     func(id string) *Snake { return snakeAt(id, "left", p(4, 5), p(5, 5), p(6, 5)) },
+			wantA: CauseOther, wantB: CauseOther, wantKillA: "b", wantKillB: "a",
+		},
+		{
+			name:  "heads swap places",
+			a:     func(id string) *Snake { return snakeAt(id, "right", p(3, 5), p(2, 5), p(1, 5)) },
+			b:     func(id string) *Snake { return snakeAt(id, "left", p(4, 5), p(5, 5), p(6, 5)) },
+			wantA: CauseOther, wantB: CauseOther, wantKillA: "b", wantKillB: "a",
+		},
+		{
+			name:  "head into body",
+			a:     func(id string) *Snake { return snakeAt(id, "up", p(5, 6), p(5, 7), p(5, 8)) },
+			b:     func(id string) *Snake { return snakeAt(id, "right", p(6, 5), p(5, 5), p(4, 5), p(3, 5)) },
+			wantA: CauseOther, wantKillA: "b",
+		},
+		{
+			name: "head into a tail vacated this tick",
+			a:    func(id string) *Snake { return snakeAt(id, "up", p(5, 6), p(5, 7)) },
+			b:    func(id string) *Snake { return snakeAt(id, "right", p(7, 5), p(6, 5), p(5, 5)) },
+		},
+		{
+			// b本tick吃到食物变长，尾巴留在原处，下一帧这一格仍有蛇身
+			name:  "head into the tail of a snake eating this tick",
+			a:     func(id string) *Snake { return snakeAt(id, "up", p(5, 6), p(5, 7)) },
+			b:     func(id string) *Snake { return snakeAt(id, "right", p(7, 5), p(6, 5), p(5, 5)) },
+			foods: []Point{p(8, 5)},
+			wantA: CauseOther, wantKillA: "b",
+			wantBodyB: []Point{p(8, 5), p(7, 5), p(6, 5), p(5, 5)},
+		},
+		{
+			name:  "tail of a dying snake stays",
+			a:     func(id string) *Snake { return snakeAt(id, "up", p(7, 6), p(7, 7)) },
+			b:     func(id string) *Snake { return snakeAt(id, "right", p(9, 5), p(8, 5), p(7, 5)) },
+			wantA: CauseOther, wantB: CauseWall, wantKillA: "b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 交换ID改变处理顺序，重复多次覆盖不同的map遍历顺序
+			for _, ids := range [][2]string{{"P1", "P2"}, {"P2", "P1"}} {
+				for i := 0; i < 20; i++ {
+					a, b := tt.a(ids[0]), tt.b(ids[1])
+					role := map[*Snake]string{a: "a", b: "b"}
+					r := newMoveRoom(10, 10, a, b)
+					for _, f := range tt.foods {
+						r.foods = append(r.foods, Food{Point: f})
+					}
+					moves := r.planMoves()
+					if len(moves) != 2 {
+						t.Fatalf("planned %d moves, want 2", len(moves))
+					}
+					r.resolveCollisions(moves)
+					for _, m := range moves {
+						want, wantKiller := tt.wantA, tt.wantKillA
+						if m.snake == b {
+							want, wantKiller = tt.wantB, tt.wantKillB
+						}
+						if m.cause != want || role[m.killer] != wantKiller {
+							t.Fatalf("ids %v: %s got cause %q killer %q, want %q killer %q",
+								ids, role[m.snake], m.cause, role[m.killer], want, wantKiller)
+						}
+					}
+					if tt.wantBodyB != nil {
+						// 只执行b的移动，a的死亡处理需要完整的房间
+						for _, m := range moves {
+							if m.snake == b {
+								r.applyMoves([]*move{m})
+							}
+						}
+						if !slices.Equal(b.Body, tt.wantBodyB) {
+							t.Fatalf("ids %v: b body after the tick = %v, want %v", ids, b.Body, tt.wantBodyB)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 昵称最大长度（按字符计）
+const maxNameLen = 16
+
+// 清理昵称：去掉首尾空白和控制字符，超长截断，结果可能为空
+func sanitizeName(raw string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(raw) {
+		if unicode.IsControl(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	name := []rune(strings.TrimSpace(b.String()))
+	if len(name) > maxNameLen {
+		name = name[:maxNameLen]
+	}
+	return string(name)
+}
+
+// 在房间内取一个不重复的昵称，重名时追加数字后缀，调用方需持有房间锁
+func (r *Room) uniqueName(name string) string {
+	taken := make(map[string]bool, len(r.players))
+	for _, s := range r.players {
+		taken[s.Name] = true
+	}
+	if !taken[name] {
+		return name
+	}
+	base := []rune(name)
+	for i := 2; ; i++ {
+		suffix := strconv.Itoa(i)
+		b := base
+		if len(b)+len(suffix) > maxNameLen {
+			b = b[:maxNameLen-len(suffix)]
+		}
+		if candidate := string(b) + suffix; !taken[candidate] {
+			return candidate
+		}
+	}
+}
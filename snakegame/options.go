@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 房间参数范围
+const (
+	defaultBoardSize = 20
+	minBoardSize     = 10
+	maxBoardSize     = 200
+	minTickInterval  = 50 * time.Millisecond
+	maxTickInterval  = 1000 * time.Millisecond
+
+	defaultMaxPlayers = 8
+	maxMaxPlayers     = 32
+)
+
+// 创建房间时的参数，仅第一个进入房间的玩家传入的参数生效
+type RoomOptions struct {
+	Width    int
+	Height   int
+	Interval time.Duration
+
+	FoodWeights FoodWeights
+	Map         string
+	Wrap        bool
+	MaxPlayers  int
+	Mode        string
+	SpeedTiers  []int
+	Record      bool
+	Bots        int
+	PowerUps    PowerUpConfig
+	PoisonEvery int           // 毒格平均生成间隔（tick），0表示不生成
+	SuddenDeath int           // 回合制房间进入决胜阶段的tick数，0表示不收缩
+	IdleTTL     time.Duration // 空闲多久后关闭房间，0表示不过期，由服务器配置决定
+	Seed        int64         // 随机数种子，相同种子和相同输入得到相同的对局
+	PassHash    []byte        // 口令的哈希，为nil表示公开房间
+}
+
+// 默认房间参数
+func defaultRoomOptions() RoomOptions {
+	return RoomOptions{
+		Width:    defaultBoardSize,
+		Height:   defaultBoardSize,
+		Interval: tickInterval,
+
+		FoodWeights: defaultFoodWeights(),
+		MaxPlayers:  defaultMaxPlayers,
+		Mode:        ModeEndless,
+		PowerUps:    defaultPowerUpConfig(),
+		PoisonEvery: defaultPoisonEvery,
+		SuddenDeath: defaultSuddenDeath,
+		IdleTTL:     defaultRoomTTL,
+	}
+}
+
+// 在defaults基础上从WebSocket URL解析房间参数，如 ?w=40&h=30&tick=100&map=cross&wrap=1&max=4&powerups=30，超出范围的值会被截断
+func parseRoomOptions(q url.Values, defaults RoomOptions) RoomOptions {
+	opts := defaults
+	if v, err := strconv.Atoi(q.Get("w")); err == nil {
+		opts.Width = clampInt(v, minBoardSize, maxBoardSize)
+	}
+	if v, err := strconv.Atoi(q.Get("h")); err == nil {
+		opts.Height = clampInt(v, minBoardSize, maxBoardSize)
+	}
+	if v, err := strconv.Atoi(q.Get("tick")); err == nil {
+		ms := clampInt(v, int(minTickInterval/time.Millisecond), int(maxTickInterval/time.Millisecond))
+		opts.Interval = time.Duration(ms) * time.Millisecond
+	}
+	opts.FoodWeights = parseFoodWeights(q.Get("food"))
+	switch m := q.Get("map"); m {
+	case MapCross, MapBox, MapMaze:
+		opts.Map = m
+	}
+	opts.Wrap = q.Get("wrap") == "1"
+	opts.Record = q.Get("record") == "1"
+	if v, err := strconv.Atoi(q.Get("bots")); err == nil {
+		opts.Bots = clampInt(v, 0, maxBots)
+	}
+	if v, err := strconv.Atoi(q.Get("max")); err == nil {
+		opts.MaxPlayers = clampInt(v, 1, maxMaxPlayers)
+	}
+	opts.SpeedTiers = parseSpeedTiers(q.Get("speed"))
+	// ?powerups=0 关闭道具
+	if v, err := strconv.Atoi(q.Get("powerups")); err == nil {
+		if v <= 0 {
+			opts.PowerUps.Every = 0
+		} else {
+			opts.PowerUps.Every = clampInt(v, minPowerUpEvery, maxPowerUpEvery)
+		}
+	}
+	// ?poison=0 关闭毒格
+	if v, err := strconv.Atoi(q.Get("poison")); err == nil {
+		if v <= 0 {
+			opts.PoisonEvery = 0
+		} else {
+			opts.PoisonEvery = clampInt(v, minPoisonEvery, maxPoisonEvery)
+		}
+	}
+	// ?sudden=0 关闭决胜阶段的场地收缩
+	if v, err := strconv.Atoi(q.Get("sudden")); err == nil {
+		if v <= 0 {
+			opts.SuddenDeath = 0
+		} else {
+			opts.SuddenDeath = clampInt(v, minSuddenDeath, maxSuddenDeath)
+		}
+	}
+	if v, err := strconv.Atoi(q.Get("ghost")); err == nil {
+		opts.PowerUps.GhostTicks = clampInt(v, 1, maxGhostTicks)
+	}
+	// ?seed=N 固定随机数种子，否则随机生成
+	if v, err := strconv.ParseInt(q.Get("seed"), 10, 64); err == nil {
+		opts.Seed = v
+	} else {
+		opts.Seed = randomSeed()
+	}
+	// ?pass=<code> 创建私人房间，只保留哈希
+	opts.PassHash = hashPass(q.Get("pass"))
+	// ?mode=match 创建回合制房间；?mode=spectator 是连接角色，不影响房间模式
+	if q.Get("mode") == ModeMatch {
+		opts.Mode = ModeMatch
+	}
+	return opts
+}
+
+// 用加密随机数生成种子
+func randomSeed() int64 {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// 把v限制在[lo, hi]范围内
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
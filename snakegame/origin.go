@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 允许发起WebSocket连接的来源，由 ALLOWED_ORIGINS 配置，逗号分隔，每项可以是：
+//
+//	https://game.example.com:8443  协议、主机、端口都要一致
+//	game.example.com               http或https，默认端口
+//	*.example.com                  任意一级或多级子域名，不含example.com本身
+//
+// 未写端口时按协议的默认端口比较（http为80，https为443）
+type originRule struct {
+	scheme   string // 为空表示http和https都可以
+	host     string // 小写，通配规则为去掉"*."后的后缀
+	port     string // 为空表示协议默认端口
+	wildcard bool
+}
+
+// 解析 ALLOWED_ORIGINS，忽略空项
+func parseOrigins(s string) []originRule {
+	var rules []originRule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		var rule originRule
+		if i := strings.Index(item, "://"); i >= 0 {
+			rule.scheme, item = item[:i], item[i+3:]
+		}
+		item = strings.TrimSuffix(item, "/")
+		if h, p, err := net.SplitHostPort(item); err == nil {
+			item, rule.port = h, p
+		}
+		if strings.HasPrefix(item, "*.") {
+			rule.wildcard = true
+			item = item[2:]
+		}
+		rule.host = item
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// 协议的默认端口
+func defaultPort(scheme string) string {
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// 判断Origin请求头是否在允许列表中；"null"、file:// 等非http(s)来源一律拒绝
+func originAllowed(origin string, rules []originRule) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+	for _, r := range rules {
+		if r.scheme != "" && r.scheme != u.Scheme {
+			continue
+		}
+		want := r.port
+		if want == "" {
+			want = defaultPort(u.Scheme)
+		}
+		if port != want {
+			continue
+		}
+		if r.wildcard {
+			if strings.HasSuffix(host, "."+r.host) {
+				return true
+			}
+		} else if host == r.host {
+			return true
+		}
+	}
+	return false
+}
+
+// 升级器使用的来源检查：规则为空时不限制（本地开发）；
+// 没有Origin头的请求来自非浏览器客户端，不受跨站劫持影响，同样放行
+func originChecker(rules []originRule) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		if len(rules) == 0 {
+			return true
+		}
+		origin := r.Header.Get("Origin")
+		return origin == "" || originAllowed(origin, rules)
+	}
+}
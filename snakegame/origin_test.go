@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// 来源匹配：协议、端口、通配子域名，以及null、file:// 等特殊来源
+func TestOriginAllowed(t *testing.T) {
+	rules := parseOrigins("https://game.example.com:8443, play.example.org, *.example.net, http://localhost:8080,,")
+	if len(rules) != 4 {
+		t.Fatalf("parsed %d rules, want 4", len(rules))
+	}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://game.example.com:8443", true},
+		{"https://GAME.example.com:8443", true},
+		{"https://game.example.com", false},     // 端口不同
+		{"http://game.example.com:8443", false}, // 协议不同
+		{"https://play.example.org", true},      // 未写协议时http和https都可以
+		{"http://play.example.org", true},
+		{"http://play.example.org:80", true},     // 显式写出默认端口
+		{"https://play.example.org:8443", false}, // 非默认端口
+		{"https://play.example.org.evil.com", false},
+		{"https://evilplay.example.org", false},
+		{"https://a.example.net", true},
+		{"https://a.b.example.net", true},
+		{"https://example.net", false}, // 通配不含域名本身
+		{"https://badexample.net", false},
+		{"http://localhost:8080", true},
+		{"http://localhost", false},
+		{"null", false},
+		{"file://", false},
+		{"file:///home/user/index.html", false},
+		{"https://user@play.example.org", false},
+		{"https://play.example.org/path", false},
+		{"", false},
+		{"://", false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(tt.origin, rules); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+// 没有配置规则时放行所有请求；配置后没有Origin头的非浏览器请求放行，不匹配的拒绝
+func TestOriginChecker(t *testing.T) {
+	tests := []struct {
+		rules  string
+		origin string
+		want   bool
+	}{
+		{"", "https://evil.com", true},
+		{"example.com", "", true},
+		{"example.com", "https://example.com", true},
+		{"example.com", "https://evil.com", false},
+		{"example.com", "null", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/ws/r1", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := originChecker(parseOrigins(tt.rules))(req); got != tt.want {
+			t.Errorf("rules %q, origin %q: %v, want %v", tt.rules, tt.origin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 私人房间：创建者用 ?pass=<code> 设置口令，之后加入的玩家和观战者都要提供相同口令。
+// 房间只保存口令的SHA-256，口令不会出现在房间列表、广播消息和访问日志中
+
+// 口令错误时的关闭码
+const closeWrongPass = 4003
+
+// 口令的哈希，未设置口令时为nil
+func hashPass(code string) []byte {
+	if code == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(code))
+	return sum[:]
+}
+
+// 房间是否设置了口令
+func (r *Room) locked() bool {
+	return r.passHash != nil
+}
+
+// 校验口令，比较哈希而不是原文，耗时与口令内容无关；公开房间总是通过
+func (r *Room) checkPass(code string) bool {
+	if !r.locked() {
+		return true
+	}
+	sum := sha256.Sum256([]byte(code))
+	return subtle.ConstantTimeCompare(sum[:], r.passHash) == 1
+}
+
+// 口令错误时发给WebSocket客户端的结构化错误
+func wrongPassMsg() map[string]interface{} {
+	return map[string]interface{}{"type": "error", "code": "wrong_pass"}
+}
+
+// HTTP接口访问私人房间时校验 ?pass=，失败时返回403并返回false
+func requirePass(c *gin.Context, room *Room) bool {
+	if room.checkPass(c.Query("pass")) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "wrong passcode", "code": "wrong_pass"})
+	return false
+}
+
+// 访问日志中隐去的查询参数：房间口令、JWT和断线重连的会话令牌，拿到任何一个都能冒充玩家
+var redactedParams = []string{"pass", "token", "resume"}
+
+// 把路径中的口令和令牌参数替换为redacted
+func redactQuery(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	q, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i] + "?redacted"
+	}
+	changed := false
+	for _, k := range redactedParams {
+		if _, ok := q[k]; ok {
+			q.Set(k, "redacted")
+			changed = true
+		}
+	}
+	if !changed {
+		return path
+	}
+	return path[:i+1] + q.Encode()
+}
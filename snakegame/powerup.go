@@ -0,0 +1,127 @@
+package main
+
+import "golearn/snakegame/protocol"
+
+// 道具种类
+const (
+	PowerGhost  = "ghost"  // 限时与其他蛇互不碰撞，墙和障碍物照常致死
+	PowerShrink = "shrink" // 长度立即减半（最短3节），不扣分
+)
+
+// 道具参数，生成间隔和幽灵时长可按房间用 ?powerups=N&ghost=N 调整
+const (
+	defaultPowerUpEvery = 75 // 平均每隔多少tick生成一个道具
+	minPowerUpEvery     = 10
+	maxPowerUpEvery     = 1000
+	defaultGhostTicks   = 20 // 幽灵效果持续的tick数
+	maxGhostTicks       = 200
+	powerUpTTL          = 50 // 道具在棋盘上保留的tick数
+	maxPowerUps         = 2  // 棋盘上同时存在的道具上限
+	shrinkMinLen        = 3  // 减半后的最短长度
+)
+
+// 棋盘上的道具
+type PowerUp = protocol.PowerUp
+
+// 房间的道具设置
+type PowerUpConfig struct {
+	Every      int // 平均生成间隔（tick），0表示不生成道具
+	GhostTicks int // 幽灵效果持续的tick数
+}
+
+// 默认道具设置
+func defaultPowerUpConfig() PowerUpConfig {
+	return PowerUpConfig{Every: defaultPowerUpEvery, GhostTicks: defaultGhostTicks}
+}
+
+// 返回p处道具的下标，没有则返回-1
+func (r *Room) powerUpAt(p Point) int {
+	for i, pu := range r.powerups {
+		if pu.Point == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// 道具倒计时，到期的移除，并按生成间隔随机生成新道具，调用方需持有房间锁
+func (r *Room) tickPowerUps() {
+	kept := r.powerups[:0]
+	for _, pu := range r.powerups {
+		pu.ExpiresIn--
+		if pu.ExpiresIn > 0 {
+			kept = append(kept, pu)
+		} else {
+			r.dirty = true
+		}
+	}
+	r.powerups = kept
+
+	if r.powerCfg.Every <= 0 || len(r.powerups) >= maxPowerUps || r.rng.Intn(r.powerCfg.Every) != 0 {
+		return
+	}
+	p, ok := r.randomEmptyCell()
+	if !ok {
+		return
+	}
+	kinds := []string{PowerGhost, PowerShrink}
+	kind := kinds[r.rng.Intn(len(kinds))]
+	r.powerups = append(r.powerups, PowerUp{Point: p, Kind: kind, ExpiresIn: powerUpTTL})
+	r.dirty = true
+}
+
+// 蛇身上的效果倒计时。在碰撞判定之后、移动之前调用，
+// 因此幽灵效果正好覆盖拾取后GhostTicks个tick的碰撞判定，调用方需持有房间锁
+func (r *Room) tickEffects() {
+	for _, s := range r.players {
+		if s.Ghost > 0 {
+			s.Ghost--
+			r.dirty = true
+		}
+	}
+}
+
+// 蛇头拾取p处的道具，调用方需持有房间锁
+func (r *Room) pickUpPowerUp(snake *Snake, p Point) {
+	i := r.powerUpAt(p)
+	if i < 0 {
+		return
+	}
+	switch r.powerups[i].Kind {
+	case PowerGhost:
+		snake.Ghost = r.powerCfg.GhostTicks
+	case PowerShrink:
+		n := len(snake.Body) / 2
+		if n < shrinkMinLen {
+			n = shrinkMinLen
+		}
+		if n < len(snake.Body) {
+			snake.Body = snake.Body[:n]
+		}
+	}
+	r.powerups = append(r.powerups[:i], r.powerups[i+1:]...)
+}
+
+// 道具列表的副本，没有道具时为空数组而不是null，调用方需持有房间锁
+func (r *Room) powerUpList() []PowerUp {
+	return append([]PowerUp{}, r.powerups...)
+}
+
+// 两条蛇之间是否会碰撞：任一方处于幽灵状态时互相穿过
+func solid(a, b *Snake) bool {
+	return a.Ghost == 0 && b.Ghost == 0
+}
+
+// 判断两组道具的位置和种类是否相同；剩余时间每tick都在变化，不计入比较，
+// 增量协议只在道具增减时下发
+func samePowerUps(a, b []PowerUp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Point != b[i].Point || a[i].Kind != b[i].Kind {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golearn/snakegame/protocol"
+)
+
+// 客户端消息类型
+const (
+	MsgDir  = protocol.MsgDir
+	MsgPing = protocol.MsgPing
+	MsgChat = protocol.MsgChat
+	MsgPong = protocol.MsgPong
+)
+
+// 客户端发来的消息，字段见 protocol.ClientMsg
+type ClientMsg struct {
+	protocol.ClientMsg
+
+	legacy bool // 旧版裸字符串指令
+}
+
+// 错误回复
+type ErrorMsg = protocol.ErrorMsg
+
+// 构建错误回复
+func errorReply(code, format string, args ...interface{}) ErrorMsg {
+	return ErrorMsg{Type: protocol.TypeError, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// 解析客户端消息，兼容旧版的 "up"/"down"/"left"/"right"/"ping" 裸字符串
+func decodeClientMsg(data []byte) (ClientMsg, error) {
+	switch cmd := string(data); cmd {
+	case "up", "down", "left", "right":
+		return ClientMsg{ClientMsg: protocol.ClientMsg{Type: MsgDir, Dir: cmd}, legacy: true}, nil
+	case "ping":
+		return ClientMsg{ClientMsg: protocol.ClientMsg{Type: MsgPing}, legacy: true}, nil
+	}
+	var msg ClientMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return ClientMsg{}, err
+	}
+	return msg, nil
+}
+
+// 处理一条客户端消息，返回需要回复给该连接的内容（nil表示不回复）；
+// snake为nil表示观战连接，方向指令被忽略
+func (r *Room) dispatch(conn *Conn, snake *Snake, msg ClientMsg) interface{} {
+	switch msg.Type {
+	case MsgDir:
+		if !validDir(msg.Dir) {
+			return errorReply("bad_dir", "invalid direction: %q", msg.Dir)
+		}
+		if snake != nil {
+			r.lock.Lock()
+			r.changeDir(snake, msg.Dir)
+			r.lock.Unlock()
+		}
+		return nil
+	case MsgPing:
+		if msg.legacy {
+			return "pong"
+		}
+		return map[string]string{"type": "pong"}
+	case MsgChat:
+		return r.chat(conn, snake, msg.Text)
+	case MsgPong:
+		conn.recordPong(msg.ID, time.Now())
+		return nil
+	}
+	return errorReply("unknown_type", "unknown message type: %q", msg.Type)
+}
+
+// 读取并处理一条原始消息，解析失败时回复错误而不断开连接
+func (r *Room) handleMessage(conn *Conn, snake *Snake, data []byte) {
+	msg, err := decodeClientMsg(data)
+	if err != nil {
+		conn.sendJSON(errorReply("bad_json", "malformed message: %v", err))
+		return
+	}
+	if snake != nil {
+		r.wakeUp()
+	}
+	switch reply := r.dispatch(conn, snake, msg).(type) {
+	case nil:
+	case string:
+		conn.sendText([]byte(reply))
+	default:
+		conn.sendJSON(reply)
+	}
+}
+
+// 是否为合法方向
+func validDir(dir string) bool {
+	switch dir {
+	case "up", "down", "left", "right":
+		return true
+	}
+	return false
+}
+
+// 最多排队的方向变更数
+const maxPendingDirs = 2
+
+// 相反方向
+var opposite = map[string]string{"up": "down", "down": "up", "left": "right", "right": "left"}
+
+// 排队一个方向变更，不能与前一个方向（上一tick实际使用的方向或队尾）相反，
+// 队列满或方向未变时忽略并返回false，调用方需持有房间锁
+func (r *Room) changeDir(snake *Snake, dir string) bool {
+	last := snake.Dir
+	if n := len(snake.pending); n > 0 {
+		last = snake.pending[n-1]
+	}
+	if dir == last || dir == opposite[last] || len(snake.pending) >= maxPendingDirs {
+		return false
+	}
+	snake.pending = append(snake.pending, dir)
+	r.touch()
+	return true
+}
@@ -0,0 +1,159 @@
+package protocol
+
+// 客户端消息类型
+const (
+	MsgDir  = "dir"
+	MsgPing = "ping"
+	MsgChat = "chat"
+	MsgPong = "pong" // 回复服务器的延迟探测
+)
+
+// 服务器消息类型，即各消息的type字段
+const (
+	TypeWelcome = "welcome"
+	TypeState   = "state"
+	TypeDeath   = "death"
+	TypePing    = "ping"
+	TypeError   = "error"
+)
+
+// 客户端发来的消息，如 {"type":"dir","dir":"up"}、{"type":"ping"}、{"type":"chat","text":"hi"}
+type ClientMsg struct {
+	Type string `json:"type"`
+	Dir  string `json:"dir,omitempty"`
+	Text string `json:"text,omitempty"`
+	ID   int64  `json:"id,omitempty"` // pong：对应的探测编号
+}
+
+// 错误回复
+type ErrorMsg struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// 延迟探测，客户端需原样回复 {"type":"pong","id":n}
+type PingMsg struct {
+	Type string `json:"type"`
+	ID   int64  `json:"id"`
+	T    int64  `json:"t"` // 发送时间（Unix毫秒）
+}
+
+// 回合制房间的回合信息
+type MatchInfo struct {
+	Round       int   `json:"round"`
+	Active      bool  `json:"active"`
+	CountdownMS int64 `json:"countdown_ms"`
+}
+
+// 小地图上的一条蛇：只有蛇头位置
+type MinimapEntry struct {
+	ID    string `json:"id"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Color int    `json:"color"`
+}
+
+// 视野中心和半径
+type Viewport struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Radius int `json:"radius"`
+}
+
+// 完整状态帧。开启视野（?view=N）时只包含视野内的蛇、食物、道具和危险格，
+// 另附Minimap和Viewport
+type State struct {
+	Type       string             `json:"type"` // "state"
+	Tick       int64              `json:"tick"`
+	Players    map[string]*Player `json:"players"`
+	Foods      []Food             `json:"foods"`
+	Food       Food               `json:"food"` // 第一个食物，兼容只认单个食物的旧客户端
+	PowerUps   []PowerUp          `json:"powerups"`
+	Hazards    []Hazard           `json:"hazards"`
+	Bounds     Bounds             `json:"bounds"`
+	Room       string             `json:"room"`
+	W          int                `json:"w"`
+	H          int                `json:"h"`
+	TickMS     int64              `json:"tick_ms"`
+	Spectators int                `json:"spectators"`
+	Collision  string             `json:"collision"`
+
+	PlayerCount    int        `json:"player_count"`
+	SpectatorCount int        `json:"spectator_count"`
+	Mode           string     `json:"mode"`
+	Match          *MatchInfo `json:"match,omitempty"`
+
+	Minimap  []MinimapEntry `json:"minimap,omitempty"`
+	Viewport *Viewport      `json:"viewport,omitempty"`
+}
+
+// 加入房间后的第一条消息
+type Welcome struct {
+	Type       string             `json:"type"` // "welcome"
+	Player     string             `json:"player"`
+	Name       string             `json:"name"`
+	Token      string             `json:"token"` // 会话令牌，断线后用 ?resume= 接管原来的蛇
+	Resumed    bool               `json:"resumed"`
+	Room       string             `json:"room"`
+	W          int                `json:"w"`
+	H          int                `json:"h"`
+	TickMS     int64              `json:"tick_ms"`
+	Foods      []Food             `json:"foods"`
+	Food       Food               `json:"food"`
+	PowerUps   []PowerUp          `json:"powerups"`
+	Hazards    []Hazard           `json:"hazards"`
+	Bounds     Bounds             `json:"bounds"`
+	Players    map[string]*Player `json:"players"`
+	Spectator  bool               `json:"spectator"`
+	MaxPlayers int                `json:"max_players"`
+	Map        string             `json:"map"`
+	Wrap       bool               `json:"wrap"`
+	Obstacles  []Point            `json:"obstacles"`
+	Mode       string             `json:"mode"`
+	SpeedTiers []int              `json:"speed_tiers"`
+	Replay     string             `json:"replay"`
+	Seed       int64              `json:"seed"`
+	Binary     bool               `json:"binary"`
+}
+
+// 死亡事件。机器人的在产生死亡的tick内、状态帧之前广播；真人玩家的要等
+// 分数写入器查到历史最高分后才广播，因此可能晚于该tick的状态帧
+type DeathEvent struct {
+	Type   string `json:"type"` // "death"
+	Player string `json:"player"`
+	Cause  string `json:"cause"`            // 与snake_session.death_cause相同
+	Killer string `json:"killer,omitempty"` // 原因为other时撞上的对手ID
+	Score  int    `json:"score"`
+	PB     bool   `json:"pb,omitempty"` // 本局刷新了个人最高分
+}
+
+// 个人最高分通知，只发给本人，紧接着广播带pb标记的死亡事件。
+// 第一次有记录且得分大于0时也算，此时Previous为空
+type PersonalBestMsg struct {
+	Type     string `json:"type"` // "personal_best"
+	Score    int    `json:"score"`
+	Previous *int   `json:"previous"`
+}
+
+// 离开事件
+type LeaveEvent struct {
+	Type   string `json:"type"` // "leave"
+	Player string `json:"player"`
+}
+
+// 加入事件，Spawn为出生时的蛇头位置，回合进行中加入的玩家为空
+type JoinEvent struct {
+	Type   string `json:"type"` // "join"
+	Player string `json:"player"`
+	Name   string `json:"name"`
+	Bot    bool   `json:"bot,omitempty"`
+	Spawn  *Point `json:"spawn,omitempty"`
+}
+
+// 人数变化事件，加入、离开和观战者进出时立即广播，不必等下一个状态帧
+type CountsEvent struct {
+	Type       string `json:"type"`            // "counts"
+	Players    int    `json:"player_count"`    // 真人玩家数，含断线保留期内的，不含机器人
+	Spectators int    `json:"spectator_count"` // 观战人数，含SSE观战流
+}
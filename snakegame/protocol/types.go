@@ -0,0 +1,113 @@
+// Package protocol 定义贪吃蛇服务器与客户端之间的JSON消息。
+// 服务器（snakegame）和Go客户端库（snakegame/client）共用这里的类型，
+// 字段和JSON标签只在这里维护，编码和解码不会各自演变
+package protocol
+
+// 坐标点
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// 食物，坐标字段平铺在JSON中以兼容旧客户端
+type Food struct {
+	Point
+	Kind      string `json:"kind"`
+	ExpiresIn int    `json:"expires_in,omitempty"` // 剩余tick数，0表示不过期
+}
+
+// 棋盘上的道具
+type PowerUp struct {
+	Point
+	Kind      string `json:"kind"`
+	ExpiresIn int    `json:"expires_in"` // 剩余tick数
+}
+
+// 棋盘上的危险格，与食物、道具分开下发
+type Hazard struct {
+	Point
+	Kind      string `json:"kind"`
+	ExpiresIn int    `json:"expires_in"` // 剩余tick数
+}
+
+// 可活动的矩形范围，四条边都包含在内
+type Bounds struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Right  int `json:"right"`
+	Bottom int `json:"bottom"`
+}
+
+// 点是否在范围内
+func (b Bounds) Contains(p Point) bool {
+	return p.X >= b.Left && p.X <= b.Right && p.Y >= b.Top && p.Y <= b.Bottom
+}
+
+// 移动方向
+type Dir string
+
+const (
+	Up    Dir = "up"
+	Down  Dir = "down"
+	Left  Dir = "left"
+	Right Dir = "right"
+)
+
+// 是否为合法方向
+func (d Dir) Valid() bool {
+	switch d {
+	case Up, Down, Left, Right:
+		return true
+	}
+	return false
+}
+
+// 相反方向，非法方向返回空
+func (d Dir) Opposite() Dir {
+	switch d {
+	case Up:
+		return Down
+	case Down:
+		return Up
+	case Left:
+		return Right
+	case Right:
+		return Left
+	}
+	return ""
+}
+
+// 沿d前进一格后的位置，不处理出界和环形地图
+func (p Point) Step(d Dir) Point {
+	switch d {
+	case Up:
+		p.Y--
+	case Down:
+		p.Y++
+	case Left:
+		p.X--
+	case Right:
+		p.X++
+	}
+	return p
+}
+
+// 状态消息中的一条蛇
+type Player struct {
+	ID     string  `json:"id"`         // 玩家ID，房间内唯一且不变，用作map键
+	Name   string  `json:"name"`       // 显示昵称，保存到排行榜
+	Body   []Point `json:"body"`       // 蛇身体坐标
+	Dir    string  `json:"dir"`        // 当前方向
+	Score  int     `json:"score"`      // 得分
+	MaxLen int     `json:"max_length"` // 本局达到的最大长度，缩短后不回落
+	Alive  bool    `json:"alive"`      // 是否存活
+	Color  int     `json:"color"`      // 调色板下标（0-15），重生和断线恢复后不变
+
+	Waiting    bool `json:"waiting,omitempty"`     // 回合制房间中途加入，等待下一回合
+	Spawning   bool `json:"spawning,omitempty"`    // 出生保护中：不移动，也不会被撞
+	SpawnTicks int  `json:"spawn_ticks,omitempty"` // 出生保护剩余tick数
+	Speed      int  `json:"speed,omitempty"`       // 速度档位，房间未启用加速时为0
+	Bot        bool `json:"bot,omitempty"`         // 服务器控制的机器人
+	Ghost      int  `json:"ghost,omitempty"`       // 幽灵效果剩余tick数
+	LatencyMS  int  `json:"latency_ms,omitempty"`  // 平滑后的往返延迟，客户端未回复探测时为0
+}
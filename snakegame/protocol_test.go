@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 解析JSON信封和旧版裸字符串指令
+func TestDecodeClientMsg(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantType   string
+		wantDir    string
+		wantLegacy bool
+		wantErr    bool
+	}{
+		{"up", MsgDir, "up", true, false},
+		{"ping", MsgPing, "", true, false},
+		{`{"type":"dir","dir":"left"}`, MsgDir, "left", false, false},
+		{`{"type":"ping"}`, MsgPing, "", false, false},
+		{`{"type":"dance"}`, "dance", "", false, false},
+		{`{"type":`, "", "", false, true},
+		{"UP", "", "", false, true},
+	}
+	for _, tt := range tests {
+		msg, err := decodeClientMsg([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("decodeClientMsg(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if msg.Type != tt.wantType || msg.Dir != tt.wantDir || msg.legacy != tt.wantLegacy {
+			t.Errorf("decodeClientMsg(%q) = %+v, want type %q dir %q legacy %v",
+				tt.in, msg, tt.wantType, tt.wantDir, tt.wantLegacy)
+		}
+	}
+}
+
+// 不经过WebSocket直接分发消息：方向排队，ping有回复，错误消息得到错误回复
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		spectator   bool
+		wantReply   string // 回复的JSON，空表示不回复
+		wantPending []string
+	}{
+		{"direction", `{"type":"dir","dir":"up"}`, false, "", []string{"up"}},
+		{"legacy direction", "up", false, "", []string{"up"}},
+		{"spectator direction", `{"type":"dir","dir":"up"}`, true, "", nil},
+		{"bad direction", `{"type":"dir","dir":"sideways"}`, false, `"code":"bad_dir"`, nil},
+		{"ping", `{"type":"ping"}`, false, `{"type":"pong"}`, nil},
+		{"legacy ping", "ping", false, `"pong"`, nil},
+		{"unknown type", `{"type":"dance"}`, false, `"code":"unknown_type"`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := snakeAt("P1", "right", Point{X: 5, Y: 5}, Point{X: 4, Y: 5})
+			r := newMoveRoom(10, 10, s)
+			var snake *Snake
+			if !tt.spectator {
+				snake = s
+			}
+			msg, err := decodeClientMsg([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reply := r.dispatch(allocConn(time.Second), snake, msg)
+			var got string
+			if reply != nil {
+				data, _ := json.Marshal(reply)
+				got = string(data)
+			}
+			if tt.wantReply == "" && got != "" || !strings.Contains(got, tt.wantReply) {
+				t.Errorf("reply = %s, want %s", got, tt.wantReply)
+			}
+			if !slices.Equal(s.pending, tt.wantPending) {
+				t.Errorf("pending = %v, want %v", s.pending, tt.wantPending)
+			}
+		})
+	}
+}
+
+// 格式错误的JSON得到错误回复，连接不会被关闭
+func TestHandleMessageBadJSON(t *testing.T) {
+	r := newMoveRoom(10, 10)
+	c := allocConn(time.Second)
+	r.handleMessage(c, nil, []byte(`{"type":`))
+	msg := <-c.send
+	if !strings.Contains(string(msg.data), `"code":"bad_json"`) {
+		t.Errorf("reply = %s, want bad_json error", msg.data)
+	}
+	select {
+	case <-c.done:
+		t.Error("connection closed after malformed message")
+	default:
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 可手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// 用fakeClock计时的限流器
+func fakeLimiter(rate float64, burst int) (*rateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newRateLimiter(rate, burst)
+	l.last, l.now = clock.t, clock.now
+	return l, clock
+}
+
+// 令牌桶：突发用完后按速率补充，积累不超过burst
+func TestRateLimiter(t *testing.T) {
+	l, clock := fakeLimiter(10, 3)
+	allowed := func(n int) int {
+		got := 0
+		for i := 0; i < n; i++ {
+			if l.Allow() {
+				got++
+			}
+		}
+		return got
+	}
+
+	if got := allowed(5); got != 3 {
+		t.Errorf("burst: allowed %d of 5, want 3", got)
+	}
+	clock.advance(250 * time.Millisecond)
+	if got := allowed(5); got != 2 {
+		t.Errorf("after 250ms at 10/s: allowed %d, want 2", got)
+	}
+	clock.advance(time.Hour)
+	if got := allowed(5); got != 3 {
+		t.Errorf("after a long pause: allowed %d, want burst 3", got)
+	}
+}
+
+// 连接的入站限流：超限丢弃，每秒最多警告一次，持续超限inboundKickAfter后关闭
+func TestCheckInbound(t *testing.T) {
+	c := allocConn(time.Second)
+	limit, clock := fakeLimiter(inboundRate, inboundBurst)
+	c.limit = limit
+
+	counts := map[int]int{}
+	// 每秒发送两倍于限额的消息，突发令牌约2秒后用完
+	step := time.Second / (2 * inboundRate)
+	for clock.t.Sub(time.Unix(0, 0)) < 2*inboundKickAfter {
+		res := c.checkInbound(clock.t)
+		counts[res]++
+		if res == inboundClose {
+			break
+		}
+		clock.advance(step)
+	}
+	if counts[inboundClose] != 1 {
+		t.Fatalf("results = %v, want the connection closed", counts)
+	}
+	if elapsed := clock.t.Sub(time.Unix(0, 0)); elapsed < inboundKickAfter {
+		t.Errorf("closed after %v, want at least %v", elapsed, inboundKickAfter)
+	}
+	// 约每秒一次警告
+	if w := counts[inboundWarn]; w < 9 || w > 11 {
+		t.Errorf("warnings = %d, want about one per second", w)
+	}
+
+	// 停止超限一段时间后重新计时
+	c = allocConn(time.Second)
+	limit, clock = fakeLimiter(inboundRate, inboundBurst)
+	c.limit = limit
+	for i := 0; i < inboundBurst+5; i++ {
+		c.checkInbound(clock.t)
+	}
+	clock.advance(5 * time.Second)
+	if res := c.checkInbound(clock.t); res != inboundOK {
+		t.Errorf("after a pause: %d, want inboundOK", res)
+	}
+	if !c.overSince.IsZero() {
+		t.Errorf("overSince = %v, want reset", c.overSince)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 把HTTP请求重定向到同一主机的HTTPS地址，httpsAddr为HTTPS监听地址（如 :8443），
+// 端口为443时省略
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]") // 不带端口的IPv6地址
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redis命令超时
+const redisTimeout = 3 * time.Second
+
+// Redis返回的错误回复
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// 按RESP协议写出一条命令
+func writeCommand(w *bufio.Writer, args []string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return w.Flush()
+}
+
+// 读取一条RESP回复：简单字符串和批量字符串为string，整数为int64，
+// 数组为[]interface{}，空回复为nil，错误回复返回redisError
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply line")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// 最简Redis客户端：单连接，命令串行执行，网络出错后下次调用时重连
+type redisClient struct {
+	addr string
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func newRedisClient(addr string) *redisClient {
+	return &redisClient{addr: addr}
+}
+
+// 执行一条命令并返回回复
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.addr, redisTimeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn, c.r, c.w = conn, bufio.NewReader(conn), bufio.NewWriter(conn)
+	}
+	_ = c.conn.SetDeadline(time.Now().Add(redisTimeout))
+	err := writeCommand(c.w, args)
+	var reply interface{}
+	if err == nil {
+		reply, err = readReply(c.r)
+	}
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		// 连接状态未知，丢弃后重连
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// 关闭连接
+func (c *redisClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// 发布订阅连接：独占一条连接，频道可随时增减，断线后自动重连并重新订阅
+type redisSub struct {
+	addr    string
+	handle  func(channel string, payload []byte)
+	mu      sync.Mutex
+	conn    net.Conn
+	w       *bufio.Writer
+	chans   map[string]int // 频道 -> 引用计数
+	closed  bool
+	stopped chan struct{}
+}
+
+// 建立订阅连接并启动读协程，收到的消息在读协程中交给handle
+func newRedisSub(addr string, handle func(channel string, payload []byte)) (*redisSub, error) {
+	s := &redisSub{
+		addr:    addr,
+		handle:  handle,
+		chans:   make(map[string]int),
+		stopped: make(chan struct{}),
+	}
+	r, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	go s.readLoop(r)
+	return s, nil
+}
+
+// 建立连接并重新订阅当前所有频道
+func (s *redisSub) dial() (*bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", s.addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn, s.w = conn, bufio.NewWriter(conn)
+	if len(s.chans) > 0 {
+		args := []string{"SUBSCRIBE"}
+		for ch := range s.chans {
+			args = append(args, ch)
+		}
+		if err := s.writeLocked(args); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return bufio.NewReader(conn), nil
+}
+
+// 写出一条命令，调用方需持有s.mu
+func (s *redisSub) writeLocked(args []string) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(redisTimeout))
+	return writeCommand(s.w, args)
+}
+
+// 订阅频道，重复订阅只增加引用计数
+func (s *redisSub) subscribe(ch string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chans[ch]++
+	if s.chans[ch] == 1 {
+		// 写失败时连接会断开，重连后统一重新订阅
+		_ = s.writeLocked([]string{"SUBSCRIBE", ch})
+	}
+}
+
+// 取消订阅，引用计数归零时才真正退订
+func (s *redisSub) unsubscribe(ch string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chans[ch] == 0 {
+		return
+	}
+	s.chans[ch]--
+	if s.chans[ch] == 0 {
+		delete(s.chans, ch)
+		_ = s.writeLocked([]string{"UNSUBSCRIBE", ch})
+	}
+}
+
+// 读协程：分发消息推送，连接断开后每秒重试重连，直到Close
+func (s *redisSub) readLoop(r *bufio.Reader) {
+	defer close(s.stopped)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			slog.Warn("redis subscription lost", "err", err)
+			for r = nil; r == nil; {
+				time.Sleep(time.Second)
+				if s.isClosed() {
+					return
+				}
+				if r, err = s.dial(); err != nil {
+					slog.Warn("redis reconnect failed", "err", err)
+				}
+			}
+			continue
+		}
+		// 消息推送格式：["message", 频道, 内容]，订阅确认等其他推送忽略
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 3 || items[0] != "message" {
+			continue
+		}
+		ch, _ := items[1].(string)
+		payload, _ := items[2].(string)
+		s.handle(ch, []byte(payload))
+	}
+}
+
+func (s *redisSub) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// 关闭订阅连接并等待读协程退出
+func (s *redisSub) Close() {
+	s.mu.Lock()
+	s.closed = true
+	_ = s.conn.Close()
+	s.mu.Unlock()
+	<-s.stopped
+}
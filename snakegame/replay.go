@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// 录像上限，超出后停止录制并标记为截断
+const (
+	maxReplayFrames = 3000    // 单个录像最多记录的tick数
+	maxReplayBytes  = 4 << 20 // 单个录像最多占用的字节数
+)
+
+// 默认的录像保存目录
+const defaultReplayDir = "./replays"
+
+// 录像ID格式，同时用作文件名
+var replayIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// 保存的录像：第一帧为完整状态，之后每tick一帧增量，格式与 ?proto=delta 相同
+type Replay struct {
+	ID        string            `json:"id"`
+	Room      string            `json:"room"`
+	Width     int               `json:"w"`
+	Height    int               `json:"h"`
+	TickMS    int64             `json:"tick_ms"`
+	Map       string            `json:"map"`
+	Wrap      bool              `json:"wrap"`
+	Obstacles []Point           `json:"obstacles"`
+	StartedAt string            `json:"started_at"`
+	Truncated bool              `json:"truncated"`
+	Frames    []json.RawMessage `json:"frames"`
+}
+
+// 房间的录制状态，调用方需持有房间锁
+type recorder struct {
+	id        string
+	startedAt time.Time
+	frames    []json.RawMessage
+	size      int
+	truncated bool
+	sent      sentFrame // 上一帧的状态（增量基准）
+}
+
+// 生成录像ID
+func newReplayID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 开始新的录像，调用方需持有房间锁
+func (r *Room) startRecording() {
+	r.rec = &recorder{id: newReplayID(), startedAt: time.Now()}
+}
+
+// 记录本tick的状态：第一帧为完整状态，之后为增量，超出上限后停止，调用方需持有房间锁
+func (r *Room) recordTick() {
+	rec := r.rec
+	if rec == nil || rec.truncated {
+		return
+	}
+	var frame []byte
+	if len(rec.frames) == 0 {
+		frame, _ = json.Marshal(r.stateMessage())
+	} else {
+		frame, _ = json.Marshal(r.buildDelta(rec.sent))
+	}
+	if len(rec.frames) >= maxReplayFrames || rec.size+len(frame) > maxReplayBytes {
+		rec.truncated = true
+		r.log.Warn("replay truncated", "replay", rec.id, "frames", len(rec.frames))
+		return
+	}
+	rec.frames = append(rec.frames, frame)
+	rec.size += len(frame)
+	rec.sent = r.captureSent()
+}
+
+// 当前录像ID，未录制时为空，调用方需持有房间锁
+func (r *Room) replayID() string {
+	if r.rec == nil {
+		return ""
+	}
+	return r.rec.id
+}
+
+// 结束当前录像，没有任何帧时返回nil，调用方需持有房间锁
+func (r *Room) finishRecording() *Replay {
+	rec := r.rec
+	r.rec = nil
+	if rec == nil || len(rec.frames) == 0 {
+		return nil
+	}
+	return &Replay{
+		ID:        rec.id,
+		Room:      r.name,
+		Width:     r.width,
+		Height:    r.height,
+		TickMS:    r.interval.Milliseconds(),
+		Map:       r.mapName,
+		Wrap:      r.wrap,
+		Obstacles: r.obstacles,
+		StartedAt: rec.startedAt.Format(time.RFC3339),
+		Truncated: rec.truncated,
+		Frames:    rec.frames,
+	}
+}
+
+// 录像文件存储，写入在后台协程中进行，不占用房间锁
+type replayStore struct {
+	dir string
+	wg  sync.WaitGroup
+}
+
+func newReplayStore(dir string) *replayStore {
+	return &replayStore{dir: dir}
+}
+
+// 录像文件路径，ID非法时返回错误
+func (s *replayStore) path(id string) (string, error) {
+	if !replayIDPattern.MatchString(id) {
+		return "", errors.New("invalid replay id")
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// 异步写入录像文件，rep为nil时忽略
+func (s *replayStore) save(rep *Replay) {
+	if rep == nil {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		data, err := json.Marshal(rep)
+		if err != nil {
+			slog.Error("replay marshal failed", "replay", rep.ID, "err", err)
+			return
+		}
+		p, _ := s.path(rep.ID)
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			slog.Error("replay dir error", "dir", s.dir, "err", err)
+			return
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			slog.Error("replay write failed", "replay", rep.ID, "err", err)
+			return
+		}
+		slog.Info("replay saved", "replay", rep.ID, "room", rep.Room, "frames", len(rep.Frames))
+	}()
+}
+
+// 读取录像文件
+func (s *replayStore) load(id string) (*Replay, error) {
+	p, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var rep Replay
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// 等待所有录像写完
+func (s *replayStore) Close() {
+	s.wg.Wait()
+}
+
+// 下载录像接口：GET /api/replay/:id
+func (s *GameServer) replayFile(c *gin.Context) {
+	p, err := s.replays.path(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := os.Stat(p); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "replay not found"})
+		return
+	}
+	c.Header("Content-Type", "application/json")
+	c.File(p)
+}
+
+// 录像回放接口：GET /ws/replay/:id，按原速度把录像帧推给客户端
+func (s *GameServer) replayWS(c *gin.Context) {
+	rep, err := s.replays.load(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "replay not found"})
+		return
+	}
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		slog.Warn("websocket upgrade failed", "replay", c.Param("id"), "remote", c.ClientIP(), "err", err)
+		return
+	}
+	interval := time.Duration(rep.TickMS) * time.Millisecond
+	conn := newConn(ws, interval)
+
+	// 读循环只用于发现客户端断开
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn.sendJSON(map[string]interface{}{
+		"type":       "replay_start",
+		"id":         rep.ID,
+		"room":       rep.Room,
+		"w":          rep.Width,
+		"h":          rep.Height,
+		"tick_ms":    rep.TickMS,
+		"map":        rep.Map,
+		"wrap":       rep.Wrap,
+		"obstacles":  rep.Obstacles,
+		"started_at": rep.StartedAt,
+		"frames":     len(rep.Frames),
+		"truncated":  rep.Truncated,
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for _, frame := range rep.Frames {
+		select {
+		case <-ticker.C:
+			conn.sendText(frame)
+		case <-ctx.Done():
+			conn.Close()
+			return
+		}
+	}
+	conn.sendJSON(map[string]string{"type": "replay_end", "id": rep.ID})
+	conn.closeWith(websocket.CloseNormalClosure, "replay finished")
+}
@@ -2,14 +2,45 @@ CREATE DATABASE IF NOT EXISTS snake_game DEFAULT CHARACTER SET utf8mb4;
 
 USE snake_game;
 
+-- 表结构由服务启动时自动迁移创建（见 migrate.go），这里仅供手动建库参考
+
 CREATE TABLE IF NOT EXISTS snake_score (
     id INT AUTO_INCREMENT PRIMARY KEY,
     player_id VARCHAR(50) NOT NULL,
     room VARCHAR(50) NOT NULL,
     score INT NOT NULL,
+    max_len INT NOT NULL DEFAULT 0, -- 本局最大长度
+    death_cause VARCHAR(20) NOT NULL DEFAULT '', -- 结束原因，取值同snake_session.death_cause
+    killer VARCHAR(50) NOT NULL DEFAULT '', -- 原因为other时对手的玩家ID
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- 回合制房间（?mode=match）的对局结果，winner为空表示同归于尽
+CREATE TABLE IF NOT EXISTS snake_match (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    room VARCHAR(50) NOT NULL,
+    round INT NOT NULL,
+    winner VARCHAR(50) NOT NULL DEFAULT '',
+    players INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_snake_match_room (room)
+);
+
+-- 每局的完整记录：出生到死亡/断线/关服的时长和结束原因
+CREATE TABLE IF NOT EXISTS snake_session (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    player_id VARCHAR(50) NOT NULL,
+    room VARCHAR(50) NOT NULL,
+    score INT NOT NULL,
+    max_len INT NOT NULL,
+    ticks BIGINT NOT NULL,
+    death_cause ENUM('wall', 'self', 'other', 'disconnect', 'shutdown', 'round_end', 'storm', 'admin_close', 'expired') NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_snake_session_player (player_id),
+    INDEX idx_snake_session_room (room)
+);
+
 -- 查看排行榜
--- SELECT player_id, room, MAX(score) AS best_score, COUNT(*) AS games, MAX(created_at) AS last_play
+-- SELECT player_id, room, MAX(score) AS best_score, MAX(max_len) AS best_length, COUNT(*) AS games, MAX(created_at) AS last_play
 -- FROM snake_score GROUP BY player_id, room ORDER BY best_score DESC LIMIT 10;
\ No newline at end of file
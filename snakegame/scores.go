@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	scoreQueueSize = 1024                   // 待写入分数队列长度
+	scoreBatchSize = 50                     // 单条INSERT最多写入的行数
+	scoreBatchWait = 100 * time.Millisecond // 第一行入队后最多等待多久凑批
+)
+
+// 一局结束时待写入的记录，同时写入snake_score和snake_session
+type scoreRow struct {
+	playerID string
+	room     string
+	score    int
+
+	maxLen    int       // 本局最大长度
+	ticks     int64     // 本局持续的tick数
+	cause     string    // 结束原因
+	killer    string    // 原因为other时对手的玩家ID，其他为空
+	startedAt time.Time // 出生时间
+
+	// 不为nil时，写入前在写协程中查询玩家此前的最高分并回调；
+	// found为false表示第一次有记录，err不为nil表示查询失败
+	onBest func(prev int, found bool, err error)
+}
+
+// 异步分数写入器：游戏循环只负责入队，由独立协程批量写库，
+// 慢数据库不会阻塞房间锁。房间关闭时大量玩家同时结算，
+// 写协程把scoreBatchWait内或scoreBatchSize条以内的行合并为一条多行INSERT
+type scoreWriter struct {
+	store   ScoreStore
+	queue   chan scoreRow
+	flushCh chan struct{} // 立即写出已收集的行，见 flush
+	timeout time.Duration // 单次写库超时
+	db      *dbStats      // 失败计数
+	wg      sync.WaitGroup
+	dropped atomic.Int64 // 队列满被丢弃的条数
+	written atomic.Int64 // 成功写入snake_score的行数
+
+	mu     sync.RWMutex
+	closed bool // 已关闭，之后的入队被丢弃
+}
+
+// 创建分数写入器并启动写协程
+func newScoreWriter(store ScoreStore, timeout time.Duration, db *dbStats) *scoreWriter {
+	w := &scoreWriter{
+		store:   store,
+		queue:   make(chan scoreRow, scoreQueueSize),
+		flushCh: make(chan struct{}, 1),
+		timeout: timeout,
+		db:      db,
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// 非阻塞入队，队列满时丢弃并计数
+func (w *scoreWriter) enqueue(row scoreRow) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		slog.Warn("score writer closed, score dropped", "room", row.room, "player", row.playerID, "score", row.score)
+		return false
+	}
+	select {
+	case w.queue <- row:
+		return true
+	default:
+		n := w.dropped.Add(1)
+		slog.Warn("score queue full, score dropped", "room", row.room, "player", row.playerID, "score", row.score, "dropped", n)
+		return false
+	}
+}
+
+// 让写协程立即写出已入队的行，不再等满scoreBatchWait；房间关闭时调用
+func (w *scoreWriter) flush() {
+	select {
+	case w.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// 关闭队列并等待剩余分数写完
+func (w *scoreWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+// 写协程：第一行入队后最多等待scoreBatchWait，期间收集到的行合并写入，
+// 满scoreBatchSize条、收到flush或队列关闭时提前写出。
+// 需要回调最高分的行（玩家死亡）不等待，以免推迟死亡事件
+func (w *scoreWriter) run() {
+	defer w.wg.Done()
+	var batch []scoreRow
+	timer := time.NewTimer(scoreBatchWait)
+	timer.Stop()
+	write := func() {
+		timer.Stop()
+		for len(batch) > 0 {
+			n := min(len(batch), scoreBatchSize)
+			w.insert(batch[:n])
+			batch = batch[n:]
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case row, ok := <-w.queue:
+			if !ok {
+				write()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(scoreBatchWait)
+			}
+			batch = append(batch, row)
+			if len(batch) >= scoreBatchSize || row.onBest != nil {
+				write()
+			}
+		case <-timer.C:
+			write()
+		case <-w.flushCh:
+			// flush之前入队的行可能还在队列里，先取出
+		drain:
+			for {
+				select {
+				case row, ok := <-w.queue:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, row)
+				default:
+					break drain
+				}
+			}
+			write()
+		}
+	}
+}
+
+// 异步写入一条对局结果；对局很少，直接起协程写库，Close时一并等待
+func (w *scoreWriter) saveMatch(row matchRow) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		slog.Warn("score writer closed, match dropped", "room", row.room, "round", row.round)
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		defer cancel()
+		if err := w.store.SaveMatch(ctx, row); err != nil {
+			w.db.record(fmt.Sprintf("insert match (%s#%d)", row.room, row.round), err)
+		}
+	}()
+}
+
+// 写入前为需要回调的行查询此前的最高分；同一批次中同一玩家的多局依次比较，
+// 后一局的“此前”包含前一局
+func (w *scoreWriter) lookupBests(ctx context.Context, batch []scoreRow) {
+	need := make(map[string]bool)
+	for _, row := range batch {
+		if row.onBest != nil {
+			need[row.playerID] = true
+		}
+	}
+	if len(need) == 0 {
+		return
+	}
+	type best struct {
+		score int
+		found bool
+	}
+	bests := make(map[string]*best)
+	for _, row := range batch {
+		if !need[row.playerID] {
+			continue
+		}
+		b := bests[row.playerID]
+		var err error
+		if b == nil {
+			b = &best{}
+			b.score, b.found, err = w.store.BestScore(ctx, row.playerID)
+			if err != nil {
+				w.db.record(fmt.Sprintf("best score (%s)", row.playerID), err)
+			} else {
+				bests[row.playerID] = b
+			}
+		}
+		if row.onBest != nil {
+			row.onBest(b.score, b.found, err)
+		}
+		if err == nil && (!b.found || row.score > b.score) {
+			b.score, b.found = row.score, true
+		}
+	}
+}
+
+// 批量写入分数和对局记录
+func (w *scoreWriter) insert(batch []scoreRow) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	w.lookupBests(ctx, batch)
+	cancel()
+
+	saved := w.write("insert", batch, w.store.SaveScores)
+	w.written.Add(int64(len(saved)))
+	for _, row := range saved {
+		slog.Info("score saved", "room", row.room, "player", row.playerID, "score", row.score, "cause", row.cause)
+	}
+	w.write("insert session", batch, w.store.SaveSessions)
+}
+
+// 用save写入一批行，返回写入成功的行。多行语句失败时退回逐行写入，
+// 超时则不再重试，以免拖长关闭；没有写入的每一行都单独记录日志
+func (w *scoreWriter) write(op string, batch []scoreRow, save func(context.Context, []scoreRow) error) []scoreRow {
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	err := save(ctx, batch)
+	cancel()
+	if err == nil {
+		return batch
+	}
+	w.db.record(fmt.Sprintf("%s (%d rows)", op, len(batch)), err)
+	if len(batch) == 1 || errors.Is(err, context.DeadlineExceeded) {
+		for _, row := range batch {
+			rowFailed(op, row, err)
+		}
+		return nil
+	}
+
+	var saved []scoreRow
+	for _, row := range batch {
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		err := save(ctx, []scoreRow{row})
+		cancel()
+		if err != nil {
+			w.db.count(err)
+			rowFailed(op, row, err)
+			continue
+		}
+		saved = append(saved, row)
+	}
+	slog.Warn("multi-row insert rejected, fell back to single rows", "op", op, "rows", len(batch), "saved", len(saved))
+	return saved
+}
+
+// 记录一行没有写入的分数
+func rowFailed(op string, row scoreRow, err error) {
+	slog.Error("score row not saved", "op", op, "room", row.room, "player", row.playerID, "score", row.score, "cause", row.cause, "err", err)
+}
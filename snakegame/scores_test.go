@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 每次写库都很慢的存储
+type slowStore struct {
+	*memoryStore
+	delay time.Duration
+	saved atomic.Int64
+}
+
+func (s *slowStore) SaveScores(ctx context.Context, rows []scoreRow) error {
+	time.Sleep(s.delay)
+	s.saved.Add(int64(len(rows)))
+	return s.memoryStore.SaveScores(ctx, rows)
+}
+
+func (s *slowStore) BestScore(ctx context.Context, playerID string) (int, bool, error) {
+	time.Sleep(s.delay)
+	return s.memoryStore.BestScore(ctx, playerID)
+}
+
+// 数据库很慢时玩家不断死亡，房间仍按200ms的间隔tick，分数在关闭时写完
+func TestSlowStoreKeepsTicking(t *testing.T) {
+	store := &slowStore{memoryStore: newMemoryStore(), delay: 500 * time.Millisecond}
+	s, _ := newTestServer(t, store)
+	opts := s.cfg.roomDefaults()
+	opts.Interval = 200 * time.Millisecond
+	opts.Bots = 1 // 机器人让房间保持运行，不进入休眠
+	room := s.getRoom("slow-db", opts)
+	ticks := func() int64 {
+		room.lock.Lock()
+		defer room.lock.Unlock()
+		return room.tick
+	}
+
+	const deaths = 5
+	start, began := ticks(), time.Now()
+	for i := 0; i < deaths; i++ {
+		// 贴着左墙向左的蛇，下一tick撞墙死亡并保存分数
+		room.lock.Lock()
+		sn := snakeAt(fmt.Sprintf("H%d", i), "left", Point{X: 0, Y: 2 * i}, Point{X: 1, Y: 2 * i})
+		room.players[sn.ID] = sn
+		room.lock.Unlock()
+		time.Sleep(opts.Interval)
+	}
+	elapsed := time.Since(began)
+	got := ticks() - start
+	// 同步写库时每次死亡都会让tick多停500ms
+	if want := int64(elapsed/opts.Interval) - 1; got < want {
+		t.Errorf("%d ticks in %v, want at least %d", got, elapsed, want)
+	}
+
+	room.lock.Lock()
+	for id, sn := range room.players {
+		if !sn.Bot {
+			delete(room.players, id)
+		}
+	}
+	room.lock.Unlock()
+	s.closeRoomIfEmpty(room)
+	s.scores.Close()
+	if n := store.saved.Load(); n != deaths {
+		t.Errorf("saved %d scores, want %d", n, deaths)
+	}
+}
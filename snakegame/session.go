@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// 断线后保留蛇的时长，期间可用会话令牌恢复
+var resumeGrace = 10 * time.Second
+
+// 生成随机会话令牌
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 用会话令牌找回处于断线保留期的蛇并挂上新连接，调用方需持有房间锁
+func (r *Room) resumeSnake(token string, conn *Conn) *Snake {
+	if token == "" {
+		return nil
+	}
+	for _, s := range r.players {
+		if s.token == token && s.detached {
+			s.detached = false
+			s.detachGen++ // 让旧的保留期定时器失效
+			s.conn = conn
+			return s
+		}
+	}
+	return nil
+}
+
+// 连接断开：蛇原地冻结（不移动但仍可被撞），保留期内未恢复则移除；写出失败的连接立即移除
+func (s *GameServer) detach(room *Room, snake *Snake, conn *Conn) {
+	conn.Close()
+
+	room.lock.Lock()
+	if snake.conn != conn {
+		// 已被新连接接管，或因写出失败已被移出房间
+		evicted := room.players[snake.ID] != snake
+		room.lock.Unlock()
+		if evicted {
+			s.closeRoomIfEmpty(room)
+		}
+		return
+	}
+	if conn.failed.Load() {
+		// 写出失败的连接与每tick的清理相同，立即移除，不进入保留期
+		room.evictFailed()
+		room.lock.Unlock()
+		s.closeRoomIfEmpty(room)
+		return
+	}
+	conn.logger().Info("player disconnected", "grace", resumeGrace.String())
+	snake.conn = nil
+	snake.detached = true
+	snake.pending = nil
+	snake.detachGen++
+	gen := snake.detachGen
+	room.lock.Unlock()
+
+	time.AfterFunc(resumeGrace, func() {
+		room.lock.Lock()
+		expired := snake.detached && snake.detachGen == gen
+		room.lock.Unlock()
+		if expired {
+			s.removePlayer(room, snake)
+		}
+	})
+}
+
+// 移除写出失败的连接：对应的玩家保存分数并广播离开，观战者直接移除。
+// 先收集再删除，不在遍历map时修改；读协程随后退出时发现玩家已不在房间，
+// 会负责销毁空房间。每tick广播后调用，调用方需持有房间锁
+func (r *Room) evictFailed() {
+	var dead []*Snake
+	for _, s := range r.players {
+		if s.conn != nil && s.conn.failed.Load() {
+			dead = append(dead, s)
+		}
+	}
+	for _, s := range dead {
+		if s.Alive {
+			r.saveScore(s, CauseDisconnect)
+		}
+		s.conn.Close()
+		s.conn = nil
+		delete(r.players, s.ID)
+		r.colors.release(s.Color)
+		r.dirty = true
+		r.emit(LeaveEvent{Type: "leave", Player: s.ID})
+		r.playerLog(s).Warn("player evicted after a failed write", "name", s.Name)
+	}
+	for c := range r.watchers {
+		if c.failed.Load() {
+			c.Close()
+			delete(r.watchers, c)
+			r.dirty = true
+		}
+	}
+	r.syncCounts()
+}
+
+// 移除玩家：存活则保存分数，广播离开，房间空了则销毁
+func (s *GameServer) removePlayer(room *Room, snake *Snake) {
+	room.lock.Lock()
+	if room.players[snake.ID] != snake {
+		room.lock.Unlock()
+		return
+	}
+	if snake.Alive {
+		room.saveScore(snake, CauseDisconnect)
+	}
+	delete(room.players, snake.ID)
+	room.colors.release(snake.Color)
+	room.dirty = true
+
+	// 广播玩家离开
+	room.emit(LeaveEvent{Type: "leave", Player: snake.ID})
+	room.playerLog(snake).Info("player left", "name", snake.Name)
+	room.syncCounts()
+	room.lock.Unlock()
+
+	// 最后一个连接离开，销毁房间
+	s.closeRoomIfEmpty(room)
+}
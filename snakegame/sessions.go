@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 一局的完整记录
+type Session struct {
+	ID         int64  `json:"id"`
+	PlayerID   string `json:"player_id"`
+	Room       string `json:"room"`
+	Score      int    `json:"score"`
+	MaxLen     int    `json:"max_length"`
+	Ticks      int64  `json:"ticks"`
+	DeathCause string `json:"death_cause"`
+	StartedAt  string `json:"started_at"`
+	EndedAt    string `json:"ended_at"`
+}
+
+// 对局查询参数，player和room为空表示不过滤
+type sessionQuery struct {
+	player string
+	room   string
+	limit  int
+	offset int
+}
+
+// 一页对局记录
+type sessionPage struct {
+	Rows  []Session
+	Total int
+}
+
+// 对局记录接口：GET /api/sessions?player=...&room=...&limit=...&offset=...
+func (s *GameServer) sessions(c *gin.Context) {
+	q := sessionQuery{player: c.Query("player"), room: c.Query("room")}
+	var err error
+	if q.limit, q.offset, err = parsePage(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), s.cfg.DBReadTimeout)
+	defer cancel()
+	page, err := s.store.Sessions(ctx, q)
+	if err != nil {
+		s.dbQueryFailed(c, "sessions query", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":   page.Rows,
+		"total":  page.Total,
+		"limit":  q.limit,
+		"offset": q.offset,
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 收到SIGINT/SIGTERM后优雅关闭的默认总时长
+const defaultShutdownGrace = 10 * time.Second
+
+// 关闭所有房间：停止循环，保存存活玩家的分数，通知客户端并发送关闭帧，
+// 最后等待分数写完。整个过程受ctx的超时约束
+func (s *GameServer) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	rooms := make([]*Room, 0, len(s.rooms))
+	for name, r := range s.rooms {
+		rooms = append(rooms, r)
+		delete(s.rooms, name)
+	}
+	s.lock.Unlock()
+	if s.cluster != nil {
+		for _, r := range rooms {
+			s.cluster.release(r.name)
+		}
+	}
+
+	var conns []*Conn
+	for _, r := range rooms {
+		conns = append(conns, r.shutdown()...)
+	}
+	if s.cluster != nil {
+		// 转发中的连接同样通知客户端
+		conns = append(conns, s.cluster.shutdown()...)
+	}
+
+	// 等待关闭帧写出
+	for _, c := range conns {
+		select {
+		case <-c.flush:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// 等待分数队列和录像写完
+	drained := make(chan struct{})
+	go func() {
+		s.scores.Close()
+		s.replays.Close()
+		if s.cluster != nil {
+			s.cluster.Close()
+		}
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 关闭房间并返回需要等待写完的连接
+func (r *Room) shutdown() []*Conn {
+	return r.terminate(CauseShutdown, "server_closing", websocket.CloseGoingAway, "server shutting down")
+}
+
+// 停止循环、以cause保存存活玩家的分数，向所有连接发送notice消息和关闭帧，返回这些连接
+func (r *Room) terminate(cause, notice string, code int, text string) []*Conn {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.closed {
+		r.closed = true
+		close(r.stopCh)
+	}
+	for _, snake := range r.players {
+		if snake.Alive {
+			r.saveScore(snake, cause)
+		}
+	}
+	// 所有分数已入队，不必等凑批
+	r.scores.flush()
+
+	r.replays.save(r.finishRecording())
+
+	conns := r.conns()
+	for _, c := range conns {
+		c.sendJSON(map[string]string{"type": notice})
+		c.closeWith(code, text)
+	}
+	// 清空玩家，断线保留期到期后的清理因此成为空操作
+	r.players = make(map[string]*Snake)
+	r.watchers = make(map[*Conn]bool)
+	r.log.Info("room closed", "reason", text, "conns", len(conns))
+	return conns
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 进程内服务器收到SIGTERM后关闭：客户端收到server_closing和关闭帧，存活玩家的分数写入存储
+func TestShutdownOnSignal(t *testing.T) {
+	store := newMemoryStore()
+	s, r := newTestServer(t, store)
+	r.GET("/ws/:room", s.handleWS)
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/closing?name=alice"
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// 收到欢迎信息后玩家已在房间中
+	if _, data, err := c.ReadMessage(); err != nil || !strings.Contains(string(data), `"type":"welcome"`) {
+		t.Fatalf("first message = %s, %v, want welcome", data, err)
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Skipf("cannot signal self: %v", err)
+	}
+	<-sigCtx.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownGrace)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	notified := false
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Errorf("read error = %v, want close 1001", err)
+			}
+			break
+		}
+		notified = notified || strings.Contains(string(data), `"type":"server_closing"`)
+	}
+	if !notified {
+		t.Error("no server_closing message before the close frame")
+	}
+	if n := len(s.roomList()); n != 0 {
+		t.Errorf("rooms after shutdown = %d, want 0", n)
+	}
+	deaths, err := store.DeathStats(context.Background(), "closing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deaths) != 1 || deaths[0] != (DeathCount{Cause: CauseShutdown, Count: 1}) {
+		t.Errorf("deaths = %+v, want one shutdown", deaths)
+	}
+}
+
+// 连接迟迟写不完关闭帧时，Shutdown在宽限期结束后返回
+func TestShutdownGrace(t *testing.T) {
+	s, _ := newTestServer(t, newMemoryStore())
+	room := s.getRoom("stuck", s.cfg.roomDefaults())
+	room.lock.Lock()
+	// 没有写协程的连接，flush永远不会关闭
+	room.watchers[allocConn(time.Second)] = true
+	room.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want deadline exceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Shutdown took %v, want about 100ms", d)
+	}
+}
+
+// 关闭房间时存活玩家的分数按关闭原因记录
+func TestTerminateCause(t *testing.T) {
+	for _, cause := range []string{CauseShutdown, CauseAdminClose, CauseExpired} {
+		store := newMemoryStore()
+		cfg := Config{Tick: tickInterval, Board: defaultBoardSize, MaxPlayers: defaultMaxPlayers, DBWriteTimeout: time.Second}
+		s := NewGameServer(store, cfg)
+		room := s.getRoom("r1", cfg.roomDefaults())
+		room.lock.Lock()
+		room.addBot()
+		for _, snake := range room.players {
+			// 机器人不记分，当作真人
+			snake.Bot = false
+		}
+		room.lock.Unlock()
+
+		room.terminate(cause, "room_closed", websocket.CloseNormalClosure, "test")
+		s.scores.Close()
+		deaths, err := store.DeathStats(context.Background(), "r1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deaths) != 1 || deaths[0] != (DeathCount{Cause: cause, Count: 1}) {
+			t.Errorf("terminate(%s): deaths = %+v, want one %s", cause, deaths, cause)
+		}
+	}
+}
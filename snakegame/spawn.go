@@ -0,0 +1,156 @@
+package main
+
+import (
+	"time"
+)
+
+// 出生保护时长：蛇可见但不移动，其他蛇可以穿过它
+const spawnFreeze = 3 * time.Second
+
+// 出生位置参数
+const (
+	spawnLength      = 3   // 新蛇的初始长度
+	spawnMinHeadDist = 4   // 与其他蛇头的最小曼哈顿距离
+	spawnAttempts    = 200 // 随机搜索的次数上限
+)
+
+// 出生保护对应的tick数
+func (r *Room) spawnFreezeTicks() int {
+	n := int((spawnFreeze + r.interval - 1) / r.interval)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// 让蛇进入出生保护并开始记录新的一局，加入和重生时调用，调用方需持有房间锁
+func (r *Room) startSpawn(s *Snake) {
+	s.SpawnTicks = r.spawnFreezeTicks()
+	s.Spawning = true
+	s.startTick = r.tick
+	s.startedAt = time.Now()
+	s.MaxLen = len(s.Body)
+	s.scoreSaved = false
+	s.Ghost = 0
+	r.dirty = true
+}
+
+// 出生保护倒计时，每tick调用一次，调用方需持有房间锁
+func (r *Room) tickSpawns() {
+	for _, s := range r.players {
+		if s.SpawnTicks > 0 {
+			s.SpawnTicks--
+			s.Spawning = s.SpawnTicks > 0
+			r.dirty = true
+		}
+	}
+}
+
+// 判断格子是否被障碍物、食物、道具或任何蛇身占用，调用方需持有房间锁
+func (r *Room) occupied(p Point) bool {
+	if r.isObstacle(p) || r.foodAt(p) >= 0 || r.powerUpAt(p) >= 0 || r.hazardAt(p) >= 0 {
+		return true
+	}
+	for _, s := range r.players {
+		for _, b := range s.Body {
+			if b == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 背对最近墙壁的方向
+func (r *Room) awayFromWall(p Point) string {
+	dir, best := "right", p.X // 最近的是左墙则向右
+	if d := r.width - 1 - p.X; d < best {
+		dir, best = "left", d
+	}
+	if d := p.Y; d < best {
+		dir, best = "down", d
+	}
+	if d := r.height - 1 - p.Y; d < best {
+		dir = "up"
+	}
+	return dir
+}
+
+// 从蛇头朝dir反方向延伸出n节身体（从头到尾），越界时返回nil
+func (r *Room) bodyBehind(head Point, dir string, n int) []Point {
+	dx, dy := 0, 0
+	switch dir {
+	case "up":
+		dy = 1
+	case "down":
+		dy = -1
+	case "left":
+		dx = 1
+	case "right":
+		dx = -1
+	}
+	body := make([]Point, n)
+	for i := range body {
+		p := Point{X: head.X + dx*i, Y: head.Y + dy*i}
+		if p.X < 0 || p.X >= r.width || p.Y < 0 || p.Y >= r.height {
+			return nil
+		}
+		body[i] = p
+	}
+	return body
+}
+
+// 蛇头到其他蛇头的曼哈顿距离是否都不小于spawnMinHeadDist
+func (r *Room) farFromHeads(p Point) bool {
+	for _, s := range r.players {
+		if len(s.Body) == 0 {
+			continue
+		}
+		h := s.Body[0]
+		if abs(h.X-p.X)+abs(h.Y-p.Y) < spawnMinHeadDist {
+			return false
+		}
+	}
+	return true
+}
+
+// 为新蛇选择出生位置：长度spawnLength、背对最近的墙、远离其他蛇头且不与任何东西重叠。
+// 找不到时退化为任意空格上的单节蛇，棋盘已满时身体为空。
+// 返回从头到尾的身体和初始方向，调用方需持有房间锁
+func (r *Room) spawnPlacement() ([]Point, string) {
+	for i := 0; i < spawnAttempts; i++ {
+		head := Point{X: r.rng.Intn(r.width), Y: r.rng.Intn(r.height)}
+		if !r.farFromHeads(head) {
+			continue
+		}
+		dir := r.awayFromWall(head)
+		body := r.bodyBehind(head, dir, spawnLength)
+		if body == nil {
+			continue
+		}
+		free := true
+		for _, p := range body {
+			if r.occupied(p) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return body, dir
+		}
+	}
+	p, ok := r.randomEmptyCell()
+	if !ok {
+		r.log.Warn("board full, no spawn cell")
+		return nil, "right"
+	}
+	r.log.Debug("no safe spawn found, falling back", "x", p.X, "y", p.Y)
+	return []Point{p}, r.awayFromWall(p)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
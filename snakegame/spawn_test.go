@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// 初始方向背对最近的墙
+func TestAwayFromWall(t *testing.T) {
+	r := newMoveRoom(10, 10)
+	tests := []struct {
+		p    Point
+		want string
+	}{
+		{Point{X: 0, Y: 5}, "right"},
+		{Point{X: 9, Y: 4}, "left"},
+		{Point{X: 4, Y: 0}, "down"},
+		{Point{X: 5, Y: 9}, "up"},
+		{Point{X: 1, Y: 8}, "right"},
+	}
+	for _, tt := range tests {
+		if got := r.awayFromWall(tt.p); got != tt.want {
+			t.Errorf("awayFromWall(%v) = %s, want %s", tt.p, got, tt.want)
+		}
+	}
+}
+
+// 有其他蛇和食物的棋盘上：长度3、身体连续、背对墙壁、不重叠且远离其他蛇头
+func TestSpawnPlacementSafe(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := newMoveRoom(12, 12,
+			snakeAt("P1", "right", Point{X: 5, Y: 5}, Point{X: 4, Y: 5}, Point{X: 3, Y: 5}),
+			snakeAt("P2", "up", Point{X: 8, Y: 2}, Point{X: 8, Y: 3}, Point{X: 8, Y: 4}),
+		)
+		r.foods = []Food{{Point: Point{X: 2, Y: 9}}, {Point: Point{X: 10, Y: 10}}}
+		r.rng = rand.New(rand.NewSource(seed))
+		r.log = slog.Default()
+
+		body, dir := r.spawnPlacement()
+		name := fmt.Sprintf("seed %d: %v %s", seed, body, dir)
+		if len(body) != spawnLength {
+			t.Fatalf("%s: length %d, want %d", name, len(body), spawnLength)
+		}
+		if dir != r.awayFromWall(body[0]) {
+			t.Errorf("%s: not facing away from the nearest wall", name)
+		}
+		if !slices.Equal(body, r.bodyBehind(body[0], dir, spawnLength)) {
+			t.Errorf("%s: body does not trail behind the head", name)
+		}
+		if !r.farFromHeads(body[0]) {
+			t.Errorf("%s: too close to another head", name)
+		}
+		for _, p := range body {
+			if r.occupied(p) {
+				t.Errorf("%s: %v is occupied", name, p)
+			}
+		}
+	}
+}
+
+// 拥挤的棋盘：只有一处能放下安全出生位置时找到它，否则退化为空格上的单节蛇，棋盘满时身体为空
+func TestSpawnPlacementCrowded(t *testing.T) {
+	tests := []struct {
+		name     string
+		w, h     int
+		free     []Point
+		wantBody []Point
+	}{
+		{
+			name:     "one safe strip",
+			w:        8,
+			h:        8,
+			free:     []Point{{X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}},
+			wantBody: []Point{{X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}},
+		},
+		{
+			name:     "fallback to one cell",
+			w:        4,
+			h:        4,
+			free:     []Point{{X: 2, Y: 3}},
+			wantBody: []Point{{X: 2, Y: 3}},
+		},
+		{name: "full board", w: 4, h: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := crowdedBoard(tt.w, tt.h, tt.free...)
+			r.log = slog.Default()
+			body, _ := r.spawnPlacement()
+			if !slices.Equal(body, tt.wantBody) {
+				t.Errorf("body = %v, want %v", body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 默认的加速分数线：0分每3个tick移动一次，10分起每2个tick，25分起每tick
+var defaultSpeedTiers = []int{10, 25}
+
+// 最多支持的分数线个数
+const maxSpeedTiers = 4
+
+// 解析 ?speed=on（默认分数线）或 ?speed=5,15,30（升序分数线），
+// 空值或非法值表示不启用按分数加速，所有蛇每tick移动
+func parseSpeedTiers(s string) []int {
+	if s == "" {
+		return nil
+	}
+	if s == "on" {
+		return append([]int(nil), defaultSpeedTiers...)
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) > maxSpeedTiers {
+		return nil
+	}
+	tiers := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return nil
+		}
+		tiers = append(tiers, n)
+	}
+	if !sort.IntsAreSorted(tiers) {
+		return nil
+	}
+	return tiers
+}
+
+// 当前分数所在的速度档位，从1开始，越大越快；未启用加速时为0
+func (r *Room) speedTier(score int) int {
+	if len(r.speedTiers) == 0 {
+		return 0
+	}
+	tier := 1
+	for _, t := range r.speedTiers {
+		if score >= t {
+			tier++
+		}
+	}
+	return tier
+}
+
+// 当前分数下每隔几个tick移动一次
+func (r *Room) moveEvery(score int) int {
+	if len(r.speedTiers) == 0 {
+		return 1
+	}
+	return len(r.speedTiers) + 2 - r.speedTier(score)
+}
+
+// 累加蛇的tick计数，返回本tick是否轮到它移动，调用方需持有房间锁
+func (r *Room) dueToMove(s *Snake) bool {
+	s.moveAcc++
+	if s.moveAcc < r.moveEvery(s.Score) {
+		return false
+	}
+	s.moveAcc = 0
+	return true
+}
@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package main
+
+// SQLite驱动体积较大，需要时用 go build -tags sqlite 编译进来
+import _ "modernc.org/sqlite"
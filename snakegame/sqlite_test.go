@@ -0,0 +1,45 @@
+//go:build sqlite
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// SQLite存储执行迁移后承载同样的查询接口，用 go test -tags sqlite 运行
+func TestSQLiteStoreHandlers(t *testing.T) {
+	st, err := openStore("sqlite://" + filepath.Join(t.TempDir(), "snake.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	checkStoreHandlers(t, st)
+}
+
+// SQLite中房间名按原样精确匹配
+func TestSQLiteRoomFilter(t *testing.T) {
+	st, err := openStore("sqlite://" + filepath.Join(t.TempDir(), "snake.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	checkRoomFilter(t, st)
+}
+
+// SQLite的日榜和周榜边界，created_at按UTC文本写入
+func TestSQLitePeriods(t *testing.T) {
+	st, err := openStore("sqlite://" + filepath.Join(t.TempDir(), "snake.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	sq := st.(*sqlStore)
+	checkPeriods(t, st, func(room string, at time.Time) {
+		if _, err := sq.db.Exec("INSERT INTO snake_score (player_id, room, score, created_at) VALUES (?, ?, ?, ?)",
+			"p-"+room, room, 10, sq.timeArg(at)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// SSE心跳间隔，期间没有消息时发送注释行，防止代理断开空闲连接
+const sseHeartbeat = 15 * time.Second
+
+// SSE观战流接口：GET /api/rooms/:room/stream，?fps=2 限制状态帧频率，私人房间需要 ?pass=。
+// 流作为观战连接挂到房间的广播上，由本协程把发送队列写成事件，
+// 每条消息与WebSocket观战收到的JSON相同；房间不存在返回404，不会创建房间
+func (s *GameServer) roomStream(c *gin.Context) {
+	room := s.findRoom(c.Param("room"))
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+	if !requirePass(c, room) {
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming unsupported"})
+		return
+	}
+
+	// 状态帧最小间隔，0表示每tick都发送
+	var every time.Duration
+	if v, err := strconv.Atoi(c.Query("fps")); err == nil && v > 0 {
+		every = time.Second / time.Duration(v)
+	}
+
+	conn := allocConn(room.interval)
+	conn.setLogger(slog.With("room", room.name, "remote", c.ClientIP(), "sse", true))
+	defer close(conn.flush)
+	room.lock.Lock()
+	if room.closed {
+		room.lock.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+	room.watchers[conn] = true
+	room.dirty = true
+	// 先发一帧完整状态，不必等到下一个tick
+	first, _ := json.Marshal(room.stateMessage())
+	conn.sendText(first)
+	room.syncCounts()
+	room.lock.Unlock()
+	defer s.unwatch(room, conn)
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // 关闭nginx的响应缓冲
+	c.Status(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	var lastState time.Time
+	for {
+		var err error
+		select {
+		case m := <-conn.send:
+			if m.mt == websocket.CloseMessage {
+				return
+			}
+			if m.mt != websocket.TextMessage {
+				continue
+			}
+			if every > 0 && isStateFrame(m.data) {
+				now := time.Now()
+				if now.Sub(lastState) < every {
+					continue
+				}
+				lastState = now
+			}
+			_, err = fmt.Fprintf(c.Writer, "data: %s\n\n", m.data)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(c.Writer, ": ping\n\n")
+		case <-conn.done:
+			return
+		case <-c.Request.Context().Done():
+			// 客户端断开或服务器关闭
+			return
+		}
+		if err != nil {
+			conn.failed.Store(true)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// 判断一条JSON消息是否为完整状态帧
+func isStateFrame(data []byte) bool {
+	var head struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(data, &head) == nil && head.Type == "state"
+}
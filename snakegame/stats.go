@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 进程级计数器；写库成功的行数由分数写入器统计，数据库失败次数见dbStats
+type serverStats struct {
+	startedAt time.Time
+	ticks     atomic.Int64 // 所有房间累计执行的tick数
+
+	mu        sync.Mutex
+	lastAt    time.Time // 上次计算tick速率的时间
+	lastTicks int64     // 上次计算时的累计tick数
+	tps       float64   // 最近一次计算的每秒tick数
+}
+
+// tick速率的最短采样窗口，更频繁的查询返回上一次的结果
+const tpsWindow = time.Second
+
+// 创建计数器
+func newServerStats() *serverStats {
+	now := time.Now()
+	return &serverStats{startedAt: now, lastAt: now}
+}
+
+// 最近一个采样窗口内实际达到的每秒tick数（所有房间合计）
+func (st *serverStats) ticksPerSecond(now time.Time) float64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	elapsed := now.Sub(st.lastAt)
+	if elapsed < tpsWindow {
+		return st.tps
+	}
+	ticks := st.ticks.Load()
+	st.tps = float64(ticks-st.lastTicks) / elapsed.Seconds()
+	st.lastAt, st.lastTicks = now, ticks
+	return st.tps
+}
+
+// 全局统计接口，只短暂持有各房间锁，可以每秒轮询。
+// expected_tps 为所有房间按各自间隔应达到的速率，ticks_per_sec 明显偏低说明服务器过载
+func (s *GameServer) stats(c *gin.Context) {
+	now := time.Now()
+	rooms, players, spectators := 0, 0, 0
+	expected := 0.0
+	for _, r := range s.roomList() {
+		r.lock.Lock()
+		rooms++
+		for _, p := range r.players {
+			if p.conn != nil {
+				players++
+			}
+		}
+		spectators += len(r.watchers)
+		interval := r.interval
+		if r.dormant {
+			interval = dormantInterval
+		}
+		expected += float64(time.Second) / float64(interval)
+		r.lock.Unlock()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rooms":          rooms,
+		"players":        players, // 在线的真人玩家，不含断线保留期内的蛇
+		"spectators":     spectators,
+		"ticks_per_sec":  s.counters.ticksPerSecond(now),
+		"expected_tps":   expected,
+		"ticks_total":    s.counters.ticks.Load(),
+		"scores_written": s.scores.written.Load(),
+		"db_errors":      s.db.errors.Load(),
+		"db_timeouts":    s.db.timeouts.Load(),
+		"uptime_sec":     int64(now.Sub(s.counters.startedAt) / time.Second),
+	})
+}
+
+// 一种结束原因的局数
+type DeathCount struct {
+	Cause string `json:"cause"` // 早于原因统计的记录为unknown
+	Count int    `json:"count"`
+}
+
+// 死亡原因统计接口：GET /api/stats/deaths?room=...，不传room时汇总所有房间
+func (s *GameServer) deathStats(c *gin.Context) {
+	room := c.Query("room")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), s.cfg.DBReadTimeout)
+	defer cancel()
+	rows, err := s.store.DeathStats(ctx, room)
+	if err != nil {
+		s.dbQueryFailed(c, "death stats", err)
+		return
+	}
+	total := 0
+	for i := range rows {
+		if rows[i].Cause == "" {
+			rows[i].Cause = "unknown"
+		}
+		total += rows[i].Count
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows, "total": total, "room": room})
+}
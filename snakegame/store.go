@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 数据库操作默认超时，可用 -db-write-timeout、-db-read-timeout 调整
+const (
+	defaultDBWriteTimeout = 2 * time.Second
+	defaultDBReadTimeout  = 5 * time.Second
+)
+
+// 数据库操作的失败次数，分数写入器和查询接口共用
+type dbStats struct {
+	errors   atomic.Int64 // 失败次数，含超时
+	timeouts atomic.Int64 // 其中超时的次数
+}
+
+// 记录一次失败的数据库操作并写日志，超时单独标注
+func (d *dbStats) record(op string, err error) {
+	if d.count(err) {
+		slog.Warn("db operation timed out", "op", op, "err", err)
+		return
+	}
+	slog.Error("db operation failed", "op", op, "err", err)
+}
+
+// 只计数不记日志，返回是否为超时
+func (d *dbStats) count(err error) bool {
+	d.errors.Add(1)
+	if errors.Is(err, context.DeadlineExceeded) {
+		d.timeouts.Add(1)
+		return true
+	}
+	return false
+}
+
+// 分数存储：游戏写入分数、排行榜和玩家统计读取，
+// 有MySQL、SQLite和内存三种实现
+type ScoreStore interface {
+	// 批量写入分数
+	SaveScores(ctx context.Context, rows []scoreRow) error
+	// 批量写入一局的完整记录
+	SaveSessions(ctx context.Context, rows []scoreRow) error
+	// 按玩家和房间分页查询历史对局，最新的在前
+	Sessions(ctx context.Context, q sessionQuery) (sessionPage, error)
+	// 写入一条回合制对局结果
+	SaveMatch(ctx context.Context, row matchRow) error
+	// 查询一页排行榜
+	Leaderboard(ctx context.Context, q rankQuery) (rankPage, error)
+	// 查询玩家统计，玩家没有记录时found为false
+	PlayerStats(ctx context.Context, playerID string) (stats PlayerStats, found bool, err error)
+	// 按结束原因统计局数，room为空表示所有房间
+	DeathStats(ctx context.Context, room string) ([]DeathCount, error)
+	// 查询玩家的历史最高分，玩家没有记录时found为false
+	BestScore(ctx context.Context, playerID string) (best int, found bool, err error)
+	Close() error
+}
+
+// 按DSN选择存储实现：memory:// 为内存存储，sqlite://<path> 为SQLite，
+// 其余按MySQL DSN处理；也可以用 SCORE_STORE=mysql|sqlite|memory 显式指定
+func openStore(dsn string) (ScoreStore, error) {
+	kind := os.Getenv("SCORE_STORE")
+	switch {
+	case kind == "":
+		switch {
+		case strings.HasPrefix(dsn, "memory://"):
+			kind = "memory"
+		case strings.HasPrefix(dsn, "sqlite://"):
+			kind = "sqlite"
+			dsn = strings.TrimPrefix(dsn, "sqlite://")
+		default:
+			kind = "mysql"
+		}
+	case kind == "sqlite":
+		dsn = strings.TrimPrefix(dsn, "sqlite://")
+	}
+
+	switch kind {
+	case "memory":
+		return newMemoryStore(), nil
+	case "mysql", "sqlite":
+		return openSQLStore(kind, dsn)
+	}
+	return nil, fmt.Errorf("unknown SCORE_STORE %q", kind)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 内存中的一条分数记录
+type memScore struct {
+	playerID  string
+	room      string
+	score     int
+	maxLen    int
+	cause     string
+	createdAt time.Time
+}
+
+// 内存存储：本地演示和测试用，进程退出后数据丢失
+type memoryStore struct {
+	mu       sync.Mutex
+	rows     []memScore
+	sessions []Session
+	matches  []matchRow
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{}
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
+
+func (m *memoryStore) SaveScores(ctx context.Context, rows []scoreRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, r := range rows {
+		m.rows = append(m.rows, memScore{playerID: r.playerID, room: r.room, score: r.score, maxLen: r.maxLen, cause: r.cause, createdAt: now})
+	}
+	return nil
+}
+
+func (m *memoryStore) SaveSessions(ctx context.Context, rows []scoreRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().Format(time.RFC3339)
+	for _, r := range rows {
+		m.sessions = append(m.sessions, Session{
+			ID:         int64(len(m.sessions) + 1),
+			PlayerID:   r.playerID,
+			Room:       r.room,
+			Score:      r.score,
+			MaxLen:     r.maxLen,
+			Ticks:      r.ticks,
+			DeathCause: r.cause,
+			StartedAt:  r.startedAt.Format(time.RFC3339),
+			EndedAt:    now,
+		})
+	}
+	return nil
+}
+
+// 最新的在前，与SQL实现的 ORDER BY id DESC 一致
+func (m *memoryStore) Sessions(ctx context.Context, q sessionQuery) (sessionPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matched []Session
+	for i := len(m.sessions) - 1; i >= 0; i-- {
+		s := m.sessions[i]
+		if (q.player != "" && s.PlayerID != q.player) || (q.room != "" && s.Room != q.room) {
+			continue
+		}
+		matched = append(matched, s)
+	}
+	page := sessionPage{Rows: []Session{}, Total: len(matched)}
+	for i := q.offset; i < len(matched) && i < q.offset+q.limit; i++ {
+		page.Rows = append(page.Rows, matched[i])
+	}
+	return page, nil
+}
+
+func (m *memoryStore) SaveMatch(ctx context.Context, row matchRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matches = append(m.matches, row)
+	return nil
+}
+
+// 按(player_id, room)聚合后排序，与SQL实现保持一致
+func (m *memoryStore) Leaderboard(ctx context.Context, q rankQuery) (rankPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type key struct{ player, room string }
+	type agg struct {
+		row  RankRow
+		last time.Time
+	}
+	groups := make(map[key]*agg)
+	for _, r := range m.rows {
+		if (q.room != "" && r.room != q.room) || (!q.since.IsZero() && r.createdAt.Before(q.since)) {
+			continue
+		}
+		k := key{r.playerID, r.room}
+		g, ok := groups[k]
+		if !ok {
+			g = &agg{row: RankRow{PlayerID: r.playerID, Name: r.playerID, Room: r.room, Best: r.score, BestLength: r.maxLen}}
+			groups[k] = g
+		}
+		g.row.Games++
+		if r.score > g.row.Best {
+			g.row.Best = r.score
+		}
+		if r.maxLen > g.row.BestLength {
+			g.row.BestLength = r.maxLen
+		}
+		if r.createdAt.After(g.last) {
+			g.last = r.createdAt
+		}
+	}
+
+	all := make([]*agg, 0, len(groups))
+	for _, g := range groups {
+		g.row.Last = g.last.Format(time.RFC3339)
+		all = append(all, g)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].row, all[j].row
+		if q.sort == SortLength && a.BestLength != b.BestLength {
+			return a.BestLength > b.BestLength
+		}
+		if a.Best != b.Best {
+			return a.Best > b.Best
+		}
+		return all[i].last.After(all[j].last)
+	})
+
+	page := rankPage{Rows: []RankRow{}, Total: len(all)}
+	for i := q.offset; i < len(all) && i < q.offset+q.limit; i++ {
+		page.Rows = append(page.Rows, all[i].row)
+	}
+	return page, nil
+}
+
+// 排序与SQL实现一致：次数多的在前，次数相同按原因名
+func (m *memoryStore) DeathStats(ctx context.Context, room string) ([]DeathCount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]int)
+	for _, r := range m.rows {
+		if room == "" || r.room == room {
+			counts[r.cause]++
+		}
+	}
+	out := []DeathCount{}
+	for cause, n := range counts {
+		out = append(out, DeathCount{Cause: cause, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Cause < out[j].Cause
+	})
+	return out, nil
+}
+
+func (m *memoryStore) BestScore(ctx context.Context, id string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	best, found := 0, false
+	for _, r := range m.rows {
+		if r.playerID == id && (!found || r.score > best) {
+			best, found = r.score, true
+		}
+	}
+	return best, found, nil
+}
+
+func (m *memoryStore) PlayerStats(ctx context.Context, id string) (PlayerStats, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := PlayerStats{PlayerID: id, Rooms: []PlayerRoomStats{}}
+	rooms := make(map[string]*PlayerRoomStats)
+	var last time.Time
+	total := 0
+	for _, r := range m.rows {
+		if r.playerID != id {
+			continue
+		}
+		out.Games++
+		total += r.score
+		if r.score > out.Best {
+			out.Best = r.score
+		}
+		if r.createdAt.After(last) {
+			last = r.createdAt
+		}
+		rs, ok := rooms[r.room]
+		if !ok {
+			rs = &PlayerRoomStats{Room: r.room}
+			rooms[r.room] = rs
+		}
+		rs.Average = (rs.Average*float64(rs.Games) + float64(r.score)) / float64(rs.Games+1)
+		rs.Games++
+		if r.score > rs.Best {
+			rs.Best = r.score
+		}
+		rs.LastPlay = r.createdAt.Format(time.RFC3339)
+	}
+	if out.Games == 0 {
+		return out, false, nil
+	}
+	out.Average = float64(total) / float64(out.Games)
+	out.LastPlay = last.Format(time.RFC3339)
+	for _, rs := range rooms {
+		out.Rooms = append(out.Rooms, *rs)
+	}
+	sort.Slice(out.Rooms, func(i, j int) bool {
+		if out.Rooms[i].Games != out.Rooms[j].Games {
+			return out.Rooms[i].Games > out.Rooms[j].Games
+		}
+		return out.Rooms[i].Room < out.Rooms[j].Room
+	})
+	return out, true, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 基于database/sql的存储，dialect为 "mysql" 或 "sqlite"
+type sqlStore struct {
+	db      *sql.DB
+	dialect string
+}
+
+// 打开数据库、检查连通性并执行迁移
+func openSQLStore(dialect, dsn string) (*sqlStore, error) {
+	db, err := sql.Open(dialect, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultDBReadTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db ping: %w", err)
+	}
+	st := &sqlStore{db: db, dialect: dialect}
+	// 迁移中的ALTER TABLE在大表上可能很慢，不设超时
+	if err := st.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db migrate: %w", err)
+	}
+	return st, nil
+}
+
+func (st *sqlStore) Close() error {
+	return st.db.Close()
+}
+
+// 多行插入
+func (st *sqlStore) SaveScores(ctx context.Context, rows []scoreRow) error {
+	placeholders := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*6)
+	for i, r := range rows {
+		placeholders[i] = "(?, ?, ?, ?, ?, ?)"
+		args = append(args, r.playerID, r.room, r.score, r.maxLen, r.cause, r.killer)
+	}
+	_, err := st.db.ExecContext(ctx, "INSERT INTO snake_score (player_id, room, score, max_len, death_cause, killer) VALUES "+
+		strings.Join(placeholders, ", "), args...)
+	return err
+}
+
+func (st *sqlStore) SaveMatch(ctx context.Context, row matchRow) error {
+	_, err := st.db.ExecContext(ctx, "INSERT INTO snake_match (room, round, winner, players) VALUES (?, ?, ?, ?)",
+		row.room, row.round, row.winner, row.players)
+	return err
+}
+
+func (st *sqlStore) SaveSessions(ctx context.Context, rows []scoreRow) error {
+	placeholders := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*7)
+	for i, r := range rows {
+		placeholders[i] = "(?, ?, ?, ?, ?, ?, ?)"
+		args = append(args, r.playerID, r.room, r.score, r.maxLen, r.ticks, r.cause, st.timeArg(r.startedAt))
+	}
+	_, err := st.db.ExecContext(ctx, "INSERT INTO snake_session (player_id, room, score, max_len, ticks, death_cause, started_at) VALUES "+
+		strings.Join(placeholders, ", "), args...)
+	return err
+}
+
+func (st *sqlStore) Sessions(ctx context.Context, q sessionQuery) (sessionPage, error) {
+	var conds []string
+	var args []interface{}
+	if q.player != "" {
+		conds = append(conds, "player_id = ?")
+		args = append(args, q.player)
+	}
+	if q.room != "" {
+		conds = append(conds, "room = ?")
+		args = append(args, q.room)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := st.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM snake_session "+where, args...).Scan(&total); err != nil {
+		return sessionPage{}, err
+	}
+
+	rows, err := st.db.QueryContext(ctx, `
+		SELECT id, player_id, room, score, max_len, ticks, death_cause, started_at, ended_at
+		FROM snake_session `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, append(args, q.limit, q.offset)...)
+	if err != nil {
+		return sessionPage{}, err
+	}
+	defer rows.Close()
+
+	page := sessionPage{Rows: []Session{}, Total: total}
+	for rows.Next() {
+		var r Session
+		if err := rows.Scan(&r.ID, &r.PlayerID, &r.Room, &r.Score, &r.MaxLen, &r.Ticks, &r.DeathCause, &r.StartedAt, &r.EndedAt); err == nil {
+			page.Rows = append(page.Rows, r)
+		}
+	}
+	return page, rows.Err()
+}
+
+// 时间参数：SQLite中created_at以UTC文本保存，需按相同格式比较
+func (st *sqlStore) timeArg(t time.Time) interface{} {
+	if st.dialect == "sqlite" {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	return t
+}
+
+// 拼接排行榜WHERE子句，没有条件时为空
+func (st *sqlStore) rankWhere(q rankQuery) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	// 房间名按原样精确比较，其中的 % 和 _ 没有通配含义
+	if q.room != "" {
+		conds = append(conds, "room = ?")
+		args = append(args, q.room)
+	}
+	if !q.since.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, st.timeArg(q.since))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+func (st *sqlStore) Leaderboard(ctx context.Context, q rankQuery) (rankPage, error) {
+	where, args := st.rankWhere(q)
+
+	var total int
+	err := st.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM snake_score
+			`+where+`
+			GROUP BY player_id, room
+		) t`, args...).Scan(&total)
+	if err != nil {
+		return rankPage{}, err
+	}
+
+	rows, err := st.db.QueryContext(ctx, `
+		SELECT player_id, room, MAX(score) AS best_score, MAX(max_len) AS best_length, COUNT(*) AS games, MAX(created_at) AS last_play
+		FROM snake_score
+		`+where+`
+		GROUP BY player_id, room
+		ORDER BY `+q.orderBy()+`, last_play DESC
+		LIMIT ? OFFSET ?`, append(args, q.limit, q.offset)...)
+	if err != nil {
+		return rankPage{}, err
+	}
+	defer rows.Close()
+
+	page := rankPage{Rows: []RankRow{}, Total: total}
+	for rows.Next() {
+		var r RankRow
+		if err := rows.Scan(&r.PlayerID, &r.Room, &r.Best, &r.BestLength, &r.Games, &r.Last); err == nil {
+			r.Name = r.PlayerID
+			page.Rows = append(page.Rows, r)
+		}
+	}
+	return page, rows.Err()
+}
+
+func (st *sqlStore) DeathStats(ctx context.Context, room string) ([]DeathCount, error) {
+	where, args := "", []interface{}{}
+	if room != "" {
+		where, args = "WHERE room = ?", append(args, room)
+	}
+	rows, err := st.db.QueryContext(ctx, `
+		SELECT death_cause, COUNT(*) AS n
+		FROM snake_score
+		`+where+`
+		GROUP BY death_cause
+		ORDER BY n DESC, death_cause`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []DeathCount{}
+	for rows.Next() {
+		var d DeathCount
+		if err := rows.Scan(&d.Cause, &d.Count); err == nil {
+			out = append(out, d)
+		}
+	}
+	return out, rows.Err()
+}
+
+func (st *sqlStore) BestScore(ctx context.Context, id string) (int, bool, error) {
+	var best sql.NullInt64
+	err := st.db.QueryRowContext(ctx, `SELECT MAX(score) FROM snake_score WHERE player_id = ?`, id).Scan(&best)
+	if err != nil {
+		return 0, false, err
+	}
+	return int(best.Int64), best.Valid, nil
+}
+
+func (st *sqlStore) PlayerStats(ctx context.Context, id string) (PlayerStats, bool, error) {
+	out := PlayerStats{PlayerID: id, Rooms: []PlayerRoomStats{}}
+	var last sql.NullString
+	err := st.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(MAX(score), 0), COALESCE(AVG(score), 0), MAX(created_at)
+		FROM snake_score
+		WHERE player_id = ?`, id).Scan(&out.Games, &out.Best, &out.Average, &last)
+	if err != nil {
+		return out, false, err
+	}
+	if out.Games == 0 {
+		return out, false, nil
+	}
+	out.LastPlay = last.String
+
+	rows, err := st.db.QueryContext(ctx, `
+		SELECT room, COUNT(*) AS games, MAX(score), AVG(score), MAX(created_at)
+		FROM snake_score
+		WHERE player_id = ?
+		GROUP BY room
+		ORDER BY games DESC, room`, id)
+	if err != nil {
+		return out, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r PlayerRoomStats
+		if err := rows.Scan(&r.Room, &r.Games, &r.Best, &r.Average, &r.LastPlay); err == nil {
+			out.Rooms = append(out.Rooms, r)
+		}
+	}
+	return out, true, rows.Err()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 使用store的服务器和只挂了查询接口的路由
+func newTestServer(t *testing.T, store ScoreStore) (*GameServer, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := NewGameServer(store, Config{
+		Tick:           tickInterval,
+		Board:          defaultBoardSize,
+		MaxPlayers:     defaultMaxPlayers,
+		DBWriteTimeout: time.Second,
+		DBReadTimeout:  time.Second,
+	})
+	t.Cleanup(s.scores.Close)
+	r := gin.New()
+	r.GET("/api/leaderboard", s.leaderboard)
+	r.GET("/api/player/:id", s.playerStats)
+	r.GET("/api/sessions", s.sessions)
+	r.GET("/api/stats/deaths", s.deathStats)
+	return s, r
+}
+
+// 发送GET请求，检查状态码并解码JSON响应
+func getJSON(t *testing.T, r http.Handler, url string, want int, out any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	if rec.Code != want {
+		t.Fatalf("GET %s: status %d, want %d: %s", url, rec.Code, want, rec.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+	}
+}
+
+// 内存存储承载排行榜等查询接口
+func TestMemoryStoreHandlers(t *testing.T) {
+	checkStoreHandlers(t, newMemoryStore())
+}
+
+// 分数经写入器进入store后，排行榜、玩家统计、历史对局和死亡统计接口都能查到
+func checkStoreHandlers(t *testing.T, store ScoreStore) {
+	t.Helper()
+	s, r := newTestServer(t, store)
+	start := time.Now().Add(-time.Minute)
+	for _, row := range []scoreRow{
+		{playerID: "alice", room: "r1", score: 30, maxLen: 8, cause: CauseWall, startedAt: start},
+		{playerID: "alice", room: "r1", score: 50, maxLen: 6, cause: CauseSelf, startedAt: start},
+		{playerID: "bob", room: "r1", score: 40, maxLen: 12, cause: CauseWall, startedAt: start},
+		{playerID: "bob", room: "r2", score: 10, maxLen: 3, cause: CauseOther, killer: "alice", startedAt: start},
+	} {
+		if !s.scores.enqueue(row) {
+			t.Fatalf("enqueue %+v failed", row)
+		}
+	}
+	// Close会写完队列中的行
+	s.scores.Close()
+
+	var board struct {
+		Data  []RankRow `json:"data"`
+		Total int       `json:"total"`
+	}
+	getJSON(t, r, "/api/leaderboard?room=r1", http.StatusOK, &board)
+	if board.Total != 2 || len(board.Data) != 2 {
+		t.Fatalf("leaderboard r1: total %d rows %d, want 2", board.Total, len(board.Data))
+	}
+	if got := board.Data[0]; got.PlayerID != "alice" || got.Best != 50 || got.Games != 2 || got.BestLength != 8 {
+		t.Errorf("leaderboard r1 first row = %+v, want alice best 50 in 2 games, length 8", got)
+	}
+	getJSON(t, r, "/api/leaderboard?room=r1&sort=length", http.StatusOK, &board)
+	if board.Data[0].PlayerID != "bob" {
+		t.Errorf("leaderboard sort=length first = %s, want bob", board.Data[0].PlayerID)
+	}
+	getJSON(t, r, "/api/leaderboard", http.StatusOK, &board)
+	if board.Total != 3 {
+		t.Errorf("leaderboard all rooms total = %d, want 3 (player, room) groups", board.Total)
+	}
+
+	var stats PlayerStats
+	getJSON(t, r, "/api/player/bob", http.StatusOK, &stats)
+	if stats.Games != 2 || stats.Best != 40 || len(stats.Rooms) != 2 {
+		t.Errorf("player bob = %+v, want 2 games, best 40, 2 rooms", stats)
+	}
+	getJSON(t, r, "/api/player/nobody", http.StatusNotFound, nil)
+
+	var sessions struct {
+		Data  []Session `json:"data"`
+		Total int       `json:"total"`
+	}
+	getJSON(t, r, "/api/sessions?player=alice", http.StatusOK, &sessions)
+	if sessions.Total != 2 {
+		t.Errorf("sessions for alice = %d, want 2", sessions.Total)
+	}
+
+	var deaths struct {
+		Data []DeathCount `json:"data"`
+	}
+	getJSON(t, r, "/api/stats/deaths?room=r1", http.StatusOK, &deaths)
+	if len(deaths.Data) == 0 || deaths.Data[0] != (DeathCount{Cause: CauseWall, Count: 2}) {
+		t.Errorf("deaths r1 = %+v, want wall=2 first", deaths.Data)
+	}
+}
+
+// DSN前缀和SCORE_STORE选择存储实现
+func TestOpenStore(t *testing.T) {
+	st, err := openStore("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.(*memoryStore); !ok {
+		t.Errorf("memory:// opened %T", st)
+	}
+	t.Setenv("SCORE_STORE", "bogus")
+	if _, err := openStore("memory://"); err == nil {
+		t.Error("SCORE_STORE=bogus: want error")
+	}
+	t.Setenv("SCORE_STORE", "memory")
+	if st, err := openStore("root@tcp(127.0.0.1:3306)/x"); err != nil {
+		t.Error(err)
+	} else if _, ok := st.(*memoryStore); !ok {
+		t.Errorf("SCORE_STORE=memory opened %T", st)
+	}
+}
+
+// 每次操作都挂起到超时的存储
+type hangingStore struct {
+	*memoryStore
+}
+
+func (hangingStore) SaveScores(ctx context.Context, rows []scoreRow) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (hangingStore) Leaderboard(ctx context.Context, q rankQuery) (rankPage, error) {
+	<-ctx.Done()
+	return rankPage{}, ctx.Err()
+}
+
+func (hangingStore) PlayerStats(ctx context.Context, playerID string) (PlayerStats, bool, error) {
+	<-ctx.Done()
+	return PlayerStats{}, false, ctx.Err()
+}
+
+// 写库超时后写入器放弃该批并计为超时，关闭不会一直阻塞
+func TestScoreWriteTimeout(t *testing.T) {
+	var db dbStats
+	w := newScoreWriter(hangingStore{newMemoryStore()}, 50*time.Millisecond, &db)
+	w.enqueue(scoreRow{playerID: "alice", room: "r1", score: 10, startedAt: time.Now()})
+	done := make(chan struct{})
+	go func() {
+		w.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a hung store")
+	}
+	if db.timeouts.Load() == 0 {
+		t.Errorf("timeouts = %d, errors = %d, want the write counted as a timeout", db.timeouts.Load(), db.errors.Load())
+	}
+}
+
+// 查询超过读超时返回504并计为超时
+func TestQueryTimeout(t *testing.T) {
+	s, r := newTestServer(t, hangingStore{newMemoryStore()})
+	s.cfg.DBReadTimeout = 50 * time.Millisecond
+	for i, url := range []string{"/api/leaderboard", "/api/player/alice"} {
+		getJSON(t, r, url, http.StatusGatewayTimeout, nil)
+		if got := s.db.timeouts.Load(); got != int64(i+1) {
+			t.Errorf("after %s: timeouts = %d, want %d", url, got, i+1)
+		}
+	}
+}
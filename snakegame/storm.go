@@ -0,0 +1,90 @@
+package main
+
+import "golearn/snakegame/protocol"
+
+// 回合制房间的决胜阶段：回合进行defaultSuddenDeath个tick后仍有2条以上的蛇存活，
+// 场地每stormEvery个tick向内收缩一圈，圈外的格子等同于墙。
+// 开始时间可按房间用 ?sudden=N 调整，0表示不收缩
+const (
+	defaultSuddenDeath = 600 // 回合开始多少tick后进入决胜阶段
+	minSuddenDeath     = 50
+	maxSuddenDeath     = 100000
+	stormEvery         = 20 // 每隔多少tick收缩一圈
+	minArenaSize       = 3  // 场地最小边长，收缩到此为止
+)
+
+// 可活动的矩形范围，四条边都包含在内
+type Bounds = protocol.Bounds
+
+// 当前可活动的范围，没有收缩时为整个棋盘，调用方需持有房间锁
+func (r *Room) bounds() Bounds {
+	ring := 0
+	if r.match != nil {
+		ring = r.match.ring
+	}
+	return Bounds{Left: ring, Top: ring, Right: r.width - 1 - ring, Bottom: r.height - 1 - ring}
+}
+
+// 点是否在当前可活动的范围内
+func (r *Room) inBounds(p Point) bool {
+	return r.bounds().Contains(p)
+}
+
+// 回合进行中每tick调用一次：到达决胜阶段后按间隔收缩场地，广播shrink事件，
+// 圈外的蛇以storm死亡，圈外的食物、道具和危险格消失，调用方需持有房间锁
+func (r *Room) tickStorm() {
+	m := r.match
+	m.ticks++
+	if r.suddenDeath <= 0 || m.ticks < r.suddenDeath || (m.ticks-r.suddenDeath)%stormEvery != 0 {
+		return
+	}
+	b := r.bounds()
+	if b.Right-b.Left-1 < minArenaSize || b.Bottom-b.Top-1 < minArenaSize {
+		return
+	}
+	m.ring++
+	b = r.bounds()
+	r.dirty = true
+	r.emit(map[string]interface{}{"type": "shrink", "tick": r.tick, "bounds": b})
+
+	for _, s := range r.sortedPlayers() {
+		if !s.Alive {
+			continue
+		}
+		for _, p := range s.Body {
+			if !b.Contains(p) {
+				s.Alive = false
+				s.Ghost = 0
+				r.die(s, CauseStorm, nil)
+				break
+			}
+		}
+	}
+	foods := r.foods[:0]
+	for _, f := range r.foods {
+		if b.Contains(f.Point) {
+			foods = append(foods, f)
+		}
+	}
+	r.foods = foods
+	powerups := r.powerups[:0]
+	for _, pu := range r.powerups {
+		if b.Contains(pu.Point) {
+			powerups = append(powerups, pu)
+		}
+	}
+	r.powerups = powerups
+	hazards := r.hazards[:0]
+	for _, h := range r.hazards {
+		if b.Contains(h.Point) {
+			hazards = append(hazards, h)
+		}
+	}
+	r.hazards = hazards
+}
+
+// 回合结束后场地恢复为整个棋盘，调用方需持有房间锁
+func (r *Room) resetStorm() {
+	r.match.ticks = 0
+	r.match.ring = 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+
+	"golearn/snakegame/protocol"
+)
+
+// 视野半径范围（?view=N），只对玩家连接生效，观战者始终收到完整状态
+const (
+	minViewRadius = 5
+	maxViewRadius = 50
+)
+
+// 点是否在以c为中心、半径为radius的正方形视野内
+func inView(p, c Point, radius int) bool {
+	return abs(p.X-c.X) <= radius && abs(p.Y-c.Y) <= radius
+}
+
+// 视野内的状态帧：与stateMessage相同，但只包含视野内的蛇（任一节在视野内即整条下发）、
+// 食物和道具，自己的蛇总是完整下发；视野外存活的蛇只在minimap中给出蛇头。
+// 没有身体（未出生或等待下一回合）时返回完整状态，调用方需持有房间锁
+func (r *Room) viewportMessage(self *Snake, radius int) *protocol.State {
+	msg := r.stateMessage()
+	if len(self.Body) == 0 {
+		return msg
+	}
+	center := self.Body[0]
+
+	players := make(map[string]*protocol.Player)
+	var minimap []protocol.MinimapEntry
+	for id, s := range msg.Players {
+		visible := s.ID == self.ID
+		for _, p := range s.Body {
+			if visible {
+				break
+			}
+			visible = inView(p, center, radius)
+		}
+		switch {
+		case visible:
+			players[id] = s
+		case s.Alive && len(s.Body) > 0:
+			minimap = append(minimap, protocol.MinimapEntry{ID: s.ID, X: s.Body[0].X, Y: s.Body[0].Y, Color: s.Color})
+		}
+	}
+	foods := []Food{}
+	for _, f := range r.foods {
+		if inView(f.Point, center, radius) {
+			foods = append(foods, f)
+		}
+	}
+	powerups := []PowerUp{}
+	for _, pu := range r.powerups {
+		if inView(pu.Point, center, radius) {
+			powerups = append(powerups, pu)
+		}
+	}
+	hazards := []Hazard{}
+	for _, h := range r.hazards {
+		if inView(h.Point, center, radius) {
+			hazards = append(hazards, h)
+		}
+	}
+
+	msg.Players = players
+	msg.Foods = foods
+	msg.Food = Food{}
+	if len(foods) > 0 {
+		msg.Food = foods[0]
+	}
+	msg.PowerUps = powerups
+	msg.Hazards = hazards
+	msg.Minimap = minimap
+	msg.Viewport = &protocol.Viewport{X: center.X, Y: center.Y, Radius: radius}
+	return msg
+}
+
+// 为开启视野的玩家逐个生成并发送状态帧，返回已处理的连接，调用方需持有房间锁
+func (r *Room) sendViewports() map[*Conn]bool {
+	done := make(map[*Conn]bool)
+	for _, s := range r.players {
+		if s.conn == nil || s.conn.view == 0 {
+			continue
+		}
+		data, err := json.Marshal(r.viewportMessage(s, s.conn.view))
+		if err != nil {
+			continue
+		}
+		s.conn.sendText(data)
+		done[s.conn] = true
+	}
+	return done
+}